@@ -9,11 +9,20 @@ import (
 	"path/filepath"
 	"strings"
 	"syscall"
+	"time"
 
+	"gitstatus/src/actions"
+	"gitstatus/src/config"
+	"gitstatus/src/defaults"
+	"gitstatus/src/exitcode"
+	"gitstatus/src/git"
 	"gitstatus/src/logger"
 	"gitstatus/src/output"
+	"gitstatus/src/pattern"
+	"gitstatus/src/tui"
 	"gitstatus/src/types"
 	"gitstatus/src/walker"
+	"gitstatus/src/watch"
 )
 
 func parseLogTypes(logStr string) []string {
@@ -23,38 +32,238 @@ func parseLogTypes(logStr string) []string {
 	return strings.Split(logStr, ",")
 }
 
+// stringList is a flag.Value that collects every occurrence of a repeatable flag
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// parseInterspersed continues parsing fs after each positional argument so
+// flags may follow the root paths, e.g. "gitstatus ~/work ~/oss -all". It
+// returns the positional arguments.
+func parseInterspersed(fs *flag.FlagSet) []string {
+	var positional []string
+	for fs.NArg() > 0 {
+		args := fs.Args()
+		positional = append(positional, args[0])
+		rest := args[1:]
+		if len(rest) > 0 && rest[0] == "--" {
+			return append(positional, rest[1:]...)
+		}
+		fs.Parse(rest)
+	}
+	return positional
+}
+
+// loadSettings reads the config layers below the command line in increasing
+// precedence: the user config file (or the -config file) and GITSTATUS_*
+// environment variables. The .gitstatus file of each scan root only applies
+// to that root and is read by the walker; it is checked here so a mistake in
+// it stops the scan before it starts.
+func loadSettings(configFile string, roots []string) ([]config.Setting, error) {
+	var settings []config.Setting
+
+	userFile, required := configFile, true
+	if userFile == "" {
+		userFile, required = config.UserConfigPath(), false
+	}
+	if userFile != "" {
+		fileSettings, err := config.ReadFile(userFile, required)
+		if err != nil {
+			return nil, err
+		}
+		settings = append(settings, fileSettings...)
+	}
+
+	for _, root := range roots {
+		if _, err := config.ReadRootFile(root); err != nil {
+			return nil, err
+		}
+	}
+
+	return append(settings, config.ReadEnv(flag.CommandLine, "config")...), nil
+}
+
+// usage prints the command line syntax and the flags
+func usage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintf(out, "Usage: %s [%s] [flags] [paths...]\n\nFlags:\n", filepath.Base(os.Args[0]), strings.Join(actions.Actions, "|"))
+	flag.PrintDefaults()
+}
+
 func main() {
 	depth := flag.Int("depth", 0, "Maximum directory depth (0 = unlimited)")
+	jobs := flag.Int("jobs", defaults.DefaultJobs, "Number of repositories to analyze in parallel")
 	logLevels := flag.String("log", "", "Log levels (comma-separated: DEBUG, INFO, WARNING, ERROR)")
 	showAll := flag.Bool("all", false, "Show all repositories including clean ones")
+	relative := flag.Bool("relative", false, "Show repository paths relative to the root they were found under")
+	fetch := flag.Bool("fetch", false, "Run git fetch --all --prune in each repository before checking status")
+	allRemotes := flag.Bool("all-remotes", false, "Also compare each branch with the branch of the same name on every other remote")
+	commits := flag.Bool("commits", false, "List the commits ahead of and behind the upstream under each branch")
+	commitLimit := flag.Int("commit-limit", defaults.DefaultCommitLimit, "With -commits, the most commits listed per repository (0 = unlimited)")
+	noTags := flag.Bool("no-tags", false, "Do not report local tags that were never pushed, and do not list the remotes' tags with -fetch")
+	failOnList := flag.String("fail-on", defaults.DefaultFailOn, "Conditions that cause a non-zero exit (comma-separated: "+strings.Join(exitcode.Conditions, ", ")+")")
 	noColor := flag.Bool("no-color", false, "Disable colored output")
+	format := flag.String("format", defaults.DefaultOutputFormat, "Output format: "+strings.Join(output.Formats, ", "))
+	columnList := flag.String("columns", defaults.DefaultColumns, "Columns of -format table (comma-separated: "+strings.Join(output.Columns, ", ")+")")
+	perBranch := flag.Bool("per-branch", false, "With -format table, print one row per branch instead of per repository")
+	sortOrder := flag.String("sort", defaults.DefaultSort, "Order of the -format table rows: "+strings.Join(output.Sorts, ", "))
+	staleAge := flag.String("stale", "", "Only show repositories and branches without commits or changes for this long, e.g. 30d")
+	sinceAge := flag.String("since", "", "Only show repositories and branches with commits or changes within this long, e.g. 2d")
+	watchMode := flag.Bool("watch", false, "Keep running and update the report whenever a repository changes")
+	interactive := flag.Bool("i", false, "Browse the results in a full-screen terminal interface")
+	summary := flag.Bool("summary", false, "Print totals across all scanned repositories after the results")
+	summaryOnly := flag.Bool("summary-only", false, "Print only the totals across all scanned repositories")
 	logFile := flag.String("logfile", "", "Log file path (optional)")
-	flag.Parse()
+	var ignore, exclude, include stringList
+	flag.Var(&ignore, "ignore", "Directory names to skip besides "+strings.Join(defaults.DefaultIgnoredDirs, ",")+" (comma-separated, !name to scan one of those, repeatable)")
+	flag.Var(&exclude, "exclude", "Gitignore-style pattern of directories to skip, relative to the scan root (repeatable)")
+	flag.Var(&include, "include", "Gitignore-style pattern of repositories to analyze, relative to the scan root (repeatable)")
+	backend := flag.String("backend", defaults.DefaultBackend, "How repositories are read: "+strings.Join(git.Backends, ", "))
+	configFile := flag.String("config", "", "Config file to read instead of "+config.UserConfigPath())
+	dryRun := flag.Bool("dry-run", false, "With pull, push or prune-gone, report what would be done without doing it")
+	flag.Usage = usage
+
+	// "gitstatus pull|push|prune-gone [flags] [paths]" runs an action on the
+	// scanned repositories instead of printing the report
+	action := ""
+	if len(os.Args) > 1 && actions.IsValidAction(os.Args[1]) {
+		action = os.Args[1]
+		flag.CommandLine.Parse(os.Args[2:])
+	} else {
+		flag.Parse()
+	}
+
+	rootArgs := parseInterspersed(flag.CommandLine)
+	if len(rootArgs) == 0 {
+		rootArgs = []string{"."}
+	}
+
+	var roots []string
+	for _, rootArg := range rootArgs {
+		absRoot, err := filepath.Abs(rootArg)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error resolving path: %v\n", err)
+			os.Exit(exitcode.Error)
+		}
+		roots = append(roots, absRoot)
+	}
+
+	if *configFile == "" {
+		*configFile = os.Getenv(config.EnvName("config"))
+	}
+
+	settings, err := loadSettings(*configFile, roots)
+	if err == nil {
+		err = config.Apply(flag.CommandLine, settings, "config")
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load configuration: %v\n", err)
+		os.Exit(exitcode.Error)
+	}
+
+	if !output.IsValidFormat(*format) {
+		fmt.Fprintf(os.Stderr, "Unknown output format %q (expected one of: %s)\n", *format, strings.Join(output.Formats, ", "))
+		os.Exit(exitcode.Error)
+	}
+
+	columns, err := output.ParseColumns(*columnList)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(exitcode.Error)
+	}
+
+	if *commitLimit < 0 {
+		fmt.Fprintf(os.Stderr, "-commit-limit must not be negative\n")
+		os.Exit(exitcode.Error)
+	}
+
+	if !output.IsValidSort(*sortOrder) {
+		fmt.Fprintf(os.Stderr, "Unknown sort order %q (expected one of: %s)\n", *sortOrder, strings.Join(output.Sorts, ", "))
+		os.Exit(exitcode.Error)
+	}
+
+	var stale, since time.Duration
+	if *staleAge != "" {
+		if stale, err = output.ParseAge(*staleAge); err != nil {
+			fmt.Fprintf(os.Stderr, "-stale: %v\n", err)
+			os.Exit(exitcode.Error)
+		}
+	}
+	if *sinceAge != "" {
+		if since, err = output.ParseAge(*sinceAge); err != nil {
+			fmt.Fprintf(os.Stderr, "-since: %v\n", err)
+			os.Exit(exitcode.Error)
+		}
+	}
 
-	rootPath := "."
-	if len(flag.Args()) > 0 {
-		rootPath = flag.Args()[0]
+	if !git.IsValidBackend(*backend) {
+		fmt.Fprintf(os.Stderr, "Unknown backend %q (expected one of: %s)\n", *backend, strings.Join(git.Backends, ", "))
+		os.Exit(exitcode.Error)
 	}
 
-	absRoot, err := filepath.Abs(rootPath)
+	if *watchMode && *interactive {
+		fmt.Fprintf(os.Stderr, "-watch and -i cannot be combined\n")
+		os.Exit(exitcode.Error)
+	}
+
+	if action != "" && (*watchMode || *interactive) {
+		fmt.Fprintf(os.Stderr, "%s cannot be combined with -watch or -i\n", action)
+		os.Exit(exitcode.Error)
+	}
+
+	failOn, err := exitcode.ParseFailOn(*failOnList)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error resolving path: %v\n", err)
-		os.Exit(1)
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(exitcode.Error)
+	}
+
+	for _, patterns := range [][]string{exclude, include} {
+		if _, err := pattern.ParseList(patterns); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(exitcode.Error)
+		}
 	}
 
 	cfg := types.Config{
-		RootPath: absRoot,
-		MaxDepth: *depth,
-		LogTypes: parseLogTypes(*logLevels),
-		ShowAll:  *showAll,
-		NoColor:  *noColor,
-		LogFile:  *logFile,
+		RootPaths:   roots,
+		MaxDepth:    *depth,
+		IgnoredDirs: ignore,
+		Exclude:     exclude,
+		Include:     include,
+		Jobs:        *jobs,
+		LogTypes:    parseLogTypes(*logLevels),
+		ShowAll:     *showAll,
+		Relative:    *relative,
+		NoColor:     *noColor,
+		Format:      *format,
+		Columns:     columns,
+		PerBranch:   *perBranch,
+		Sort:        *sortOrder,
+		Commits:     *commits,
+		CommitLimit: *commitLimit,
+		Stale:       stale,
+		Since:       since,
+		Summary:     *summary,
+		SummaryOnly: *summaryOnly,
+		Fetch:       *fetch,
+		NoTags:      *noTags,
+		AllRemotes:  *allRemotes,
+		Backend:     *backend,
+		FailOn:      failOn,
+		LogFile:     *logFile,
 	}
 
 	logger, err := logger.NewLogger(cfg.LogTypes, cfg.LogFile)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Failed to initialize logger: %v\n", err)
-		os.Exit(1)
+		os.Exit(exitcode.Error)
 	}
 
 	ctx, cancel := context.WithCancel(context.Background())
@@ -68,10 +277,19 @@ func main() {
 		cancel()
 	}()
 
-	logger.Info("Starting git status scan in: %s", cfg.RootPath)
+	logger.Info("Starting git status scan in: %s", strings.Join(cfg.RootPaths, ", "))
+
+	if *watchMode {
+		if err := watch.Run(ctx, cfg, logger); err != nil {
+			fmt.Fprintf(os.Stderr, "Watch failed: %v\n", err)
+			os.Exit(exitcode.Error)
+		}
+		os.Exit(exitcode.Clean)
+	}
 
 	var results []types.RepoResult
 
+	start := time.Now()
 	err = walker.Walk(ctx, cfg, logger, func(res types.RepoResult) {
 		results = append(results, res)
 	})
@@ -82,5 +300,39 @@ func main() {
 
 	logger.Info("Scan complete. Found %d repositories.", len(results))
 
-	output.PrintResults(results, cfg, logger)
+	if action != "" {
+		outcomes := actions.Run(ctx, action, results, cfg, *dryRun, logger)
+		actions.PrintReport(outcomes, cfg)
+		switch {
+		case ctx.Err() != nil:
+			os.Exit(exitcode.Interrupted)
+		case err != nil || actions.Failed(outcomes):
+			os.Exit(exitcode.Error)
+		default:
+			os.Exit(exitcode.Clean)
+		}
+	}
+
+	if *interactive && ctx.Err() == nil {
+		if tuiErr := tui.Run(ctx, results, cfg, logger); tuiErr != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", tuiErr)
+			os.Exit(exitcode.Error)
+		}
+		if ctx.Err() != nil {
+			os.Exit(exitcode.Interrupted)
+		}
+		os.Exit(exitcode.Clean)
+	}
+
+	// The exit code covers what was printed, after -stale and -since
+	shown := output.PrintResults(results, cfg, time.Since(start), logger)
+
+	switch {
+	case ctx.Err() != nil:
+		os.Exit(exitcode.Interrupted)
+	case err != nil:
+		os.Exit(exitcode.Error)
+	default:
+		os.Exit(exitcode.Evaluate(shown, cfg.FailOn))
+	}
 }
@@ -0,0 +1,256 @@
+// Package actions implements the subcommands that act on the scan results:
+// pull, push and prune-gone. Each only makes changes that cannot lose work,
+// and reports per repository and branch what it did, skipped or failed.
+package actions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"gitstatus/src/defaults"
+	"gitstatus/src/git"
+	"gitstatus/src/logger"
+	"gitstatus/src/output"
+	"gitstatus/src/types"
+)
+
+// Subcommands
+const (
+	ActionPull      = "pull"
+	ActionPush      = "push"
+	ActionPruneGone = "prune-gone"
+)
+
+// Actions lists every subcommand
+var Actions = []string{ActionPull, ActionPush, ActionPruneGone}
+
+// IsValidAction reports whether name is a subcommand
+func IsValidAction(name string) bool {
+	for _, a := range Actions {
+		if name == a {
+			return true
+		}
+	}
+	return false
+}
+
+// Status is what happened to a step
+type Status string
+
+const (
+	StatusDone    Status = "done"
+	StatusDryRun  Status = "dry-run" // the step would run without -dry-run
+	StatusSkipped Status = "skipped"
+	StatusFailed  Status = "failed"
+)
+
+// Outcome is the result of one step of an action: a branch pulled, pushed
+// or deleted, or the reason it was not
+type Outcome struct {
+	Repo   types.RepoResult
+	Branch string
+	Status Status
+	Detail string // what the step does, why it was skipped or how it failed
+}
+
+// step is a change an action plans for a branch. A step with a skip reason
+// is reported without running.
+type step struct {
+	branch string
+	detail string
+	skip   string
+	run    func(ctx context.Context, logger *logger.Logger) error
+}
+
+// Run plans action for every repository in results and, unless dryRun is
+// set, runs the planned steps on cfg.Jobs repositories at a time. Outcomes
+// are returned in the order of results.
+func Run(ctx context.Context, action string, results []types.RepoResult, cfg types.Config, dryRun bool, logger *logger.Logger) []Outcome {
+	jobs := cfg.Jobs
+	if jobs < 1 {
+		jobs = defaults.DefaultJobs
+	}
+
+	perRepo := make([][]Outcome, len(results))
+	sem := make(chan struct{}, jobs)
+	var wg sync.WaitGroup
+	for i := range results {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			perRepo[i] = runRepo(ctx, action, results[i], dryRun, logger)
+		}(i)
+	}
+	wg.Wait()
+
+	var outcomes []Outcome
+	for _, o := range perRepo {
+		outcomes = append(outcomes, o...)
+	}
+	return outcomes
+}
+
+// runRepo runs the steps of action in one repository in order
+func runRepo(ctx context.Context, action string, res types.RepoResult, dryRun bool, logger *logger.Logger) []Outcome {
+	var steps []step
+	switch action {
+	case ActionPull:
+		steps = planPull(res)
+	case ActionPush:
+		steps = planPush(ctx, res)
+	case ActionPruneGone:
+		steps = planPruneGone(ctx, res)
+	}
+
+	var outcomes []Outcome
+	for _, s := range steps {
+		o := Outcome{Repo: res, Branch: s.branch, Detail: s.detail}
+		switch {
+		case s.skip != "":
+			o.Status, o.Detail = StatusSkipped, s.skip
+		case dryRun:
+			o.Status = StatusDryRun
+		case ctx.Err() != nil:
+			o.Status, o.Detail = StatusSkipped, "interrupted"
+		default:
+			logger.Info("%s %s: %s", res.Path, s.branch, s.detail)
+			if err := s.run(ctx, logger); err != nil {
+				o.Status, o.Detail = StatusFailed, err.Error()
+			} else {
+				o.Status = StatusDone
+			}
+		}
+		outcomes = append(outcomes, o)
+	}
+	return outcomes
+}
+
+// currentBranch returns the status of the checked out branch, which is only
+// listed when it needs attention
+func currentBranch(res types.RepoResult) (types.BranchSyncStatus, bool) {
+	for _, b := range res.Branches {
+		if b.Current {
+			return b, true
+		}
+	}
+	return types.BranchSyncStatus{}, false
+}
+
+// planPull fast-forwards the checked out branch when it is behind its
+// upstream, unless that could mix with uncommitted or unfinished work
+func planPull(res types.RepoResult) []step {
+	if res.Error != nil {
+		return nil
+	}
+	b, ok := currentBranch(res)
+	if !ok || b.Behind == 0 || b.Gone || b.NoUpstream {
+		return nil
+	}
+
+	s := step{
+		branch: b.Name,
+		detail: "fast-forward " + output.Pluralize(b.Behind, "commit"),
+		run: func(ctx context.Context, logger *logger.Logger) error {
+			return git.Pull(ctx, res.Path, logger)
+		},
+	}
+	switch {
+	case b.Ahead > 0:
+		s.skip = fmt.Sprintf("diverged from upstream (ahead %d, behind %d), cannot fast-forward", b.Ahead, b.Behind)
+	case res.HasOperation:
+		s.skip = string(res.Operation.Operation) + " in progress"
+	case res.HasUncommitted:
+		s.skip = "working tree has uncommitted changes"
+	}
+	return []step{s}
+}
+
+// planPush pushes every branch that is ahead of its push destination and
+// not behind it, so the push is a fast-forward on the remote. That is the
+// upstream unless the branch pushes elsewhere, as in triangular workflows,
+// where branches never pushed to their destination are pushed when they are
+// ahead of their upstream. Branches git push would refuse to push, such as
+// ones tracking another local branch or, with push.default simple, an
+// upstream of another name, are skipped with git's reason.
+func planPush(ctx context.Context, res types.RepoResult) []step {
+	if res.Error != nil {
+		return nil
+	}
+
+	var steps []step
+	for _, b := range res.Branches {
+		target, ahead, behind := b.Upstream, b.Ahead, b.Behind
+		if b.Push != "" {
+			target, ahead, behind = b.Push, b.PushAhead, b.PushBehind
+		} else if b.Gone || b.NoUpstream {
+			continue
+		}
+		if b.PushGone {
+			// Never pushed there: only worth it with work not on the upstream
+			ahead = b.Ahead
+		}
+		if ahead == 0 {
+			continue
+		}
+
+		branch := b.Name
+		s := step{
+			branch: branch,
+			detail: "push " + output.Pluralize(ahead, "commit") + " to " + target,
+			run: func(ctx context.Context, logger *logger.Logger) error {
+				return git.PushBranch(ctx, res.Path, branch, logger)
+			},
+		}
+		if b.PushGone {
+			s.detail = "push to " + target + ", not pushed there yet"
+		}
+		_, _, destErr := git.GetPushDestination(ctx, res.Path, branch)
+		switch {
+		case errors.Is(destErr, git.ErrNoPushDestination):
+			s.skip = strings.TrimPrefix(destErr.Error(), git.ErrNoPushDestination.Error()+": ")
+		case behind > 0 && b.Push != "":
+			s.skip = fmt.Sprintf("diverged from %s (ahead %d, behind %d)", target, ahead, behind)
+		case behind > 0:
+			s.skip = fmt.Sprintf("diverged from upstream (ahead %d, behind %d), pull first", ahead, behind)
+		}
+		steps = append(steps, s)
+	}
+	return steps
+}
+
+// planPruneGone deletes the branches whose upstream was deleted, as long as
+// they are fully merged into HEAD and not checked out
+func planPruneGone(ctx context.Context, res types.RepoResult) []step {
+	if res.Error != nil {
+		return nil
+	}
+
+	var steps []step
+	for _, b := range res.Branches {
+		if !b.Gone {
+			continue
+		}
+		branch := b.Name
+		s := step{
+			branch: branch,
+			detail: "delete branch, upstream is gone",
+			run: func(ctx context.Context, logger *logger.Logger) error {
+				return git.DeleteBranch(ctx, res.Path, branch, logger)
+			},
+		}
+		if b.Current {
+			s.skip = "checked out"
+		} else if merged, err := git.IsMerged(ctx, res.Path, branch); err != nil {
+			s.skip = err.Error()
+		} else if !merged {
+			s.skip = "not fully merged into HEAD"
+		}
+		steps = append(steps, s)
+	}
+	return steps
+}
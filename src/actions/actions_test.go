@@ -0,0 +1,275 @@
+package actions
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gitstatus/src/logger"
+	"gitstatus/src/types"
+	"gitstatus/src/walker"
+)
+
+// gitRun runs git in dir and fails the test when it fails
+func gitRun(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", append([]string{"-c", "user.name=Test User", "-c", "user.email=test@example.com"}, args...)...)
+	cmd.Dir = dir
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %v failed: %v\n%s", args, err, output)
+	}
+	return strings.TrimSpace(string(output))
+}
+
+// setupClones creates a bare remote with a main branch and two clones of it,
+// returning the remote and clone paths
+func setupClones(t *testing.T) (string, string, string) {
+	t.Helper()
+	dir := t.TempDir()
+	remote := filepath.Join(dir, "remote.git")
+	gitRun(t, dir, "init", "--quiet", "--bare", "--initial-branch=main", remote)
+
+	seed := filepath.Join(dir, "seed")
+	gitRun(t, dir, "init", "--quiet", "--initial-branch=main", seed)
+	gitRun(t, seed, "commit", "--quiet", "--allow-empty", "-m", "Initial commit")
+	gitRun(t, seed, "push", "--quiet", remote, "main")
+
+	a := filepath.Join(dir, "a")
+	b := filepath.Join(dir, "b")
+	gitRun(t, dir, "clone", "--quiet", remote, a)
+	gitRun(t, dir, "clone", "--quiet", remote, b)
+	return remote, a, b
+}
+
+// analyze returns the status of the repositories at paths
+func analyze(t *testing.T, paths ...string) []types.RepoResult {
+	t.Helper()
+	logger, _ := logger.NewLogger([]string{}, "")
+	var results []types.RepoResult
+	for _, path := range paths {
+		res, ok := walker.AnalyzeRepo(context.Background(), types.Config{}, path, nil, logger)
+		if !ok || res.Error != nil {
+			t.Fatalf("AnalyzeRepo(%s) failed: %v", path, res.Error)
+		}
+		results = append(results, res)
+	}
+	return results
+}
+
+func run(t *testing.T, action string, results []types.RepoResult, dryRun bool) []Outcome {
+	t.Helper()
+	logger, _ := logger.NewLogger([]string{}, "")
+	return Run(context.Background(), action, results, types.Config{Jobs: 2}, dryRun, logger)
+}
+
+// expectOutcomes checks the branch and status of each outcome
+func expectOutcomes(t *testing.T, outcomes []Outcome, want ...string) {
+	t.Helper()
+	var got []string
+	for _, o := range outcomes {
+		got = append(got, o.Branch+" "+string(o.Status))
+	}
+	if strings.Join(got, ", ") != strings.Join(want, ", ") {
+		t.Errorf("Outcomes = %v, want %v (%+v)", got, want, outcomes)
+	}
+}
+
+func TestPull(t *testing.T) {
+	_, a, b := setupClones(t)
+	gitRun(t, a, "commit", "--quiet", "--allow-empty", "-m", "Upstream change")
+	gitRun(t, a, "push", "--quiet")
+	gitRun(t, b, "fetch", "--quiet")
+
+	outcomes := run(t, ActionPull, analyze(t, a, b), true)
+	expectOutcomes(t, outcomes, "main dry-run")
+	if outcomes[0].Detail != "fast-forward 1 commit" {
+		t.Errorf("Detail = %q", outcomes[0].Detail)
+	}
+	if head := gitRun(t, b, "rev-list", "--count", "HEAD"); head != "1" {
+		t.Errorf("Dry run changed HEAD: %s commits", head)
+	}
+
+	// Uncommitted changes are left alone
+	if err := os.WriteFile(filepath.Join(b, "file.txt"), []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	outcomes = run(t, ActionPull, analyze(t, b), false)
+	expectOutcomes(t, outcomes, "main skipped")
+	os.Remove(filepath.Join(b, "file.txt"))
+
+	outcomes = run(t, ActionPull, analyze(t, b), false)
+	expectOutcomes(t, outcomes, "main done")
+	if head := gitRun(t, b, "rev-list", "--count", "HEAD"); head != "2" {
+		t.Errorf("HEAD has %s commits after pull, want 2", head)
+	}
+
+	// A diverged branch cannot be fast-forwarded
+	gitRun(t, a, "commit", "--quiet", "--allow-empty", "-m", "Upstream change")
+	gitRun(t, a, "push", "--quiet")
+	gitRun(t, b, "commit", "--quiet", "--allow-empty", "-m", "Local change")
+	gitRun(t, b, "fetch", "--quiet")
+	outcomes = run(t, ActionPull, analyze(t, b), false)
+	expectOutcomes(t, outcomes, "main skipped")
+	if !strings.HasPrefix(outcomes[0].Detail, "diverged") {
+		t.Errorf("Detail = %q", outcomes[0].Detail)
+	}
+}
+
+func TestPush(t *testing.T) {
+	remote, a, b := setupClones(t)
+	gitRun(t, a, "checkout", "--quiet", "-b", "feature")
+	gitRun(t, a, "push", "--quiet", "-u", "origin", "feature")
+	gitRun(t, a, "commit", "--quiet", "--allow-empty", "-m", "Feature work")
+	gitRun(t, a, "checkout", "--quiet", "main")
+	gitRun(t, a, "commit", "--quiet", "--allow-empty", "-m", "Local change")
+
+	// main in b is behind after a pushes, so b's local commit diverges
+	gitRun(t, b, "commit", "--quiet", "--allow-empty", "-m", "Other change")
+
+	outcomes := run(t, ActionPush, analyze(t, a), false)
+	expectOutcomes(t, outcomes, "feature done", "main done")
+	if outcomes[0].Detail != "push 1 commit to origin/feature" {
+		t.Errorf("Detail = %q", outcomes[0].Detail)
+	}
+	for _, branch := range []string{"main", "feature"} {
+		if got, want := gitRun(t, remote, "rev-parse", branch), gitRun(t, a, "rev-parse", branch); got != want {
+			t.Errorf("Remote %s at %s, want %s", branch, got, want)
+		}
+	}
+
+	gitRun(t, b, "fetch", "--quiet")
+	outcomes = run(t, ActionPush, analyze(t, b), false)
+	expectOutcomes(t, outcomes, "main skipped")
+
+	// A branch tracking a local branch is never pushed, nor is the branch it
+	// tracks moved
+	gitRun(t, a, "checkout", "--quiet", "-b", "topic", "--track", "main")
+	gitRun(t, a, "commit", "--quiet", "--allow-empty", "-m", "Topic work")
+	gitRun(t, a, "checkout", "--quiet", "main")
+	mainTip := gitRun(t, a, "rev-parse", "main")
+	outcomes = run(t, ActionPush, analyze(t, a), false)
+	expectOutcomes(t, outcomes, "topic skipped")
+	if outcomes[0].Detail != "upstream is a local branch" {
+		t.Errorf("Detail = %q", outcomes[0].Detail)
+	}
+	if tip := gitRun(t, a, "rev-parse", "main"); tip != mainTip {
+		t.Errorf("main moved to %s", tip)
+	}
+
+	// git push refuses a branch whose upstream has another name under
+	// push.default simple
+	gitRun(t, a, "branch", "--quiet", "-D", "topic")
+	gitRun(t, a, "config", "push.default", "simple")
+	gitRun(t, a, "checkout", "--quiet", "-b", "renamed", "--track", "origin/main")
+	gitRun(t, a, "commit", "--quiet", "--allow-empty", "-m", "Renamed work")
+	gitRun(t, a, "checkout", "--quiet", "main")
+	outcomes = run(t, ActionPush, analyze(t, a), false)
+	expectOutcomes(t, outcomes, "renamed skipped")
+	if !strings.Contains(outcomes[0].Detail, "different name") {
+		t.Errorf("Detail = %q", outcomes[0].Detail)
+	}
+}
+
+func TestPushTriangular(t *testing.T) {
+	_, a, _ := setupClones(t)
+	fork := filepath.Join(filepath.Dir(a), "fork.git")
+	gitRun(t, a, "init", "--quiet", "--bare", fork)
+	gitRun(t, a, "remote", "add", "fork", fork)
+	gitRun(t, a, "config", "remote.pushDefault", "fork")
+	// Older git cannot resolve @{push} of a simple push to another remote
+	gitRun(t, a, "config", "push.default", "current")
+
+	// feature tracks origin/main and was never pushed to the fork
+	gitRun(t, a, "checkout", "--quiet", "-b", "feature", "--track", "origin/main")
+	gitRun(t, a, "commit", "--quiet", "--allow-empty", "-m", "Feature work")
+	gitRun(t, a, "checkout", "--quiet", "main")
+
+	outcomes := run(t, ActionPush, analyze(t, a), true)
+	expectOutcomes(t, outcomes, "feature dry-run")
+	if outcomes[0].Detail != "push to fork/feature, not pushed there yet" {
+		t.Errorf("Detail = %q", outcomes[0].Detail)
+	}
+
+	outcomes = run(t, ActionPush, analyze(t, a), false)
+	expectOutcomes(t, outcomes, "feature done")
+	if got, want := gitRun(t, fork, "rev-parse", "feature"), gitRun(t, a, "rev-parse", "feature"); got != want {
+		t.Errorf("Fork feature at %s, want %s", got, want)
+	}
+	if got := gitRun(t, a, "rev-parse", "origin/main"); got == gitRun(t, a, "rev-parse", "feature") {
+		t.Error("Pushed to the upstream instead of the fork")
+	}
+
+	// Level with its upstream but ahead of the fork: pushed to the fork
+	gitRun(t, a, "fetch", "--quiet", "fork")
+	gitRun(t, a, "branch", "--quiet", "--force", "feature", "main")
+	gitRun(t, a, "push", "--quiet", "--force", "fork", "main:feature")
+	gitRun(t, a, "checkout", "--quiet", "feature")
+	gitRun(t, a, "commit", "--quiet", "--allow-empty", "-m", "More work")
+	gitRun(t, a, "push", "--quiet", "origin", "feature:main")
+	gitRun(t, a, "checkout", "--quiet", "main")
+	gitRun(t, a, "fetch", "--quiet", "--all")
+
+	outcomes = run(t, ActionPush, analyze(t, a), true)
+	expectOutcomes(t, outcomes, "feature dry-run")
+	if outcomes[0].Detail != "push 1 commit to fork/feature" {
+		t.Errorf("Detail = %q", outcomes[0].Detail)
+	}
+}
+
+func TestPruneGone(t *testing.T) {
+	_, a, _ := setupClones(t)
+	for _, branch := range []string{"merged", "unmerged"} {
+		gitRun(t, a, "checkout", "--quiet", "-b", branch, "main")
+		gitRun(t, a, "commit", "--quiet", "--allow-empty", "-m", "Work on "+branch)
+		gitRun(t, a, "push", "--quiet", "-u", "origin", branch)
+	}
+	gitRun(t, a, "checkout", "--quiet", "main")
+	gitRun(t, a, "merge", "--quiet", "--ff-only", "merged")
+	gitRun(t, a, "push", "--quiet", "origin", "--delete", "merged", "unmerged")
+	gitRun(t, a, "fetch", "--quiet", "--prune")
+
+	outcomes := run(t, ActionPruneGone, analyze(t, a), true)
+	expectOutcomes(t, outcomes, "merged dry-run", "unmerged skipped")
+
+	outcomes = run(t, ActionPruneGone, analyze(t, a), false)
+	expectOutcomes(t, outcomes, "merged done", "unmerged skipped")
+	if branches := gitRun(t, a, "branch", "--format=%(refname:short)"); branches != "main\nunmerged" {
+		t.Errorf("Branches after prune-gone: %q", branches)
+	}
+}
+
+func TestReport(t *testing.T) {
+	repo := types.RepoResult{Path: "/src/app"}
+	outcomes := []Outcome{
+		{Repo: repo, Branch: "main", Status: StatusDone, Detail: "fast-forward 2 commits"},
+		{Repo: repo, Branch: "dev", Status: StatusDryRun, Detail: "push 1 commit to origin/dev"},
+		{Repo: repo, Branch: "old", Status: StatusSkipped, Detail: "checked out"},
+		{Repo: repo, Branch: "x", Status: StatusFailed, Detail: "git push failed: rejected"},
+	}
+
+	want := []string{
+		"/src/app main: done, fast-forward 2 commits",
+		"/src/app dev: would push 1 commit to origin/dev",
+		"/src/app old: skipped, checked out",
+		"/src/app x: failed, git push failed: rejected",
+	}
+	for i, o := range outcomes {
+		if got := reportLine(o, types.Config{NoColor: true}); got != want[i] {
+			t.Errorf("reportLine = %q, want %q", got, want[i])
+		}
+	}
+
+	if got := reportTotals(outcomes); got != "1 done, 1 would run, 1 skipped, 1 failed" {
+		t.Errorf("reportTotals = %q", got)
+	}
+	if got := reportTotals(nil); got != "Nothing to do" {
+		t.Errorf("reportTotals(nil) = %q", got)
+	}
+	if !Failed(outcomes) || Failed(outcomes[:3]) {
+		t.Error("Failed should only report outcomes with a failed step")
+	}
+}
@@ -0,0 +1,79 @@
+package actions
+
+import (
+	"fmt"
+	"strings"
+
+	"gitstatus/src/output"
+	"gitstatus/src/types"
+)
+
+// reportLine formats an outcome, e.g. "~/src/app main: done, fast-forward 3 commits"
+// or, in a dry run, "~/src/app main: would fast-forward 3 commits"
+func reportLine(o Outcome, cfg types.Config) string {
+	var text, color string
+	switch o.Status {
+	case StatusDone:
+		text, color = "done, "+o.Detail, output.ColorGreen
+	case StatusDryRun:
+		text, color = "would "+o.Detail, output.ColorCyan
+	case StatusSkipped:
+		text, color = "skipped, "+o.Detail, output.ColorYellow
+	case StatusFailed:
+		text, color = "failed, "+o.Detail, output.ColorRed
+	}
+
+	line := fmt.Sprintf("%s %s: %s", output.DisplayPath(o.Repo, cfg), o.Branch, text)
+	if cfg.NoColor {
+		return line
+	}
+	return color + line + output.ColorReset
+}
+
+// reportTotals counts outcomes by status, e.g. "2 done, 1 skipped"
+func reportTotals(outcomes []Outcome) string {
+	counts := make(map[Status]int)
+	for _, o := range outcomes {
+		counts[o.Status]++
+	}
+
+	var parts []string
+	for _, s := range []struct {
+		status Status
+		label  string
+	}{
+		{StatusDone, "done"},
+		{StatusDryRun, "would run"},
+		{StatusSkipped, "skipped"},
+		{StatusFailed, "failed"},
+	} {
+		if counts[s.status] > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", counts[s.status], s.label))
+		}
+	}
+	if len(parts) == 0 {
+		return "Nothing to do"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// PrintReport prints a line per outcome followed by the totals
+func PrintReport(outcomes []Outcome, cfg types.Config) {
+	for _, o := range outcomes {
+		fmt.Println(reportLine(o, cfg))
+	}
+	if len(outcomes) > 0 {
+		fmt.Println()
+	}
+	fmt.Println(reportTotals(outcomes))
+}
+
+// Failed reports whether any step of the action failed
+func Failed(outcomes []Outcome) bool {
+	for _, o := range outcomes {
+		if o.Status == StatusFailed {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,195 @@
+package config
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"gitstatus/src/defaults"
+)
+
+// EnvPrefix is prepended to a setting's upper-cased name to form its
+// environment variable, e.g. depth -> GITSTATUS_DEPTH, no-color -> GITSTATUS_NO_COLOR
+const EnvPrefix = "GITSTATUS_"
+
+// Setting is a single key = value pair together with where it came from.
+// Keys are the names of the command line flags they set.
+type Setting struct {
+	Key    string
+	Value  string
+	Source string
+}
+
+// UserConfigPath returns the per-user config file location:
+// $XDG_CONFIG_HOME/gitstatus/config, falling back to ~/.config/gitstatus/config.
+func UserConfigPath() string {
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return filepath.Join(dir, defaults.DefaultUserConfigFile)
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".config", defaults.DefaultUserConfigFile)
+}
+
+// RootConfigPath returns the location of the optional config file in a scan root
+func RootConfigPath(root string) string {
+	return filepath.Join(root, defaults.DefaultRootConfigFile)
+}
+
+// RootKeys are the settings a scan root's config file may set. A root may be
+// a checkout of someone else's repository, so it can only narrow down what is
+// scanned, never write files, talk to remotes or change how repositories are
+// read.
+var RootKeys = []string{"depth", "exclude", "include", "ignore"}
+
+// ReportKeys are settings that shape the whole report rather than the scan of
+// one root, so a root's config file cannot set them even when it is the only
+// root scanned.
+var ReportKeys = []string{"format", "no-color", "log", "logfile"}
+
+// ReadRootFile reads the optional config file of a scan root, rejecting
+// settings other than RootKeys
+func ReadRootFile(root string) ([]Setting, error) {
+	settings, err := ReadFile(RootConfigPath(root), false)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range settings {
+		switch {
+		case contains(ReportKeys, s.Key):
+			return nil, fmt.Errorf("%s: %q applies to the whole report and cannot be set per scan root; set it in the user config file, the environment or on the command line", s.Source, s.Key)
+		case !contains(RootKeys, s.Key):
+			return nil, fmt.Errorf("%s: %q cannot be set in a scan root (only %s)", s.Source, s.Key, strings.Join(RootKeys, ", "))
+		case s.Key == "depth":
+			if depth, err := strconv.Atoi(s.Value); err != nil || depth < 0 {
+				return nil, fmt.Errorf("%s: invalid value %q for depth: expected a number of directories, 0 for unlimited", s.Source, s.Value)
+			}
+		}
+	}
+	return settings, nil
+}
+
+// IgnoredDirs returns the directory names to skip: defaults.DefaultIgnoredDirs
+// changed by each comma-separated entry of lists in turn, where "name" adds a
+// name and "!name" removes it again. Every place ignore is set, from the user
+// config to a scan root's config file, adds to the same list this way.
+func IgnoredDirs(lists ...string) []string {
+	dirs := append([]string(nil), defaults.DefaultIgnoredDirs...)
+	for _, list := range lists {
+		for _, name := range strings.Split(list, ",") {
+			name = strings.TrimSpace(name)
+			if removed, ok := strings.CutPrefix(name, "!"); ok {
+				kept := dirs[:0]
+				for _, dir := range dirs {
+					if dir != removed {
+						kept = append(kept, dir)
+					}
+				}
+				dirs = kept
+			} else if name != "" && !contains(dirs, name) {
+				dirs = append(dirs, name)
+			}
+		}
+	}
+	return dirs
+}
+
+// ReadFile parses a config file made of "key = value" lines. Blank lines and
+// lines starting with # are ignored. A missing file yields no settings unless
+// required is set.
+func ReadFile(path string, required bool) ([]Setting, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) && !required {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	var settings []Setting
+	scanner := bufio.NewScanner(f)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("%s:%d: expected key = value, got %q", path, lineNum, line)
+		}
+
+		settings = append(settings, Setting{
+			Key:    strings.ToLower(strings.TrimSpace(key)),
+			Value:  strings.TrimSpace(value),
+			Source: fmt.Sprintf("%s:%d", path, lineNum),
+		})
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return settings, nil
+}
+
+// ReadEnv returns a setting for every flag in fs whose GITSTATUS_ variable is
+// set, except for the flags listed in skip.
+func ReadEnv(fs *flag.FlagSet, skip ...string) []Setting {
+	var settings []Setting
+	fs.VisitAll(func(f *flag.Flag) {
+		if contains(skip, f.Name) {
+			return
+		}
+		name := EnvName(f.Name)
+		if value, ok := os.LookupEnv(name); ok {
+			settings = append(settings, Setting{Key: f.Name, Value: value, Source: "environment " + name})
+		}
+	})
+	return settings
+}
+
+// EnvName returns the environment variable that sets the flag called key
+func EnvName(key string) string {
+	return EnvPrefix + strings.ToUpper(strings.ReplaceAll(key, "-", "_"))
+}
+
+// Apply sets flags in fs from settings, in order, so later settings override
+// earlier ones. Flags given explicitly on the command line always win, and
+// settings listed in skip cannot be set from config at all.
+func Apply(fs *flag.FlagSet, settings []Setting, skip ...string) error {
+	explicit := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) {
+		explicit[f.Name] = true
+	})
+
+	for _, s := range settings {
+		if fs.Lookup(s.Key) == nil || contains(skip, s.Key) {
+			return fmt.Errorf("%s: unknown setting %q", s.Source, s.Key)
+		}
+		if explicit[s.Key] {
+			continue
+		}
+		if err := fs.Set(s.Key, s.Value); err != nil {
+			return fmt.Errorf("%s: invalid value %q for %s: %w", s.Source, s.Value, s.Key, err)
+		}
+	}
+	return nil
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,188 @@
+package config
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newTestFlagSet() (*flag.FlagSet, *int, *string, *bool) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	depth := fs.Int("depth", 0, "")
+	format := fs.String("format", "text", "")
+	noColor := fs.Bool("no-color", false, "")
+	fs.String("config", "", "")
+	return fs, depth, format, noColor
+}
+
+func writeFile(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "config")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestReadFile(t *testing.T) {
+	path := writeFile(t, "# comment\n\ndepth = 3\n  Format=json  \nignore = node_modules, vendor\n")
+
+	settings, err := ReadFile(path, true)
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+
+	want := []Setting{
+		{Key: "depth", Value: "3", Source: path + ":3"},
+		{Key: "format", Value: "json", Source: path + ":4"},
+		{Key: "ignore", Value: "node_modules, vendor", Source: path + ":5"},
+	}
+	if len(settings) != len(want) {
+		t.Fatalf("Expected %d settings, got %+v", len(want), settings)
+	}
+	for i := range want {
+		if settings[i] != want[i] {
+			t.Errorf("Setting %d = %+v, want %+v", i, settings[i], want[i])
+		}
+	}
+}
+
+func TestReadFileErrors(t *testing.T) {
+	missing := filepath.Join(t.TempDir(), "missing")
+	if settings, err := ReadFile(missing, false); err != nil || settings != nil {
+		t.Errorf("Optional missing file: got %v, %v", settings, err)
+	}
+	if _, err := ReadFile(missing, true); err == nil {
+		t.Error("Expected error for missing required file")
+	}
+
+	path := writeFile(t, "depth 3\n")
+	if _, err := ReadFile(path, true); err == nil || !strings.Contains(err.Error(), ":1:") {
+		t.Errorf("Expected error with line number, got %v", err)
+	}
+}
+
+func TestReadRootFile(t *testing.T) {
+	root := t.TempDir()
+	if settings, err := ReadRootFile(root); err != nil || settings != nil {
+		t.Errorf("Missing root file: got %v, %v", settings, err)
+	}
+
+	path := RootConfigPath(root)
+	if err := os.WriteFile(path, []byte("exclude = archive\ninclude = services/*\nignore = build\ndepth = 2\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if settings, err := ReadRootFile(root); err != nil || len(settings) != 4 {
+		t.Errorf("ReadRootFile = %+v, %v; want 4 settings", settings, err)
+	}
+
+	tests := []struct {
+		line string
+		want string
+	}{
+		{"fetch = true", "cannot be set in a scan root"},
+		{"backend = native", "cannot be set in a scan root"},
+		{"logfile = /tmp/x", "applies to the whole report"},
+		{"format = json", "applies to the whole report"},
+		{"no-color = true", "applies to the whole report"},
+		{"depth = -1", "invalid value"},
+		{"depth = deep", "invalid value"},
+	}
+	for _, tt := range tests {
+		if err := os.WriteFile(path, []byte("exclude = archive\n"+tt.line+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		_, err := ReadRootFile(root)
+		if err == nil || !strings.Contains(err.Error(), path+":2") || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("ReadRootFile with %q = %v, want an error containing %q", tt.line, err, tt.want)
+		}
+	}
+}
+
+func TestIgnoredDirs(t *testing.T) {
+	tests := []struct {
+		lists []string
+		add   []string
+		drop  []string
+	}{
+		{nil, nil, nil},
+		{[]string{"archive, cache"}, []string{"archive", "cache"}, nil},
+		{[]string{"archive", "!node_modules,!vendor"}, []string{"archive"}, []string{"node_modules", "vendor"}},
+		{[]string{"!dist", "dist"}, []string{"dist"}, nil},
+	}
+	for _, tt := range tests {
+		got := IgnoredDirs(tt.lists...)
+		has := make(map[string]bool)
+		for _, name := range got {
+			has[name] = true
+		}
+		for _, name := range append(tt.add, ".git", "build") {
+			if !has[name] {
+				t.Errorf("IgnoredDirs(%q) = %v, missing %s", tt.lists, got, name)
+			}
+		}
+		for _, name := range tt.drop {
+			if has[name] {
+				t.Errorf("IgnoredDirs(%q) = %v, should not contain %s", tt.lists, got, name)
+			}
+		}
+	}
+}
+
+func TestApplyPrecedence(t *testing.T) {
+	fs, depth, format, noColor := newTestFlagSet()
+	if err := fs.Parse([]string{"-format", "text"}); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("GITSTATUS_DEPTH", "5")
+	t.Setenv("GITSTATUS_CONFIG", "/ignored")
+
+	settings := []Setting{
+		{Key: "depth", Value: "2", Source: "user:1"},
+		{Key: "no-color", Value: "true", Source: "user:2"},
+		{Key: "format", Value: "json", Source: "root:1"},
+		{Key: "depth", Value: "3", Source: "root:2"},
+	}
+	settings = append(settings, ReadEnv(fs, "config")...)
+
+	if err := Apply(fs, settings, "config"); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+
+	if *depth != 5 {
+		t.Errorf("depth = %d, want 5 from environment", *depth)
+	}
+	if *format != "text" {
+		t.Errorf("format = %q, want text from command line", *format)
+	}
+	if !*noColor {
+		t.Error("no-color should be set from the user file")
+	}
+}
+
+func TestApplyErrors(t *testing.T) {
+	fs, _, _, _ := newTestFlagSet()
+
+	err := Apply(fs, []Setting{{Key: "colour", Value: "yes", Source: "file:4"}})
+	if err == nil || !strings.Contains(err.Error(), "file:4") {
+		t.Errorf("Expected unknown setting error with source, got %v", err)
+	}
+
+	err = Apply(fs, []Setting{{Key: "depth", Value: "deep", Source: "file:1"}})
+	if err == nil {
+		t.Error("Expected error for invalid int value")
+	}
+
+	err = Apply(fs, []Setting{{Key: "config", Value: "other", Source: "file:2"}}, "config")
+	if err == nil {
+		t.Error("Expected config to be rejected inside a config file")
+	}
+}
+
+func TestEnvName(t *testing.T) {
+	if got := EnvName("no-color"); got != "GITSTATUS_NO_COLOR" {
+		t.Errorf("EnvName(no-color) = %q", got)
+	}
+}
@@ -1,5 +1,7 @@
 package defaults
 
+import "runtime"
+
 // DirsToSkip contains directories that should be skipped during traversal.
 // You can add or remove directories here to customize the scanning process.
 var DefaultIgnoredDirs = []string{
@@ -15,6 +17,15 @@ var DefaultIgnoredDirs = []string{
 	".sass-cache",
 }
 
+// DefaultUserConfigFile is the per-user config file, relative to $XDG_CONFIG_HOME or ~/.config
+const DefaultUserConfigFile = "gitstatus/config"
+
+// DefaultRootConfigFile is the optional config file read from the scan root
+const DefaultRootConfigFile = ".gitstatus"
+
+// DefaultIgnoreFile holds gitignore-style exclude patterns, read from the scan root
+const DefaultIgnoreFile = ".gitstatusignore"
+
 // DefaultLogFile is the default name for the log file
 const DefaultLogFile = "gitstatus.log"
 
@@ -23,3 +34,27 @@ const DefaultMaxDepth = 0
 
 // DefaultGitCommandTimeoutSeconds is the timeout in seconds for git commands
 const DefaultGitCommandTimeoutSeconds = 5
+
+// DefaultGitFetchTimeoutSeconds is the timeout in seconds for git fetch with -fetch
+const DefaultGitFetchTimeoutSeconds = 30
+
+// DefaultJobs is the default number of repositories analyzed in parallel
+var DefaultJobs = runtime.NumCPU()
+
+// DefaultOutputFormat is the output format used when -format is not given
+const DefaultOutputFormat = "text"
+
+// DefaultColumns lists the columns of -format table, in order
+const DefaultColumns = "path,branch,ahead,behind,upstream,modified,staged,untracked,stashes,tags,age"
+
+// DefaultCommitLimit is the most commits listed per repository with -commits
+const DefaultCommitLimit = 10
+
+// DefaultSort is the order of the -format table rows used when -sort is not given
+const DefaultSort = "path"
+
+// DefaultBackend is the git backend used when -backend is not given
+const DefaultBackend = "exec"
+
+// DefaultFailOn lists the conditions that cause a non-zero exit code
+const DefaultFailOn = "ahead,behind,gone,no-upstream,dirty,stash,in-progress,detached,tags,error"
@@ -0,0 +1,144 @@
+package exitcode
+
+import (
+	"fmt"
+	"strings"
+
+	"gitstatus/src/types"
+)
+
+// Process exit codes
+const (
+	Clean       = 0   // no repository matched any -fail-on condition
+	Attention   = 1   // unsynced branches or uncommitted work found
+	Error       = 2   // errors occurred while scanning, or invalid usage
+	Interrupted = 130 // scan stopped by SIGINT/SIGTERM
+)
+
+// Conditions accepted by -fail-on
+const (
+	ConditionAhead      = "ahead"
+	ConditionBehind     = "behind"
+	ConditionGone       = "gone"
+	ConditionNoUpstream = "no-upstream"
+	ConditionDirty      = "dirty"
+	ConditionStash      = "stash"
+	ConditionInProgress = "in-progress"
+	ConditionDetached   = "detached"
+	ConditionTags       = "tags"
+	ConditionError      = "error"
+)
+
+// Conditions lists every condition accepted by -fail-on
+var Conditions = []string{
+	ConditionAhead,
+	ConditionBehind,
+	ConditionGone,
+	ConditionNoUpstream,
+	ConditionDirty,
+	ConditionStash,
+	ConditionInProgress,
+	ConditionDetached,
+	ConditionTags,
+	ConditionError,
+}
+
+// ParseFailOn parses a comma-separated list of conditions
+func ParseFailOn(s string) ([]string, error) {
+	var failOn []string
+	for _, c := range strings.Split(s, ",") {
+		c = strings.ToLower(strings.TrimSpace(c))
+		if c == "" {
+			continue
+		}
+		if !isCondition(c) {
+			return nil, fmt.Errorf("unknown -fail-on condition %q (expected one of: %s)", c, strings.Join(Conditions, ", "))
+		}
+		failOn = append(failOn, c)
+	}
+	return failOn, nil
+}
+
+// Evaluate returns the exit code for results. Error wins over Attention when
+// both kinds of conditions are present.
+func Evaluate(results []types.RepoResult, failOn []string) int {
+	enabled := make(map[string]bool)
+	for _, c := range failOn {
+		enabled[c] = true
+	}
+
+	code := Clean
+	for _, res := range results {
+		for _, c := range repoConditions(res) {
+			if !enabled[c] {
+				continue
+			}
+			if c == ConditionError {
+				return Error
+			}
+			code = Attention
+		}
+	}
+	return code
+}
+
+// repoConditions returns every condition that applies to res
+func repoConditions(res types.RepoResult) []string {
+	var conditions []string
+
+	if res.Error != nil || res.FetchError != nil {
+		conditions = append(conditions, ConditionError)
+	}
+	if res.HasUncommitted {
+		conditions = append(conditions, ConditionDirty)
+	}
+	if res.HasStash {
+		conditions = append(conditions, ConditionStash)
+	}
+	if res.HasOperation {
+		conditions = append(conditions, ConditionInProgress)
+	}
+	if res.HasDetachedHead && res.DetachedHead.Orphaned > 0 {
+		conditions = append(conditions, ConditionDetached)
+	}
+	if res.HasUnpushedTags {
+		conditions = append(conditions, ConditionTags)
+	}
+
+	for _, b := range res.Branches {
+		if b.Ahead > 0 || b.PushAhead > 0 {
+			conditions = append(conditions, ConditionAhead)
+		}
+		if b.Behind > 0 || b.PushBehind > 0 || behindRemote(b) {
+			conditions = append(conditions, ConditionBehind)
+		}
+		if b.Gone {
+			conditions = append(conditions, ConditionGone)
+		}
+		if b.NoUpstream {
+			conditions = append(conditions, ConditionNoUpstream)
+		}
+	}
+
+	return conditions
+}
+
+func isCondition(c string) bool {
+	for _, known := range Conditions {
+		if c == known {
+			return true
+		}
+	}
+	return false
+}
+
+// behindRemote reports whether b is behind one of the other remotes it was
+// compared with
+func behindRemote(b types.BranchSyncStatus) bool {
+	for _, r := range b.Remotes {
+		if r.Behind > 0 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,114 @@
+package exitcode
+
+import (
+	"errors"
+	"testing"
+
+	"gitstatus/src/types"
+)
+
+func TestParseFailOn(t *testing.T) {
+	failOn, err := ParseFailOn("ahead, Behind,,dirty")
+	if err != nil {
+		t.Fatalf("ParseFailOn failed: %v", err)
+	}
+	want := []string{ConditionAhead, ConditionBehind, ConditionDirty}
+	if len(failOn) != len(want) {
+		t.Fatalf("Expected %v, got %v", want, failOn)
+	}
+	for i := range want {
+		if failOn[i] != want[i] {
+			t.Errorf("Condition %d = %q, want %q", i, failOn[i], want[i])
+		}
+	}
+
+	if _, err := ParseFailOn("ahead,sideways"); err == nil {
+		t.Error("Expected error for unknown condition")
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	clean := types.RepoResult{Path: "/clean"}
+	ahead := types.RepoResult{
+		Path:        "/ahead",
+		HasUnsynced: true,
+		Branches:    []types.BranchSyncStatus{{Name: "main", Ahead: 1}},
+	}
+	dirty := types.RepoResult{
+		Path:           "/dirty",
+		Uncommitted:    types.WorkdirStatus{Modified: 1},
+		HasUncommitted: true,
+	}
+	stashed := types.RepoResult{
+		Path:     "/stashed",
+		Stash:    types.StashStatus{Count: 1},
+		HasStash: true,
+	}
+	rebasing := types.RepoResult{
+		Path:         "/rebasing",
+		Operation:    types.OperationState{Operation: types.OperationRebase, Step: 1, Total: 2},
+		HasOperation: true,
+	}
+	orphaned := types.RepoResult{
+		Path:            "/orphaned",
+		DetachedHead:    types.DetachedHead{Commit: "1a2b3c4", From: "v1.0", Moved: true, Orphaned: 2},
+		HasDetachedHead: true,
+	}
+	detached := types.RepoResult{
+		Path:            "/detached",
+		DetachedHead:    types.DetachedHead{Commit: "1a2b3c4", From: "v1.0"},
+		HasDetachedHead: true,
+	}
+	tagged := types.RepoResult{
+		Path:            "/tagged",
+		UnpushedTags:    []string{"v1.1"},
+		HasUnpushedTags: true,
+	}
+	forkBehind := types.RepoResult{
+		Path:        "/fork",
+		HasUnsynced: true,
+		Branches: []types.BranchSyncStatus{{Name: "main", Upstream: "origin/main",
+			Remotes: []types.RemoteSyncStatus{{Ref: "upstream/main", Remote: "upstream", Behind: 3}}}},
+	}
+	unpushedToFork := types.RepoResult{
+		Path:        "/triangular",
+		HasUnsynced: true,
+		Branches:    []types.BranchSyncStatus{{Name: "main", Upstream: "origin/main", Push: "fork/main", PushAhead: 2}},
+	}
+	failed := types.RepoResult{Path: "/failed", Error: errors.New("git command failed")}
+	fetchFailed := types.RepoResult{Path: "/fetch", FetchError: errors.New("remote unreachable")}
+
+	tests := []struct {
+		name    string
+		results []types.RepoResult
+		failOn  []string
+		want    int
+	}{
+		{"AllClean", []types.RepoResult{clean}, Conditions, Clean},
+		{"Ahead", []types.RepoResult{clean, ahead}, Conditions, Attention},
+		{"AheadNotSelected", []types.RepoResult{ahead}, []string{ConditionBehind, ConditionDirty}, Clean},
+		{"Dirty", []types.RepoResult{dirty}, []string{ConditionDirty}, Attention},
+		{"Stash", []types.RepoResult{stashed}, Conditions, Attention},
+		{"StashNotSelected", []types.RepoResult{stashed}, []string{ConditionDirty}, Clean},
+		{"InProgress", []types.RepoResult{rebasing}, []string{ConditionInProgress}, Attention},
+		{"DetachedOrphaned", []types.RepoResult{orphaned}, []string{ConditionDetached}, Attention},
+		{"DetachedNoOrphans", []types.RepoResult{detached}, Conditions, Clean},
+		{"BehindOtherRemote", []types.RepoResult{forkBehind}, []string{ConditionBehind}, Attention},
+		{"AheadOfPushDestination", []types.RepoResult{unpushedToFork}, []string{ConditionAhead}, Attention},
+		{"PushDestinationNotBehind", []types.RepoResult{unpushedToFork}, []string{ConditionBehind}, Clean},
+		{"Tags", []types.RepoResult{tagged}, Conditions, Attention},
+		{"TagsNotSelected", []types.RepoResult{tagged}, []string{ConditionAhead}, Clean},
+		{"ErrorWins", []types.RepoResult{ahead, failed}, Conditions, Error},
+		{"FetchErrorIsError", []types.RepoResult{fetchFailed}, []string{ConditionError}, Error},
+		{"ErrorNotSelected", []types.RepoResult{failed, dirty}, []string{ConditionDirty}, Attention},
+		{"NothingSelected", []types.RepoResult{ahead, dirty, failed}, nil, Clean},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Evaluate(tt.results, tt.failOn); got != tt.want {
+				t.Errorf("Evaluate = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,20 @@
+package git
+
+// Backends accepted in types.Config.Backend
+const (
+	BackendExec   = "exec"   // run git for every query
+	BackendNative = "native" // read refs and objects directly, falling back to exec
+)
+
+// Backends lists every supported backend
+var Backends = []string{BackendExec, BackendNative}
+
+// IsValidBackend reports whether backend names a supported backend
+func IsValidBackend(backend string) bool {
+	for _, b := range Backends {
+		if b == backend {
+			return true
+		}
+	}
+	return false
+}
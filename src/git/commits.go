@@ -0,0 +1,134 @@
+package git
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"gitstatus/src/defaults"
+	"gitstatus/src/logger"
+	"gitstatus/src/types"
+)
+
+// commitLogFormat separates the fields of a commit with the unit separator,
+// which cannot appear in author names or subjects
+const commitLogFormat = "--format=%h%x1f%an%x1f%ct%x1f%s"
+
+// GetBranchCommits lists the commits of branch that are not on its upstream
+// (ahead) and the commits of the upstream that are not on branch (behind),
+// newest first. limit caps the length of each list, 0 means no limit.
+func GetBranchCommits(ctx context.Context, path string, branch string, limit int, logger *logger.Logger) ([]types.Commit, []types.Commit, error) {
+	logger.Debug("Listing commits of %s against its upstream in: %s", branch, path)
+
+	// <branch>@{upstream} always names a branch, even if a tag has the same name
+	local := "refs/heads/" + branch
+	upstream := branch + "@{upstream}"
+
+	ahead, err := getCommits(ctx, path, upstream+".."+local, limit, logger)
+	if err != nil {
+		return nil, nil, err
+	}
+	behind, err := getCommits(ctx, path, local+".."+upstream, limit, logger)
+	if err != nil {
+		return nil, nil, err
+	}
+	return ahead, behind, nil
+}
+
+// getCommits runs git log over revRange
+func getCommits(ctx context.Context, path string, revRange string, limit int, logger *logger.Logger) ([]types.Commit, error) {
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(defaults.DefaultGitCommandTimeoutSeconds)*time.Second)
+	defer cancel()
+
+	args := []string{"log", commitLogFormat}
+	if limit > 0 {
+		args = append(args, "--max-count="+strconv.Itoa(limit))
+	}
+	args = append(args, revRange, "--")
+
+	output, err := runGit(ctx, path, nil, args...)
+	if err != nil {
+		return nil, fmt.Errorf("git log failed: %w", err)
+	}
+
+	return parseCommitLog(string(output), logger)
+}
+
+// parseCommitLog parses git log output in commitLogFormat
+func parseCommitLog(output string, logger *logger.Logger) ([]types.Commit, error) {
+	var commits []types.Commit
+	scanner := newLineScanner(output)
+
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			continue
+		}
+
+		fields := strings.SplitN(line, "\x1f", 4)
+		if len(fields) != 4 {
+			logger.Debug("Skipping log line (format mismatch): %s", line)
+			continue
+		}
+
+		commit := types.Commit{Hash: fields[0], Author: fields[1], Subject: fields[3]}
+		if seconds, err := strconv.ParseInt(fields[2], 10, 64); err == nil {
+			commit.Time = time.Unix(seconds, 0)
+		} else {
+			logger.Error("Failed to parse commit timestamp '%s': %v", fields[2], err)
+		}
+		commits = append(commits, commit)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading git log output: %w", err)
+	}
+
+	return commits, nil
+}
+
+// GetChangedFiles lists the files with uncommitted changes, including
+// untracked files, in the order git status reports them
+func GetChangedFiles(ctx context.Context, path string, logger *logger.Logger) ([]types.FileChange, error) {
+	logger.Debug("Listing changed files in: %s", path)
+
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(defaults.DefaultGitCommandTimeoutSeconds)*time.Second)
+	defer cancel()
+
+	output, err := runGit(ctx, path, []string{"GIT_OPTIONAL_LOCKS=0"}, "status", "--porcelain=v1", "-z")
+	if err != nil {
+		return nil, fmt.Errorf("git status failed: %w", err)
+	}
+
+	return parseChangedFiles(string(output), logger), nil
+}
+
+// parseChangedFiles parses git status --porcelain=v1 -z. Entries are
+// NUL-terminated "XY path", and renames and copies are followed by an extra
+// entry holding the source path.
+func parseChangedFiles(output string, logger *logger.Logger) []types.FileChange {
+	var files []types.FileChange
+	entries := strings.Split(output, "\x00")
+
+	for i := 0; i < len(entries); i++ {
+		entry := entries[i]
+		if entry == "" {
+			continue
+		}
+		if len(entry) < 4 || entry[2] != ' ' {
+			logger.Debug("Skipping status entry (format mismatch): %q", entry)
+			continue
+		}
+
+		file := types.FileChange{Status: entry[:2], Path: entry[3:]}
+		if (entry[0] == 'R' || entry[0] == 'C') && i+1 < len(entries) {
+			i++
+			file.OrigPath = entries[i]
+		}
+		files = append(files, file)
+	}
+
+	return files
+}
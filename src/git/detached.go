@@ -0,0 +1,59 @@
+package git
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"gitstatus/src/defaults"
+	"gitstatus/src/logger"
+	"gitstatus/src/types"
+)
+
+// Regex to parse the detached HEAD line of git branch -vv:
+// * (HEAD detached at origin/main) a1b2c3d Commit message
+// Groups: 1=at or from, 2=Ref or commit, 3=Commit
+var detachedLineRegex = regexp.MustCompile(`^\*\s+\(HEAD detached (at|from) ([^)]+)\)\s+(\w+)`)
+
+// parseDetachedHead finds the detached HEAD line in git branch -vv output.
+// Lines like "(no branch, rebasing main)" written while an operation is in
+// progress are not reported, the operation state covers them.
+func parseDetachedHead(output string, logger *logger.Logger) (types.DetachedHead, bool) {
+	scanner := newLineScanner(output)
+	for scanner.Scan() {
+		matches := detachedLineRegex.FindStringSubmatch(scanner.Text())
+		if matches == nil {
+			continue
+		}
+
+		head := types.DetachedHead{
+			Commit: matches[3],
+			From:   matches[2],
+			Moved:  matches[1] == "from",
+		}
+		logger.Debug("Parsed detached HEAD: %s (From: %s, Moved: %v)", head.Commit, head.From, head.Moved)
+		return head, true
+	}
+	return types.DetachedHead{}, false
+}
+
+// countOrphanedCommits counts the commits reachable from HEAD but from no
+// branch, tag or remote-tracking ref, which git gc may eventually delete.
+func countOrphanedCommits(ctx context.Context, path string) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(defaults.DefaultGitCommandTimeoutSeconds)*time.Second)
+	defer cancel()
+
+	output, err := runGit(ctx, path, nil, "rev-list", "--count", "HEAD", "--not", "--branches", "--tags", "--remotes")
+	if err != nil {
+		return 0, fmt.Errorf("git rev-list failed: %w", err)
+	}
+
+	count, err := strconv.Atoi(strings.TrimSpace(string(output)))
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse commit count '%s': %w", strings.TrimSpace(string(output)), err)
+	}
+	return count, nil
+}
@@ -0,0 +1,182 @@
+package git
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"gitstatus/src/defaults"
+	"gitstatus/src/logger"
+)
+
+// Fetch failures are wrapped around one of these so callers can tell them apart
+var (
+	ErrFetchAuth        = errors.New("authentication failed")
+	ErrFetchUnreachable = errors.New("remote unreachable")
+	ErrFetchTimeout     = errors.New("fetch timed out")
+	ErrFetchFailed      = errors.New("fetch failed")
+)
+
+// Substrings of git's output used to classify fetch failures
+var fetchAuthMessages = []string{
+	"Authentication failed",
+	"could not read Username",
+	"could not read Password",
+	"terminal prompts disabled",
+	"Permission denied",
+	"Host key verification failed",
+}
+
+var fetchUnreachableMessages = []string{
+	"Could not resolve host",
+	"Connection refused",
+	"Connection timed out",
+	"Network is unreachable",
+	"does not appear to be a git repository",
+	"Could not read from remote repository",
+	"unable to access",
+}
+
+// FetchRepo runs git fetch --all --prune in the repository at path. Git is
+// never allowed to prompt for credentials, so remotes that need interactive
+// authentication fail with ErrFetchAuth instead of blocking the scan.
+func FetchRepo(ctx context.Context, path string, logger *logger.Logger) error {
+	logger.Debug("Fetching remotes for repo: %s", path)
+
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(defaults.DefaultGitFetchTimeoutSeconds)*time.Second)
+	defer cancel()
+
+	output, err := runGit(ctx, path, nonInteractiveEnv(), "fetch", "--all", "--prune")
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return fmt.Errorf("%w after %ds", ErrFetchTimeout, defaults.DefaultGitFetchTimeoutSeconds)
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		logger.Debug("git fetch failed in %s. Error: %v. Output: %s", path, err, string(output))
+		return classifyFetchError(string(output))
+	}
+
+	logger.Debug("Fetch output for %s:\n%s", path, string(output))
+	return nil
+}
+
+// FetchGroup fetches each repository at most once, however many of its
+// worktrees ask for it. A linked worktree shares its refs and objects with the
+// main repository, and fetching both at the same time fails to lock refs. The
+// zero value is ready to use.
+type FetchGroup struct {
+	mu      sync.Mutex
+	fetches map[string]*groupFetch
+}
+
+// groupFetch is a fetch of one git common dir; err is set before done closes
+type groupFetch struct {
+	done chan struct{}
+	err  error
+}
+
+// Fetch runs FetchRepo for path, or waits for the fetch of another worktree
+// of the same repository started through g and returns its error.
+func (g *FetchGroup) Fetch(ctx context.Context, path string, logger *logger.Logger) error {
+	commonDir, err := GetCommonDir(ctx, path)
+	if err != nil {
+		logger.Debug("Could not resolve the common git dir of %s, fetching it alone: %v", path, err)
+		return FetchRepo(ctx, path, logger)
+	}
+
+	g.mu.Lock()
+	if g.fetches == nil {
+		g.fetches = make(map[string]*groupFetch)
+	}
+	fetch, started := g.fetches[commonDir]
+	if !started {
+		fetch = &groupFetch{done: make(chan struct{})}
+		g.fetches[commonDir] = fetch
+	}
+	g.mu.Unlock()
+
+	if started {
+		logger.Debug("Remotes of %s are fetched with %s", path, commonDir)
+		select {
+		case <-fetch.done:
+			return fetch.err
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	fetch.err = FetchRepo(ctx, path, logger)
+	close(fetch.done)
+	return fetch.err
+}
+
+// GetCommonDir returns the absolute directory holding the refs and objects of
+// the repository at path, which all of its worktrees share.
+func GetCommonDir(ctx context.Context, path string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(defaults.DefaultGitCommandTimeoutSeconds)*time.Second)
+	defer cancel()
+
+	output, err := runGit(ctx, path, nil, "rev-parse", "--git-common-dir")
+	if err != nil {
+		return "", fmt.Errorf("git rev-parse failed: %w", err)
+	}
+
+	dir := strings.TrimSpace(string(output))
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(path, dir)
+	}
+	if resolved, err := filepath.EvalSymlinks(dir); err == nil {
+		dir = resolved
+	}
+	return filepath.Clean(dir), nil
+}
+
+// nonInteractiveEnv returns the environment variables that disable every
+// credential prompt git or ssh could show.
+func nonInteractiveEnv() []string {
+	env := []string{
+		"GIT_TERMINAL_PROMPT=0",
+		"GIT_ASKPASS=",
+		"SSH_ASKPASS=",
+		"GCM_INTERACTIVE=never",
+	}
+	if os.Getenv("GIT_SSH_COMMAND") == "" {
+		env = append(env, "GIT_SSH_COMMAND=ssh -o BatchMode=yes")
+	}
+	return env
+}
+
+func classifyFetchError(output string) error {
+	detail := firstErrorLine(output)
+
+	for _, msg := range fetchAuthMessages {
+		if strings.Contains(output, msg) {
+			return fmt.Errorf("%w: %s", ErrFetchAuth, detail)
+		}
+	}
+	for _, msg := range fetchUnreachableMessages {
+		if strings.Contains(output, msg) {
+			return fmt.Errorf("%w: %s", ErrFetchUnreachable, detail)
+		}
+	}
+	return fmt.Errorf("%w: %s", ErrFetchFailed, detail)
+}
+
+// firstErrorLine returns the first fatal/error line of git's output
+func firstErrorLine(output string) string {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "fatal:") || strings.HasPrefix(line, "error:") {
+			return line
+		}
+	}
+	return strings.TrimSpace(lines[0])
+}
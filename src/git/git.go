@@ -4,7 +4,7 @@ import (
 	"bufio"
 	"context"
 	"fmt"
-	"os/exec"
+	"path/filepath"
 	"regexp"
 	"strconv"
 	"strings"
@@ -16,79 +16,274 @@ import (
 )
 
 // Regex to parse: * main a1b2c3d [origin/main: ahead 2, behind 1] Commit message
-// Groups: 1=Current(* or space), 2=BranchName, 3=RemoteInfo
-var branchLineRegex = regexp.MustCompile(`^([\* ])\s+(\S+)\s+\w+\s+\[([^\]]+)\]`)
+// Branches checked out in another worktree are marked with + and followed by
+// the worktree path: + feature a1b2c3d (/path/to/worktree) [origin/feature] Commit message
+// Groups: 1=Current(*, + or space), 2=BranchName, 3=RemoteInfo
+var branchLineRegex = regexp.MustCompile(`^([\*\+ ])\s+(\S+)\s+\w+\s+(?:\([^)]*\)\s+)?\[([^\]]+)\]`)
+
+// Regex to parse a branch without upstream: * main a1b2c3d Commit message
+var branchNoUpstreamRegex = regexp.MustCompile(`^([\*\+ ])\s+(\S+)\s+\w+\s+`)
 
 // Regex to parse remote info: origin/main: ahead 2, behind 1
 var aheadRegex = regexp.MustCompile(`ahead (\d+)`)
 var behindRegex = regexp.MustCompile(`behind (\d+)`)
 
-func GetRepoStatus(ctx context.Context, path string, logger *logger.Logger) (*types.RepoResult, error) {
-	logger.Debug("Analyzing branches in repo: %s", path)
+// maxOutputLineBytes bounds a single line of git output, e.g. a branch whose
+// commit subject is unusually long
+const maxOutputLineBytes = 16 << 20
 
-	ctx, cancel := context.WithTimeout(ctx, time.Duration(defaults.DefaultGitCommandTimeoutSeconds)*time.Second)
-	defer cancel()
+// newLineScanner returns a scanner over the lines of git output
+func newLineScanner(output string) *bufio.Scanner {
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	scanner.Buffer(nil, maxOutputLineBytes)
+	return scanner
+}
+
+// GetRepoStatus analyzes the repository at path. With BackendNative the
+// branches, detached HEAD and stash are read without running git, falling
+// back to git when the repository uses something the native reader does not
+// support; the working directory is always checked with git status.
+func GetRepoStatus(ctx context.Context, path string, backend string, logger *logger.Logger) (*types.RepoResult, error) {
+	logger.Debug("Analyzing branches in repo: %s", path)
 
-	cmd := exec.CommandContext(ctx, "git", "branch", "-vv")
-	cmd.Dir = path
+	result := &types.RepoResult{
+		Path:     path,
+		Branches: []types.BranchSyncStatus{},
+	}
 
-	output, err := cmd.CombinedOutput()
+	fsys := fileSystem(ctx)
+	kind, parent, err := detectRepo(fsys, path)
 	if err != nil {
-		logger.Error("Failed to execute git command in %s. Error: %v. Output: %s", path, err, string(output))
-		return nil, fmt.Errorf("git command failed: %w", err)
+		logger.Warn("Could not determine repository kind for %s: %v", path, err)
+	} else {
+		result.Kind = kind
+		result.Parent = parent
 	}
 
-	logger.Debug("Git command output for %s:\n%s", path, string(output))
+	var native *nativeStatus
+	if backend == BackendNative {
+		native, err = readNativeStatus(path, logger)
+		if err != nil {
+			logger.Info("Native backend cannot read %s, falling back to git: %v", path, err)
+			native = nil
+		}
+	}
 
-	branches, err := parseGitOutput(string(output), logger)
-	if err != nil {
-		return nil, err
+	var branches []types.BranchSyncStatus
+	if native != nil {
+		branches = native.Branches
+		result.DetachedHead = native.DetachedHead
+		result.HasDetachedHead = native.HasDetachedHead
+	} else {
+		branches, err = getBranchesExec(ctx, path, result, logger)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	result := &types.RepoResult{
-		Path:     path,
-		Branches: []types.BranchSyncStatus{},
+	if native != nil {
+		result.LastCommit = native.HeadTime
+		result.LastAuthored = native.HeadAuthorTime
+	} else {
+		refs, err := getBranchRefsExec(ctx, path, logger)
+		if err != nil {
+			logger.Error("Failed to get branch commit times for %s: %v", path, err)
+		}
+		for i := range branches {
+			branches[i].LastCommit = refs[branches[i].Name].lastCommit
+			branches[i].LastAuthored = refs[branches[i].Name].lastAuthored
+			branches[i].Remote = refs[branches[i].Name].remote
+		}
+		if err := comparePushRefs(ctx, path, branches, refs, logger); err != nil {
+			logger.Error("Failed to compare branches with their push destinations in %s: %v", path, err)
+		}
+	}
+
+	for _, b := range branches {
+		if b.Current {
+			result.Branch = b.Name
+			result.LastCommit = b.LastCommit
+			result.LastAuthored = b.LastAuthored
+		}
+	}
+
+	// HEAD is detached, possibly by a rebase, or on a branch without commits
+	if native == nil && result.Branch == "" {
+		if result.LastCommit, result.LastAuthored, err = getHeadTimeExec(ctx, path); err != nil {
+			logger.Debug("No HEAD commit time for %s: %v", path, err)
+		}
 	}
 
 	for _, b := range branches {
-		if b.Ahead > 0 || b.Behind > 0 || b.Gone || b.NoUpstream {
+		if b.Ahead > 0 || b.Behind > 0 || b.Gone || b.NoUpstream || b.PushAhead > 0 || b.PushBehind > 0 {
 			result.HasUnsynced = true
 			result.Branches = append(result.Branches, b)
 		}
 	}
 
-	workdirStatus, err := GetWorkdirStatus(ctx, path, logger)
+	workdirStatus, changed, err := getWorkdirStatus(ctx, path, logger)
 	if err != nil {
 		logger.Error("Failed to get working directory status for %s: %v", path, err)
 	} else {
 		result.Uncommitted = workdirStatus
-		result.HasUncommitted = workdirStatus.Modified > 0 || workdirStatus.Staged > 0 || workdirStatus.Untracked > 0
+		result.HasUncommitted = hasWorkdirChanges(workdirStatus)
+		result.LastModified = lastModified(fsys, path, changed)
+	}
+
+	operation, err := getOperationState(fsys, path)
+	if err != nil {
+		logger.Error("Failed to get operation state for %s: %v", path, err)
+	} else if operation.Operation != types.OperationNone {
+		logger.Debug("Repo %s has a %s in progress (%d/%d)", path, operation.Operation, operation.Step, operation.Total)
+		result.Operation = operation
+		result.HasOperation = true
+	}
+
+	if native != nil {
+		result.Stash = native.Stash
+		result.HasStash = native.Stash.Count > 0
+	} else if stashStatus, err := GetStashStatus(ctx, path, logger); err != nil {
+		logger.Error("Failed to get stash status for %s: %v", path, err)
+	} else {
+		result.Stash = stashStatus
+		result.HasStash = stashStatus.Count > 0
 	}
 
-	logger.Debug("Repo %s: %d unsynced branches found, uncommitted: modified=%d staged=%d untracked=%d",
-		path, len(result.Branches), result.Uncommitted.Modified, result.Uncommitted.Staged, result.Uncommitted.Untracked)
+	logger.Debug("Repo %s: %d unsynced branches found, uncommitted: %v, stashes=%d",
+		path, len(result.Branches), result.HasUncommitted, result.Stash.Count)
 	return result, nil
 }
 
+// getBranchesExec lists branches with git branch -vv and records a detached
+// HEAD in result
+func getBranchesExec(ctx context.Context, path string, result *types.RepoResult, logger *logger.Logger) ([]types.BranchSyncStatus, error) {
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(defaults.DefaultGitCommandTimeoutSeconds)*time.Second)
+	defer cancel()
+
+	output, err := runGit(ctx, path, nil, "branch", "-vv")
+	if err != nil {
+		logger.Error("Failed to execute git command in %s. Error: %v. Output: %s", path, err, string(output))
+		return nil, fmt.Errorf("git command failed: %w", err)
+	}
+
+	logger.Debug("Git command output for %s:\n%s", path, string(output))
+
+	branches, err := parseGitOutput(string(output), logger)
+	if err != nil {
+		return nil, err
+	}
+
+	if detached, ok := parseDetachedHead(string(output), logger); ok {
+		orphaned, err := countOrphanedCommits(ctx, path)
+		if err != nil {
+			logger.Error("Failed to count commits only reachable from HEAD in %s: %v", path, err)
+		}
+		detached.Orphaned = orphaned
+		result.DetachedHead = detached
+		result.HasDetachedHead = true
+	}
+
+	return branches, nil
+}
+
+// branchRef is what git for-each-ref reports about a local branch beyond
+// git branch -vv
+type branchRef struct {
+	lastCommit   time.Time // committer time of the branch tip
+	lastAuthored time.Time // author time of the branch tip
+	remote       string    // remote of the upstream, "" without one
+	push         string    // ref git push would update (@{push}), "" without one
+}
+
+// getBranchRefsExec returns the tip commit and author times, upstream remote
+// and push destination of every local branch
+func getBranchRefsExec(ctx context.Context, path string, logger *logger.Logger) (map[string]branchRef, error) {
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(defaults.DefaultGitCommandTimeoutSeconds)*time.Second)
+	defer cancel()
+
+	output, err := runGit(ctx, path, nil, "for-each-ref", "--format=%(committerdate:unix)%00%(authordate:unix)%00%(refname)%00%(upstream:remotename)%00%(push)", "refs/heads")
+	if err != nil {
+		return nil, fmt.Errorf("git for-each-ref failed: %w", err)
+	}
+	return parseBranchRefs(string(output), logger), nil
+}
+
+// parseBranchRefs parses "<commit time>\0<author time>\0refs/heads/<name>\0<remote>\0<push ref>"
+// lines with unix times, where remote and push ref may be empty
+func parseBranchRefs(output string, logger *logger.Logger) map[string]branchRef {
+	refs := make(map[string]branchRef)
+	scanner := newLineScanner(output)
+
+	for scanner.Scan() {
+		fields := strings.Split(scanner.Text(), "\x00")
+		if len(fields) != 5 {
+			logger.Debug("Skipping ref line (format mismatch): %q", scanner.Text())
+			continue
+		}
+		committed, err := strconv.ParseInt(fields[0], 10, 64)
+		if err != nil {
+			logger.Error("Failed to parse commit time in '%s': %v", scanner.Text(), err)
+			continue
+		}
+		authored, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			logger.Error("Failed to parse author time in '%s': %v", scanner.Text(), err)
+			continue
+		}
+		refs[strings.TrimPrefix(fields[2], "refs/heads/")] = branchRef{
+			lastCommit:   time.Unix(committed, 0),
+			lastAuthored: time.Unix(authored, 0),
+			remote:       fields[3],
+			push:         fields[4],
+		}
+	}
+
+	return refs
+}
+
+// getHeadTimeExec returns the committer and author times of the commit HEAD
+// points at
+func getHeadTimeExec(ctx context.Context, path string) (time.Time, time.Time, error) {
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(defaults.DefaultGitCommandTimeoutSeconds)*time.Second)
+	defer cancel()
+
+	output, err := runGit(ctx, path, nil, "log", "-1", "--format=%ct %at", "HEAD")
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("git log failed: %w", err)
+	}
+	var times [2]time.Time
+	fields := strings.Fields(string(output))
+	if len(fields) != len(times) {
+		return time.Time{}, time.Time{}, fmt.Errorf("unexpected git log output '%s'", strings.TrimSpace(string(output)))
+	}
+	for i, field := range fields {
+		seconds, err := strconv.ParseInt(field, 10, 64)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("failed to parse commit time '%s': %w", field, err)
+		}
+		times[i] = time.Unix(seconds, 0)
+	}
+	return times[0], times[1], nil
+}
+
 func parseGitOutput(output string, logger *logger.Logger) ([]types.BranchSyncStatus, error) {
 	var branches []types.BranchSyncStatus
-	scanner := bufio.NewScanner(strings.NewReader(output))
+	scanner := newLineScanner(output)
 
 	for scanner.Scan() {
-		line := scanner.Text()
-		line = strings.TrimSpace(line)
+		// Only trim the right side: the first column carries the current marker
+		line := strings.TrimRight(scanner.Text(), " \t\r")
 		if line == "" {
 			continue
 		}
 
-		if strings.Contains(line, "(HEAD detached at") {
+		if strings.Contains(line, "(HEAD detached ") {
 			logger.Debug("Skipping detached HEAD line: %s", line)
 			continue
 		}
 
 		matches := branchLineRegex.FindStringSubmatch(line)
 		if matches == nil {
-			branchNoUpstreamRegex := regexp.MustCompile(`^([\* ])\s+(\S+)\s+\w+\s+`)
 			noUpstreamMatches := branchNoUpstreamRegex.FindStringSubmatch(line)
 			if noUpstreamMatches != nil {
 				isCurrent := noUpstreamMatches[1] == "*"
@@ -110,9 +305,12 @@ func parseGitOutput(output string, logger *logger.Logger) ([]types.BranchSyncSta
 		name := matches[2]
 		remoteInfo := matches[3]
 
+		// The upstream is everything before the state, e.g. "origin/main: ahead 2"
+		upstream, _, _ := strings.Cut(remoteInfo, ":")
 		b := types.BranchSyncStatus{
-			Name:    name,
-			Current: isCurrent,
+			Name:     name,
+			Current:  isCurrent,
+			Upstream: upstream,
 		}
 
 		if strings.Contains(remoteInfo, ": gone]") || strings.HasSuffix(remoteInfo, ": gone") {
@@ -139,56 +337,156 @@ func parseGitOutput(output string, logger *logger.Logger) ([]types.BranchSyncSta
 			}
 		}
 
-		logger.Debug("Parsed branch: %s (Current: %v, Ahead: %d, Behind: %d, Gone: %v)",
-			b.Name, b.Current, b.Ahead, b.Behind, b.Gone)
+		logger.Debug("Parsed branch: %s (Current: %v, Upstream: %s, Ahead: %d, Behind: %d, Gone: %v)",
+			b.Name, b.Current, b.Upstream, b.Ahead, b.Behind, b.Gone)
 
 		branches = append(branches, b)
 	}
 
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading git branch output: %w", err)
+	}
+
 	return branches, nil
 }
 
 func GetWorkdirStatus(ctx context.Context, path string, logger *logger.Logger) (types.WorkdirStatus, error) {
+	status, _, err := getWorkdirStatus(ctx, path, logger)
+	return status, err
+}
+
+// getWorkdirStatus counts the uncommitted changes at path and also returns
+// the changed and untracked paths, relative to path
+func getWorkdirStatus(ctx context.Context, path string, logger *logger.Logger) (types.WorkdirStatus, []string, error) {
 	logger.Debug("Checking working directory status for: %s", path)
 
 	ctx, cancel := context.WithTimeout(ctx, time.Duration(defaults.DefaultGitCommandTimeoutSeconds)*time.Second)
 	defer cancel()
 
-	cmd := exec.CommandContext(ctx, "git", "status", "--porcelain")
-	cmd.Dir = path
-
-	output, err := cmd.CombinedOutput()
+	// Without optional locks git status does not write the refreshed index
+	// back, so scanning never modifies a repository (or wakes up -watch)
+	output, err := runGit(ctx, path, []string{"GIT_OPTIONAL_LOCKS=0"}, "status", "--porcelain=v2")
 	if err != nil {
-		return types.WorkdirStatus{}, fmt.Errorf("git status failed: %w", err)
+		return types.WorkdirStatus{}, nil, fmt.Errorf("git status failed: %w", err)
 	}
 
+	status, paths := parsePorcelainV2(string(output), logger)
+
+	logger.Debug("Working directory status for %s: modified=%d staged=%d untracked=%d deleted=%d renamed=%d conflicted=%d typechanged=%d",
+		path, status.Modified, status.Staged, status.Untracked, status.Deleted, status.Renamed, status.Conflicted, status.TypeChanged)
+
+	return status, paths, nil
+}
+
+// parsePorcelainV2 parses the output of git status --porcelain=v2.
+//
+// Changed entries are counted from their XY status code, where X is the
+// index and Y the worktree state and "." means unchanged. An entry can be
+// counted in several categories, e.g. "RM" is staged, renamed and modified:
+//   - Staged: X is not "."
+//   - Modified: Y is "M"
+//   - Deleted: X or Y is "D"
+//   - TypeChanged: X or Y is "T"
+//   - Renamed: rename entries ("2" lines) with X "R"
+//   - Conflicted: unmerged entries ("u" lines), whatever their XY
+//   - Untracked: "?" lines
+//
+// The paths of the changed and untracked entries are returned as well.
+func parsePorcelainV2(output string, logger *logger.Logger) (types.WorkdirStatus, []string) {
 	status := types.WorkdirStatus{}
-	scanner := bufio.NewScanner(strings.NewReader(string(output)))
+	var paths []string
+	scanner := newLineScanner(output)
 
 	for scanner.Scan() {
 		line := scanner.Text()
-		if len(line) < 2 {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
 			continue
 		}
 
-		indexStatus := line[0]
-		worktreeStatus := line[1]
-
-		if indexStatus != ' ' && indexStatus != '?' {
-			status.Staged++
+		switch fields[0] {
+		case "1", "2":
+			if len(fields) < 2 || len(fields[1]) != 2 {
+				logger.Debug("Skipping status line (format mismatch): %s", line)
+				continue
+			}
+			x, y := fields[1][0], fields[1][1]
+			if x != '.' {
+				status.Staged++
+			}
+			if y == 'M' {
+				status.Modified++
+			}
+			if x == 'D' || y == 'D' {
+				status.Deleted++
+			}
+			if x == 'T' || y == 'T' {
+				status.TypeChanged++
+			}
+			if fields[0] == "2" && x == 'R' {
+				status.Renamed++
+			}
+			paths = appendStatusPath(paths, line)
+		case "u":
+			status.Conflicted++
+			paths = appendStatusPath(paths, line)
+		case "?":
+			status.Untracked++
+			paths = appendStatusPath(paths, line)
+		case "!":
+			// ignored files are only listed with --ignored
+		default:
+			logger.Debug("Skipping status line (format mismatch): %s", line)
 		}
+	}
 
-		if worktreeStatus == 'M' {
-			status.Modified++
-		}
+	return status, paths
+}
 
-		if indexStatus == '?' && worktreeStatus == '?' {
-			status.Untracked++
+// statusPathField is the index of the path among the space-separated fields
+// of each kind of git status --porcelain=v2 entry
+var statusPathField = map[byte]int{'1': 8, '2': 9, 'u': 10, '?': 1}
+
+// appendStatusPath appends the path of a status entry to paths. Of a rename,
+// the path it was renamed to is used; paths git quotes are unquoted.
+func appendStatusPath(paths []string, line string) []string {
+	n := statusPathField[line[0]]
+	fields := strings.SplitN(line, " ", n+1)
+	if len(fields) != n+1 {
+		return paths
+	}
+	p := fields[n]
+	if line[0] == '2' {
+		p, _, _ = strings.Cut(p, "\t")
+	}
+	if strings.HasPrefix(p, `"`) {
+		unquoted, err := strconv.Unquote(p)
+		if err != nil {
+			return paths
 		}
+		p = unquoted
 	}
+	return append(paths, p)
+}
 
-	logger.Debug("Working directory status for %s: modified=%d staged=%d untracked=%d",
-		path, status.Modified, status.Staged, status.Untracked)
+// lastModified returns the latest modification time of paths relative to
+// dir, skipping the ones that no longer exist such as deleted files
+func lastModified(fsys FileSystem, dir string, paths []string) time.Time {
+	var latest time.Time
+	for _, p := range paths {
+		info, err := fsys.Lstat(filepath.Join(dir, p))
+		if err != nil {
+			continue
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest
+}
 
-	return status, nil
+// hasWorkdirChanges reports whether w counts any uncommitted change
+func hasWorkdirChanges(w types.WorkdirStatus) bool {
+	return w.Modified > 0 || w.Staged > 0 || w.Untracked > 0 || w.Deleted > 0 ||
+		w.Renamed > 0 || w.Conflicted > 0 || w.TypeChanged > 0
 }
@@ -2,10 +2,22 @@ package git
 
 import (
 	"context"
+	"crypto/sha1"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
 	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
 	"testing"
+	"testing/fstest"
+	"time"
 
 	"gitstatus/src/logger"
+	"gitstatus/src/types"
 )
 
 func TestGetRepoStatusReal(t *testing.T) {
@@ -60,7 +72,7 @@ func TestGetRepoStatusReal(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.repoName, func(t *testing.T) {
 			repoPath := filepath.Join(testEnv, tt.repoName)
-			result, err := GetRepoStatus(ctx, repoPath, logger)
+			result, err := GetRepoStatus(ctx, repoPath, BackendExec, logger)
 			if err != nil {
 				t.Fatalf("GetRepoStatus failed: %v", err)
 			}
@@ -102,10 +114,11 @@ func TestGetWorkdirStatusReal(t *testing.T) {
 	ctx := context.Background()
 
 	tests := []struct {
-		repoName      string
-		wantModified  int
-		wantStaged    int
-		wantUntracked int
+		repoName       string
+		wantModified   int
+		wantStaged     int
+		wantUntracked  int
+		wantConflicted int
 	}{
 		{
 			repoName:      "repo_synced",
@@ -131,6 +144,10 @@ func TestGetWorkdirStatusReal(t *testing.T) {
 			wantStaged:    0,
 			wantUntracked: 1,
 		},
+		{
+			repoName:       "repo_merging",
+			wantConflicted: 1,
+		},
 	}
 
 	for _, tt := range tests {
@@ -150,6 +167,1487 @@ func TestGetWorkdirStatusReal(t *testing.T) {
 			if status.Untracked != tt.wantUntracked {
 				t.Errorf("Untracked = %d, want %d", status.Untracked, tt.wantUntracked)
 			}
+			if status.Conflicted != tt.wantConflicted {
+				t.Errorf("Conflicted = %d, want %d", status.Conflicted, tt.wantConflicted)
+			}
+		})
+	}
+}
+
+func TestDetectRepoReal(t *testing.T) {
+	testEnv := setupTestRepos(t)
+
+	tests := []struct {
+		repoName   string
+		wantKind   types.RepoKind
+		wantParent string
+		wantErr    error
+	}{
+		{
+			repoName: "repo_synced",
+			wantKind: types.RepoKindRepository,
+		},
+		{
+			repoName:   "repo_worktree",
+			wantKind:   types.RepoKindWorktree,
+			wantParent: filepath.Join(testEnv, "repo_with_worktree"),
+		},
+		{
+			repoName:   filepath.Join("repo_with_submodule", "sub"),
+			wantKind:   types.RepoKindSubmodule,
+			wantParent: filepath.Join(testEnv, "repo_with_submodule"),
+		},
+		{
+			repoName: "repo_gitfile",
+			wantKind: types.RepoKindGitfile,
+		},
+		{
+			repoName: "not_a_repo",
+			wantErr:  ErrNotRepository,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.repoName, func(t *testing.T) {
+			kind, parent, err := DetectRepo(filepath.Join(testEnv, tt.repoName))
+			if err != tt.wantErr {
+				t.Fatalf("DetectRepo error = %v, want %v", err, tt.wantErr)
+			}
+			if kind != tt.wantKind {
+				t.Errorf("Kind = %q, want %q", kind, tt.wantKind)
+			}
+			if parent != tt.wantParent {
+				t.Errorf("Parent = %q, want %q", parent, tt.wantParent)
+			}
+		})
+	}
+}
+
+func TestGetRepoStatusWorktree(t *testing.T) {
+	testEnv := setupTestRepos(t)
+	logger, _ := logger.NewLogger([]string{}, "")
+	ctx := context.Background()
+
+	result, err := GetRepoStatus(ctx, filepath.Join(testEnv, "repo_worktree"), BackendExec, logger)
+	if err != nil {
+		t.Fatalf("GetRepoStatus failed: %v", err)
+	}
+
+	if result.Kind != types.RepoKindWorktree {
+		t.Errorf("Kind = %q, want %q", result.Kind, types.RepoKindWorktree)
+	}
+
+	found := false
+	for _, b := range result.Branches {
+		if b.Name == "worktree-branch" {
+			found = true
+			if !b.Current || !b.NoUpstream {
+				t.Errorf("Expected current branch without upstream, got %+v", b)
+			}
+		}
+	}
+	if !found {
+		t.Errorf("Expected worktree-branch in %+v", result.Branches)
+	}
+
+	// The main worktree sees worktree-branch as checked out elsewhere (+)
+	result, err = GetRepoStatus(ctx, filepath.Join(testEnv, "repo_with_worktree"), BackendExec, logger)
+	if err != nil {
+		t.Fatalf("GetRepoStatus failed: %v", err)
+	}
+	for _, b := range result.Branches {
+		if b.Name == "worktree-branch" && b.Current {
+			t.Error("Branch checked out in another worktree should not be current")
+		}
+	}
+}
+
+func TestParseGitOutputNonCurrentBranches(t *testing.T) {
+	logger, _ := logger.NewLogger([]string{}, "")
+	output := "  develop  1a2b3c4 [origin/develop: behind 4] Fix\n" +
+		"* main     5d6e7f8 [origin/main: ahead 1] Work\n" +
+		"+ feature  9a8b7c6 (/tmp/wt) [origin/feature: ahead 2, behind 3] WIP\n" +
+		"  local    0f1e2d3 Local only\n"
+
+	branches, err := parseGitOutput(output, logger)
+	if err != nil {
+		t.Fatalf("parseGitOutput failed: %v", err)
+	}
+
+	want := []types.BranchSyncStatus{
+		{Name: "develop", Upstream: "origin/develop", Behind: 4},
+		{Name: "main", Current: true, Upstream: "origin/main", Ahead: 1},
+		{Name: "feature", Upstream: "origin/feature", Ahead: 2, Behind: 3},
+		{Name: "local", NoUpstream: true},
+	}
+	if len(branches) != len(want) {
+		t.Fatalf("Expected %d branches, got %d: %+v", len(want), len(branches), branches)
+	}
+	for i := range want {
+		if !reflect.DeepEqual(branches[i], want[i]) {
+			t.Errorf("Branch %d = %+v, want %+v", i, branches[i], want[i])
+		}
+	}
+}
+
+func TestGetRepoStatusDetachedHead(t *testing.T) {
+	testEnv := setupTestRepos(t)
+	logger, _ := logger.NewLogger([]string{}, "")
+	ctx := context.Background()
+
+	tests := []struct {
+		repoName     string
+		wantDetached bool
+		wantFrom     string
+		wantMoved    bool
+		wantOrphaned int
+	}{
+		{repoName: "repo_synced"},
+		{repoName: "repo_detached", wantDetached: true, wantMoved: true, wantOrphaned: 1},
+		{repoName: "repo_detached_clean", wantDetached: true, wantFrom: "origin/master"},
+		{repoName: "repo_rebasing"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.repoName, func(t *testing.T) {
+			result, err := GetRepoStatus(ctx, filepath.Join(testEnv, tt.repoName), BackendExec, logger)
+			if err != nil {
+				t.Fatalf("GetRepoStatus failed: %v", err)
+			}
+			if result.HasDetachedHead != tt.wantDetached {
+				t.Fatalf("HasDetachedHead = %v, want %v", result.HasDetachedHead, tt.wantDetached)
+			}
+			if !tt.wantDetached {
+				return
+			}
+
+			head := result.DetachedHead
+			if head.Commit == "" {
+				t.Error("Commit is empty")
+			}
+			if tt.wantFrom != "" && head.From != tt.wantFrom {
+				t.Errorf("From = %q, want %q", head.From, tt.wantFrom)
+			}
+			if head.Moved != tt.wantMoved {
+				t.Errorf("Moved = %v, want %v", head.Moved, tt.wantMoved)
+			}
+			if head.Orphaned != tt.wantOrphaned {
+				t.Errorf("Orphaned = %d, want %d", head.Orphaned, tt.wantOrphaned)
+			}
+			for _, b := range result.Branches {
+				if strings.HasPrefix(b.Name, "(") {
+					t.Errorf("detached HEAD parsed as branch %q", b.Name)
+				}
+			}
+		})
+	}
+}
+
+func TestParseDetachedHead(t *testing.T) {
+	logger, _ := logger.NewLogger([]string{}, "")
+
+	tests := []struct {
+		line   string
+		want   types.DetachedHead
+		wantOk bool
+	}{
+		{"* (HEAD detached at v1.2.0) 1a2b3c4 Release", types.DetachedHead{Commit: "1a2b3c4", From: "v1.2.0"}, true},
+		{"* (HEAD detached from 9f8e7d6) 1a2b3c4 Local work", types.DetachedHead{Commit: "1a2b3c4", From: "9f8e7d6", Moved: true}, true},
+		{"* (no branch, rebasing topic) 1a2b3c4 Topic 1", types.DetachedHead{}, false},
+		{"* master 1a2b3c4 [origin/master] Initial", types.DetachedHead{}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			got, ok := parseDetachedHead(tt.line+"\n  master 1a2b3c4 [origin/master] Initial\n", logger)
+			if ok != tt.wantOk || got != tt.want {
+				t.Errorf("parseDetachedHead() = %+v, %v, want %+v, %v", got, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
+
+func TestNativeBackendMatchesExec(t *testing.T) {
+	testEnv := setupTestRepos(t)
+	logger, _ := logger.NewLogger([]string{}, "")
+	ctx := context.Background()
+
+	entries, err := os.ReadDir(testEnv)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, entry := range entries {
+		path := filepath.Join(testEnv, entry.Name())
+		if _, _, err := DetectRepo(path); err != nil {
+			continue
+		}
+
+		t.Run(entry.Name(), func(t *testing.T) {
+			if _, err := readNativeStatus(path, logger); err != nil {
+				t.Fatalf("readNativeStatus failed: %v", err)
+			}
+
+			want, err := GetRepoStatus(ctx, path, BackendExec, logger)
+			if err != nil {
+				t.Fatalf("GetRepoStatus(exec) failed: %v", err)
+			}
+			got, err := GetRepoStatus(ctx, path, BackendNative, logger)
+			if err != nil {
+				t.Fatalf("GetRepoStatus(native) failed: %v", err)
+			}
+
+			if len(got.Branches) != len(want.Branches) {
+				t.Fatalf("Branches = %+v, want %+v", got.Branches, want.Branches)
+			}
+			for i := range want.Branches {
+				if !reflect.DeepEqual(got.Branches[i], want.Branches[i]) {
+					t.Errorf("Branch %d = %+v, want %+v", i, got.Branches[i], want.Branches[i])
+				}
+			}
+			if got.HasDetachedHead != want.HasDetachedHead || got.DetachedHead != want.DetachedHead {
+				t.Errorf("DetachedHead = %v %+v, want %v %+v",
+					got.HasDetachedHead, got.DetachedHead, want.HasDetachedHead, want.DetachedHead)
+			}
+			if got.Branch != want.Branch || !got.LastCommit.Equal(want.LastCommit) {
+				t.Errorf("Branch, LastCommit = %q, %v, want %q, %v", got.Branch, got.LastCommit, want.Branch, want.LastCommit)
+			}
+			if got.Stash.Count != want.Stash.Count || !got.Stash.Oldest.Equal(want.Stash.Oldest) {
+				t.Errorf("Stash = %+v, want %+v", got.Stash, want.Stash)
+			}
+		})
+	}
+}
+
+func TestObjectStoreReadsPackedObjects(t *testing.T) {
+	testEnv := setupTestRepos(t)
+	repoPath := filepath.Join(testEnv, "repo_packed")
+
+	cmd := exec.Command("git", "cat-file", "--batch-all-objects", "--batch-check=%(objectname) %(objecttype)")
+	cmd.Dir = repoPath
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("git cat-file failed: %v", err)
+	}
+
+	store, err := openObjectStore(filepath.Join(repoPath, ".git", "objects"))
+	if err != nil {
+		t.Fatalf("openObjectStore failed: %v", err)
+	}
+	defer store.close()
+	if len(store.packs) == 0 {
+		t.Fatal("Expected repo_packed to have a packfile")
+	}
+
+	typeNames := map[objectType]string{objectCommit: "commit", objectTree: "tree", objectBlob: "blob", objectTag: "tag"}
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		fields := strings.Fields(line)
+		id, err := parseObjectID(fields[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		typ, data, err := store.read(id)
+		if err != nil {
+			t.Errorf("read(%s) failed: %v", id, err)
+			continue
+		}
+		if typeNames[typ] != fields[1] {
+			t.Errorf("read(%s) type = %s, want %s", id, typeNames[typ], fields[1])
+		}
+		if sum := sha1.Sum([]byte(fmt.Sprintf("%s %d\x00%s", fields[1], len(data), data))); sum != id {
+			t.Errorf("read(%s) returned content hashing to %x", id, sum)
+		}
+	}
+}
+
+func TestApplyDelta(t *testing.T) {
+	base := []byte("hello, world")
+	// source size 12, target size 11, copy "hello" from offset 0, insert "!!!",
+	// copy "rld" from offset 9
+	delta := []byte{12, 11, 0x90, 5, 3, '!', '!', '!', 0x91, 9, 3}
+	got, err := applyDelta(base, delta)
+	if err != nil {
+		t.Fatalf("applyDelta failed: %v", err)
+	}
+	if string(got) != "hello!!!rld" {
+		t.Errorf("applyDelta = %q, want %q", got, "hello!!!rld")
+	}
+
+	for _, bad := range [][]byte{
+		{11, 5, 0x90, 5},     // wrong source size
+		{12, 5, 0x91, 10, 5}, // copy past the end of the base
+		{12, 5, 6, 'a', 'b'}, // insert past the end of the delta
+		{12, 3, 0x90, 5},     // result longer than announced
+		{12, 1, 0},           // reserved opcode
+	} {
+		if _, err := applyDelta(base, bad); err == nil {
+			t.Errorf("applyDelta(%v) succeeded, want error", bad)
+		}
+	}
+}
+
+func TestMapRefspec(t *testing.T) {
+	tests := []struct {
+		refspec, ref, want string
+		wantOk             bool
+	}{
+		{"+refs/heads/*:refs/remotes/origin/*", "refs/heads/main", "refs/remotes/origin/main", true},
+		{"+refs/heads/*:refs/remotes/origin/*", "refs/heads/feature/x", "refs/remotes/origin/feature/x", true},
+		{"+refs/heads/*:refs/remotes/origin/*", "refs/tags/v1", "", false},
+		{"refs/heads/main:refs/remotes/upstream/trunk", "refs/heads/main", "refs/remotes/upstream/trunk", true},
+		{"refs/heads/main:refs/remotes/upstream/trunk", "refs/heads/dev", "", false},
+		{"^refs/heads/secret", "refs/heads/secret", "", false},
+		{"refs/heads/main", "refs/heads/main", "", false},
+	}
+
+	for _, tt := range tests {
+		got, ok := mapRefspec(tt.refspec, tt.ref)
+		if got != tt.want || ok != tt.wantOk {
+			t.Errorf("mapRefspec(%q, %q) = %q, %v, want %q, %v", tt.refspec, tt.ref, got, ok, tt.want, tt.wantOk)
+		}
+	}
+}
+
+func TestParseConfig(t *testing.T) {
+	content := `
+# comment
+[core]
+	repositoryformatversion = 0
+	Bare = false
+[remote "origin"]
+	url = "git@example.com:team/repo.git" ; trailing comment
+	fetch = +refs/heads/*:refs/remotes/origin/*
+	fetch = +refs/tags/*:refs/tags/*
+[branch "Feature/X"]
+	remote = origin
+	merge = refs/heads/feature/x
+[branch.legacy]
+	remote = upstream
+[alias]
+	ls = log \
+		--oneline
+	flag
+`
+	config, err := parseConfig(content)
+	if err != nil {
+		t.Fatalf("parseConfig failed: %v", err)
+	}
+
+	tests := map[string][]string{
+		"core.repositoryformatversion": {"0"},
+		"core.bare":                    {"false"},
+		"remote.origin.url":            {"git@example.com:team/repo.git"},
+		"remote.origin.fetch":          {"+refs/heads/*:refs/remotes/origin/*", "+refs/tags/*:refs/tags/*"},
+		"branch.Feature/X.merge":       {"refs/heads/feature/x"},
+		"branch.legacy.remote":         {"upstream"},
+		"alias.ls":                     {"log --oneline"},
+		"alias.flag":                   {"true"},
+	}
+	for key, want := range tests {
+		got := config[key]
+		if strings.Join(got, "|") != strings.Join(want, "|") {
+			t.Errorf("%s = %q, want %q", key, got, want)
+		}
+	}
+
+	if _, err := parseConfig("key = value\n"); err == nil {
+		t.Error("Expected error for key outside a section")
+	}
+}
+
+func TestNativeBackendUnsupported(t *testing.T) {
+	testEnv := setupTestRepos(t)
+	logger, _ := logger.NewLogger([]string{}, "")
+
+	repoPath := filepath.Join(t.TempDir(), "repo")
+	cmd := exec.Command("git", "clone", "--quiet", filepath.Join(testEnv, "repo_synced"), repoPath)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git clone failed: %v\n%s", err, output)
+	}
+	cmd = exec.Command("git", "config", "extensions.refStorage", "files")
+	cmd.Dir = repoPath
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git config failed: %v\n%s", err, output)
+	}
+
+	if _, err := readNativeStatus(repoPath, logger); !errors.Is(err, errNativeUnsupported) {
+		t.Errorf("readNativeStatus error = %v, want %v", err, errNativeUnsupported)
+	}
+}
+
+func TestNativeBackendUserConfig(t *testing.T) {
+	testEnv := setupTestRepos(t)
+	logger, _ := logger.NewLogger([]string{}, "")
+	ctx := context.Background()
+
+	repoPath := filepath.Join(t.TempDir(), "repo")
+	cmd := exec.Command("git", "clone", "--quiet", filepath.Join(testEnv, "repo_ahead"), repoPath)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git clone failed: %v\n%s", err, output)
+	}
+
+	global := filepath.Join(t.TempDir(), "gitconfig")
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+	t.Setenv("GIT_CONFIG_GLOBAL", global)
+	writeGlobal := func(content string) {
+		t.Helper()
+		if err := os.WriteFile(global, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Push settings in the global config are seen, and resolved by git
+	writeGlobal("[push]\n\tdefault = current\n")
+	if _, err := readNativeStatus(repoPath, logger); !errors.Is(err, errNativeUnsupported) {
+		t.Errorf("readNativeStatus with global push.default = %v, want %v", err, errNativeUnsupported)
+	}
+	writeGlobal("[include]\n\tpath = ~/more.gitconfig\n")
+	if _, err := readNativeStatus(repoPath, logger); !errors.Is(err, errNativeUnsupported) {
+		t.Errorf("readNativeStatus with global includes = %v, want %v", err, errNativeUnsupported)
+	}
+
+	// A merge without a remote is no upstream to git
+	writeGlobal("[core]\n\tautocrlf = false\n")
+	cmd = exec.Command("git", "config", "--unset", "branch.master.remote")
+	cmd.Dir = repoPath
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git config failed: %v\n%s", err, output)
+	}
+	want, err := GetRepoStatus(ctx, repoPath, BackendExec, logger)
+	if err != nil {
+		t.Fatalf("GetRepoStatus(exec) failed: %v", err)
+	}
+	got, err := readNativeStatus(repoPath, logger)
+	if err != nil {
+		t.Fatalf("readNativeStatus failed: %v", err)
+	}
+	if len(got.Branches) != 1 || len(want.Branches) != 1 ||
+		!got.Branches[0].NoUpstream || !want.Branches[0].NoUpstream || got.Branches[0].Remote != "" {
+		t.Errorf("Native branches %+v, exec branches %+v; want no upstream in both", got.Branches, want.Branches)
+	}
+
+	t.Setenv("GIT_CONFIG_COUNT", "1")
+	if _, err := readNativeStatus(repoPath, logger); !errors.Is(err, errNativeUnsupported) {
+		t.Errorf("readNativeStatus with GIT_CONFIG_COUNT = %v, want %v", err, errNativeUnsupported)
+	}
+}
+
+// replayRepo returns a context whose git commands are answered by a
+// ReplayRunner with calls, and the path of the repository it serves, which
+// only exists in the runner's files. The test fails if any command was not
+// answered by calls.
+func replayRepo(t *testing.T, calls ...Call) (context.Context, string) {
+	t.Helper()
+
+	replay := &ReplayRunner{
+		Calls: calls,
+		Files: fstest.MapFS{"replay/repo/.git": &fstest.MapFile{Mode: fs.ModeDir | 0755}},
+	}
+	t.Cleanup(func() {
+		for _, command := range replay.Unrecorded() {
+			t.Errorf("Unrecorded command: %s", command)
+		}
+	})
+	return WithRunner(context.Background(), replay), filepath.FromSlash("/replay/repo")
+}
+
+func TestGetRepoStatusReplay(t *testing.T) {
+	t.Parallel()
+	logger, _ := logger.NewLogger([]string{}, "")
+
+	var huge strings.Builder
+	huge.WriteString("* main 1a2b3c4 [origin/main: ahead 1] " + strings.Repeat("x", 200000) + "\n")
+	for i := 0; i < 5000; i++ {
+		fmt.Fprintf(&huge, "  topic-%04d 1a2b3c4 [origin/topic-%04d: behind %d] Topic\n", i, i, i%3)
+	}
+
+	tests := []struct {
+		name         string
+		branchOutput string
+		wantBranches []types.BranchSyncStatus
+		wantCount    int
+	}{
+		{
+			name: "OddBranchNames",
+			branchOutput: "* feature/ünïcode-名前 1a2b3c4 [origin/feature/ünïcode-名前: ahead 2] Unicode\n" +
+				"  fix-[brackets] 1a2b3c4 [origin/fix-[brackets]] Brackets in name\n" +
+				"  release/1.2.x 1a2b3c4 [origin/release/1.2.x: behind 3] Dots\n" +
+				"  ahead-4 1a2b3c4 Subject mentioning [ahead 4]\n",
+			wantBranches: []types.BranchSyncStatus{
+				{Name: "feature/ünïcode-名前", Current: true, Upstream: "origin/feature/ünïcode-名前", Ahead: 2},
+				{Name: "release/1.2.x", Upstream: "origin/release/1.2.x", Behind: 3},
+				{Name: "ahead-4", NoUpstream: true},
+			},
+		},
+		{
+			name: "WorktreeAndDetached",
+			branchOutput: "* (HEAD detached at v1.0) 1a2b3c4 Release\n" +
+				"+ main 1a2b3c4 (/work/main) [origin/main: ahead 1, behind 2] Main\n",
+			wantBranches: []types.BranchSyncStatus{
+				{Name: "main", Upstream: "origin/main", Ahead: 1, Behind: 2},
+			},
+		},
+		{
+			name:         "HugeOutput",
+			branchOutput: huge.String(),
+			wantCount:    1 + 5000*2/3,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			ctx, path := replayRepo(t,
+				Call{Args: []string{"branch", "-vv"}, Output: tt.branchOutput},
+				Call{Args: []string{"rev-list", "--count", "HEAD", "--not", "--branches", "--tags", "--remotes"}, Output: "0\n"},
+				Call{Args: []string{"status", "--porcelain=v2"}},
+				Call{Args: []string{"stash", "list", "--format=%ct"}},
+				Call{Args: []string{"for-each-ref", "--format=%(committerdate:unix)%00%(authordate:unix)%00%(refname)%00%(upstream:remotename)%00%(push)", "refs/heads"}},
+				Call{Args: []string{"log", "-1", "--format=%ct %at", "HEAD"}, Output: "1700000000 1700000000\n"},
+			)
+
+			result, err := GetRepoStatus(ctx, path, BackendExec, logger)
+			if err != nil {
+				t.Fatalf("GetRepoStatus failed: %v", err)
+			}
+
+			if tt.wantBranches == nil {
+				if len(result.Branches) != tt.wantCount {
+					t.Errorf("Got %d unsynced branches, want %d", len(result.Branches), tt.wantCount)
+				}
+				return
+			}
+			if len(result.Branches) != len(tt.wantBranches) {
+				t.Fatalf("Branches = %+v, want %+v", result.Branches, tt.wantBranches)
+			}
+			for i, want := range tt.wantBranches {
+				if !reflect.DeepEqual(result.Branches[i], want) {
+					t.Errorf("Branch %d = %+v, want %+v", i, result.Branches[i], want)
+				}
+			}
+		})
+	}
+}
+
+func TestGetRepoStatusReplayErrors(t *testing.T) {
+	t.Parallel()
+	logger, _ := logger.NewLogger([]string{}, "")
+
+	ctx, path := replayRepo(t, Call{
+		Args:   []string{"branch", "-vv"},
+		Output: "fatal: not a git repository (or any of the parent directories): .git\n",
+		Err:    errors.New("exit status 128"),
+	})
+	if _, err := GetRepoStatus(ctx, path, BackendExec, logger); err == nil {
+		t.Error("Expected error when git branch fails")
+	}
+
+	// Failures after git branch are logged
+	failed := errors.New("exit status 128")
+	ctx, path = replayRepo(t,
+		Call{Args: []string{"branch", "-vv"}, Output: "* main 1a2b3c4 [origin/main] Main\n"},
+		Call{Args: []string{"for-each-ref", "--format=%(committerdate:unix)%00%(authordate:unix)%00%(refname)%00%(upstream:remotename)%00%(push)", "refs/heads"}, Err: failed},
+		Call{Args: []string{"status", "--porcelain=v2"}, Err: failed},
+		Call{Args: []string{"stash", "list", "--format=%ct"}, Err: failed},
+	)
+	result, err := GetRepoStatus(ctx, path, BackendExec, logger)
+	if err != nil {
+		t.Fatalf("GetRepoStatus failed: %v", err)
+	}
+	if result.HasUnsynced || result.HasUncommitted || result.HasStash {
+		t.Errorf("Expected a clean result, got %+v", result)
+	}
+}
+
+func TestRecordingRunnerReplays(t *testing.T) {
+	testEnv := setupTestRepos(t)
+	logger, _ := logger.NewLogger([]string{}, "")
+	ctx := context.Background()
+	repoPath := filepath.Join(testEnv, "repo_stashed")
+
+	recorder := &RecordingRunner{Runner: ExecRunner{}}
+	want, err := GetRepoStatus(WithRunner(ctx, recorder), repoPath, BackendExec, logger)
+	if err != nil {
+		t.Fatalf("GetRepoStatus failed: %v", err)
+	}
+
+	calls := recorder.Calls()
+	if len(calls) == 0 {
+		t.Fatal("Expected recorded calls")
+	}
+
+	// The replay reads nothing from the repository on disk
+	replay := &ReplayRunner{
+		Calls: calls,
+		Files: fstest.MapFS{mapPath(filepath.Join(repoPath, ".git")): &fstest.MapFile{Mode: fs.ModeDir | 0755}},
+	}
+	got, err := GetRepoStatus(WithRunner(ctx, replay), repoPath, BackendExec, logger)
+	if err != nil {
+		t.Fatalf("GetRepoStatus from replay failed: %v", err)
+	}
+	if got.Kind != want.Kind || got.Stash != want.Stash || got.Uncommitted != want.Uncommitted || len(got.Branches) != len(want.Branches) {
+		t.Errorf("Replayed result %+v, want %+v", got, want)
+	}
+	if unrecorded := replay.Unrecorded(); len(unrecorded) != 0 {
+		t.Errorf("Unrecorded commands: %v", unrecorded)
+	}
+
+	if _, err := replay.Run(ctx, repoPath, nil, "log"); !errors.Is(err, ErrNoRecording) {
+		t.Errorf("Expected ErrNoRecording for an unrecorded command, got %v", err)
+	}
+	if unrecorded := replay.Unrecorded(); len(unrecorded) != 1 || !strings.HasPrefix(unrecorded[0], "git log in ") {
+		t.Errorf("Unrecorded() = %v, want the git log command", unrecorded)
+	}
+}
+
+func TestExecRunnerEnvironment(t *testing.T) {
+	output, err := ExecRunner{}.Run(context.Background(), t.TempDir(), []string{"GITSTATUS_TEST=1"},
+		"-c", "alias.printenv=!env", "printenv")
+	if err != nil {
+		t.Fatalf("Run failed: %v\n%s", err, output)
+	}
+	for _, want := range []string{"LC_ALL=C", "GITSTATUS_TEST=1"} {
+		if !strings.Contains(string(output), want+"\n") {
+			t.Errorf("Expected %s in the environment of git", want)
+		}
+	}
+}
+
+func TestFetchRepoReal(t *testing.T) {
+	testEnv := setupTestRepos(t)
+	logger, _ := logger.NewLogger([]string{}, "")
+	ctx := context.Background()
+
+	t.Run("UpdatesBehindCount", func(t *testing.T) {
+		repoPath := filepath.Join(testEnv, "repo_stale")
+		if err := FetchRepo(ctx, repoPath, logger); err != nil {
+			t.Fatalf("FetchRepo failed: %v", err)
+		}
+
+		result, err := GetRepoStatus(ctx, repoPath, BackendExec, logger)
+		if err != nil {
+			t.Fatalf("GetRepoStatus failed: %v", err)
+		}
+		for _, b := range result.Branches {
+			if b.Current && b.Behind != 1 {
+				t.Errorf("Behind = %d after fetch, want 1", b.Behind)
+			}
+		}
+		if !result.HasUnsynced {
+			t.Error("Expected repo to be behind after fetch")
+		}
+	})
+
+	t.Run("UnreachableRemote", func(t *testing.T) {
+		err := FetchRepo(ctx, filepath.Join(testEnv, "repo_bad_remote"), logger)
+		if !errors.Is(err, ErrFetchUnreachable) {
+			t.Errorf("Expected ErrFetchUnreachable, got %v", err)
+		}
+	})
+}
+
+func TestFetchGroupReal(t *testing.T) {
+	testEnv := setupTestRepos(t)
+	logger, _ := logger.NewLogger([]string{}, "")
+	recorder := &RecordingRunner{Runner: ExecRunner{}}
+	ctx := WithRunner(context.Background(), recorder)
+
+	main := filepath.Join(testEnv, "repo_with_worktree")
+	worktree := filepath.Join(testEnv, "repo_worktree")
+	mainDir, err := GetCommonDir(ctx, main)
+	if err != nil {
+		t.Fatalf("GetCommonDir failed: %v", err)
+	}
+	worktreeDir, err := GetCommonDir(ctx, worktree)
+	if err != nil {
+		t.Fatalf("GetCommonDir failed: %v", err)
+	}
+	if mainDir != worktreeDir {
+		t.Errorf("GetCommonDir = %q and %q, want the same directory", mainDir, worktreeDir)
+	}
+
+	var group FetchGroup
+	var wg sync.WaitGroup
+	errs := make([]error, 3)
+	for i, path := range []string{main, worktree, filepath.Join(testEnv, "repo_stale")} {
+		wg.Add(1)
+		go func(i int, path string) {
+			defer wg.Done()
+			errs[i] = group.Fetch(ctx, path, logger)
+		}(i, path)
+	}
+	wg.Wait()
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("Fetch %d failed: %v", i, err)
+		}
+	}
+
+	fetches := 0
+	for _, call := range recorder.Calls() {
+		if call.Args[0] == "fetch" {
+			fetches++
+		}
+	}
+	if fetches != 2 {
+		t.Errorf("Ran %d fetches, want 2 (one per repository)", fetches)
+	}
+}
+
+func TestClassifyFetchError(t *testing.T) {
+	tests := []struct {
+		output string
+		want   error
+	}{
+		{"fatal: could not read Username for 'https://example.com': terminal prompts disabled", ErrFetchAuth},
+		{"git@example.com: Permission denied (publickey).\nfatal: Could not read from remote repository.", ErrFetchAuth},
+		{"fatal: unable to access 'https://example.com/x.git/': Could not resolve host: example.com", ErrFetchUnreachable},
+		{"error: some unexpected failure", ErrFetchFailed},
+	}
+
+	for _, tt := range tests {
+		if err := classifyFetchError(tt.output); !errors.Is(err, tt.want) {
+			t.Errorf("classifyFetchError(%q) = %v, want %v", tt.output, err, tt.want)
+		}
+	}
+}
+
+func TestGetBranchCommitsReal(t *testing.T) {
+	testEnv := setupTestRepos(t)
+	logger, _ := logger.NewLogger([]string{}, "")
+	ctx := context.Background()
+
+	ahead, behind, err := GetBranchCommits(ctx, filepath.Join(testEnv, "repo_ahead"), "master", 0, logger)
+	if err != nil {
+		t.Fatalf("GetBranchCommits failed: %v", err)
+	}
+	if len(ahead) != 1 || ahead[0].Subject != "Ahead commit" || ahead[0].Author != "Test User" ||
+		len(ahead[0].Hash) < 7 || ahead[0].Time.IsZero() {
+		t.Errorf("Unexpected ahead commits: %+v", ahead)
+	}
+	if len(behind) != 0 {
+		t.Errorf("Expected no behind commits, got %+v", behind)
+	}
+
+	ahead, behind, err = GetBranchCommits(ctx, filepath.Join(testEnv, "repo_behind"), "master", 0, logger)
+	if err != nil {
+		t.Fatalf("GetBranchCommits failed: %v", err)
+	}
+	if len(ahead) != 0 || len(behind) != 1 || behind[0].Subject != "Behind commit" {
+		t.Errorf("Unexpected commits: ahead %+v, behind %+v", ahead, behind)
+	}
+
+	if _, _, err := GetBranchCommits(ctx, filepath.Join(testEnv, "repo_no_upstream"), "master", 0, logger); err == nil {
+		t.Error("Expected error for a branch without upstream")
+	}
+}
+
+func TestParseCommitLog(t *testing.T) {
+	logger, _ := logger.NewLogger([]string{}, "")
+
+	output := "1a2b3c4\x1fAlice\x1f1700000000\x1fFix: a|b \x1f c\n" +
+		"malformed line\n" +
+		"5d6e7f8\x1fBob\x1fnot-a-time\x1fSecond\n"
+
+	commits, err := parseCommitLog(output, logger)
+	if err != nil {
+		t.Fatalf("parseCommitLog failed: %v", err)
+	}
+	want := []types.Commit{
+		{Hash: "1a2b3c4", Author: "Alice", Subject: "Fix: a|b \x1f c", Time: time.Unix(1700000000, 0)},
+		{Hash: "5d6e7f8", Author: "Bob", Subject: "Second"},
+	}
+	if len(commits) != len(want) {
+		t.Fatalf("Got %d commits, want %d: %+v", len(commits), len(want), commits)
+	}
+	for i := range want {
+		if commits[i] != want[i] {
+			t.Errorf("Commit %d = %+v, want %+v", i, commits[i], want[i])
+		}
+	}
+}
+
+func TestGetChangedFilesReal(t *testing.T) {
+	testEnv := setupTestRepos(t)
+	logger, _ := logger.NewLogger([]string{}, "")
+	ctx := context.Background()
+
+	tests := []struct {
+		repo string
+		want types.FileChange
+	}{
+		{"repo_modified", types.FileChange{Status: " M", Path: "initial_file"}},
+		{"repo_staged", types.FileChange{Status: "A ", Path: "staged_file"}},
+		{"repo_untracked", types.FileChange{Status: "??", Path: "untracked_file"}},
+	}
+
+	for _, tt := range tests {
+		files, err := GetChangedFiles(ctx, filepath.Join(testEnv, tt.repo), logger)
+		if err != nil {
+			t.Fatalf("GetChangedFiles failed for %s: %v", tt.repo, err)
+		}
+		if len(files) != 1 || files[0] != tt.want {
+			t.Errorf("%s: files = %+v, want [%+v]", tt.repo, files, tt.want)
+		}
+	}
+}
+
+func TestParseChangedFiles(t *testing.T) {
+	logger, _ := logger.NewLogger([]string{}, "")
+
+	output := " M src/main.go\x00R  new name.go\x00old name.go\x00?? notes.txt\x00bad\x00"
+	files := parseChangedFiles(output, logger)
+
+	want := []types.FileChange{
+		{Status: " M", Path: "src/main.go"},
+		{Status: "R ", Path: "new name.go", OrigPath: "old name.go"},
+		{Status: "??", Path: "notes.txt"},
+	}
+	if len(files) != len(want) {
+		t.Fatalf("Got %d files, want %d: %+v", len(files), len(want), files)
+	}
+	for i := range want {
+		if files[i] != want[i] {
+			t.Errorf("File %d = %+v, want %+v", i, files[i], want[i])
+		}
+	}
+}
+
+func TestPullPushReal(t *testing.T) {
+	testEnv := setupTestRepos(t)
+	logger, _ := logger.NewLogger([]string{}, "")
+	ctx := context.Background()
+
+	dir := t.TempDir()
+	remote := filepath.Join(dir, "remote.git")
+	work := filepath.Join(dir, "work")
+	run := func(dir string, args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		if output, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v failed: %v\n%s", args, err, output)
+		}
+	}
+	run(dir, "clone", "--quiet", "--bare", filepath.Join(testEnv, "remote_repo.git"), remote)
+	run(dir, "clone", "--quiet", remote, work)
+	run(work, "config", "user.email", "test@example.com")
+	run(work, "config", "user.name", "Test User")
+
+	currentBranch := func() types.BranchSyncStatus {
+		t.Helper()
+		result, err := GetRepoStatus(ctx, work, BackendExec, logger)
+		if err != nil {
+			t.Fatalf("GetRepoStatus failed: %v", err)
+		}
+		for _, b := range result.Branches {
+			if b.Current {
+				return b
+			}
+		}
+		return types.BranchSyncStatus{}
+	}
+
+	run(work, "reset", "--quiet", "--hard", "HEAD~1")
+	if b := currentBranch(); b.Behind == 0 {
+		t.Fatalf("Expected branch to be behind before pull, got %+v", b)
+	}
+	if err := Pull(ctx, work, logger); err != nil {
+		t.Fatalf("Pull failed: %v", err)
+	}
+	if b := currentBranch(); b.Behind != 0 {
+		t.Errorf("Behind = %d after pull, want 0", b.Behind)
+	}
+
+	run(work, "commit", "--quiet", "--allow-empty", "-m", "Unpushed")
+	if err := Push(ctx, work, logger); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+	if b := currentBranch(); b.Ahead != 0 {
+		t.Errorf("Ahead = %d after push, want 0", b.Ahead)
+	}
+
+	run(work, "remote", "set-url", "origin", filepath.Join(dir, "missing.git"))
+	err := Pull(ctx, work, logger)
+	if err == nil || !strings.HasPrefix(err.Error(), "git pull failed: ") {
+		t.Errorf("Expected git pull failure, got %v", err)
+	}
+}
+
+func TestGetStashStatusReal(t *testing.T) {
+	testEnv := setupTestRepos(t)
+	logger, _ := logger.NewLogger([]string{}, "")
+	ctx := context.Background()
+
+	result, err := GetRepoStatus(ctx, filepath.Join(testEnv, "repo_stashed"), BackendExec, logger)
+	if err != nil {
+		t.Fatalf("GetRepoStatus failed: %v", err)
+	}
+	if !result.HasStash || result.Stash.Count != 2 {
+		t.Errorf("Expected 2 stash entries, got %+v", result.Stash)
+	}
+	if result.Stash.Oldest.IsZero() || time.Since(result.Stash.Oldest) < 0 {
+		t.Errorf("Unexpected oldest stash time: %v", result.Stash.Oldest)
+	}
+	if result.HasUncommitted {
+		t.Error("Stashed changes should not count as uncommitted")
+	}
+
+	result, err = GetRepoStatus(ctx, filepath.Join(testEnv, "repo_synced"), BackendExec, logger)
+	if err != nil {
+		t.Fatalf("GetRepoStatus failed: %v", err)
+	}
+	if result.HasStash || result.Stash.Count != 0 {
+		t.Errorf("Expected no stash, got %+v", result.Stash)
+	}
+}
+
+func TestParseBranchRefs(t *testing.T) {
+	logger, _ := logger.NewLogger([]string{}, "")
+
+	refs := parseBranchRefs("1700000100\x001690000000\x00refs/heads/main\x00origin\x00refs/remotes/fork/main\n"+
+		"1700000200\x001700000200\x00refs/heads/feature/x\x00\x00\n"+
+		"bad\x001700000200\x00refs/heads/broken\x00\x00\n"+
+		"1700000300 refs/heads/old origin\n", logger)
+
+	want := map[string]branchRef{
+		"main":      {lastCommit: time.Unix(1700000100, 0), lastAuthored: time.Unix(1690000000, 0), remote: "origin", push: "refs/remotes/fork/main"},
+		"feature/x": {lastCommit: time.Unix(1700000200, 0), lastAuthored: time.Unix(1700000200, 0)},
+	}
+	if len(refs) != len(want) {
+		t.Fatalf("Got %v, want %v", refs, want)
+	}
+	for name, w := range want {
+		if refs[name] != w {
+			t.Errorf("%s = %+v, want %+v", name, refs[name], w)
+		}
+	}
+}
+
+func TestParseStashList(t *testing.T) {
+	logger, _ := logger.NewLogger([]string{}, "")
+
+	status := parseStashList("1700000300\n1700000100\n1700000200\n", logger)
+
+	if status.Count != 3 {
+		t.Errorf("Count = %d, want 3", status.Count)
+	}
+	if !status.Oldest.Equal(time.Unix(1700000100, 0)) {
+		t.Errorf("Oldest = %v, want %v", status.Oldest, time.Unix(1700000100, 0))
+	}
+}
+
+func TestGetOperationStateReal(t *testing.T) {
+	testEnv := setupTestRepos(t)
+
+	tests := []struct {
+		repoName string
+		want     types.OperationState
+	}{
+		{"repo_synced", types.OperationState{}},
+		{"repo_merging", types.OperationState{Operation: types.OperationMerge}},
+		{"repo_rebasing", types.OperationState{Operation: types.OperationRebase, Step: 1, Total: 2}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.repoName, func(t *testing.T) {
+			state, err := GetOperationState(filepath.Join(testEnv, tt.repoName))
+			if err != nil {
+				t.Fatalf("GetOperationState failed: %v", err)
+			}
+			if state != tt.want {
+				t.Errorf("State = %+v, want %+v", state, tt.want)
+			}
 		})
 	}
 }
+
+func TestGetOperationStateMarkers(t *testing.T) {
+	tests := []struct {
+		files map[string]string
+		want  types.OperationState
+	}{
+		{map[string]string{"CHERRY_PICK_HEAD": "abc\n"}, types.OperationState{Operation: types.OperationCherryPick}},
+		{map[string]string{"REVERT_HEAD": "abc\n"}, types.OperationState{Operation: types.OperationRevert}},
+		{map[string]string{"BISECT_LOG": "# bad: abc\n"}, types.OperationState{Operation: types.OperationBisect}},
+		{
+			map[string]string{"rebase-apply/applying": "", "rebase-apply/next": "2\n", "rebase-apply/last": "5\n"},
+			types.OperationState{Operation: types.OperationAm, Step: 2, Total: 5},
+		},
+		{
+			map[string]string{"rebase-apply/next": "3\n", "rebase-apply/last": "7\n"},
+			types.OperationState{Operation: types.OperationRebase, Step: 3, Total: 7},
+		},
+	}
+
+	for _, tt := range tests {
+		repo := t.TempDir()
+		for name, content := range tt.files {
+			path := filepath.Join(repo, ".git", name)
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		state, err := GetOperationState(repo)
+		if err != nil {
+			t.Fatalf("GetOperationState failed: %v", err)
+		}
+		if state != tt.want {
+			t.Errorf("State = %+v, want %+v", state, tt.want)
+		}
+	}
+}
+
+func TestParsePorcelainV2EntryCodes(t *testing.T) {
+	logger, _ := logger.NewLogger([]string{}, "")
+
+	tests := []struct {
+		line string
+		want types.WorkdirStatus
+	}{
+		{"1 .M N... 100644 100644 100644 aaaaaaaa bbbbbbbb file.txt", types.WorkdirStatus{Modified: 1}},
+		{"1 .T N... 100644 100644 100644 aaaaaaaa bbbbbbbb file.txt", types.WorkdirStatus{TypeChanged: 1}},
+		{"1 .A N... 100644 100644 100644 aaaaaaaa bbbbbbbb file.txt", types.WorkdirStatus{}},
+		{"1 .D N... 100644 100644 100644 aaaaaaaa bbbbbbbb file.txt", types.WorkdirStatus{Deleted: 1}},
+		{"1 M. N... 100644 100644 100644 aaaaaaaa bbbbbbbb file.txt", types.WorkdirStatus{Staged: 1}},
+		{"1 MM N... 100644 100644 100644 aaaaaaaa bbbbbbbb file.txt", types.WorkdirStatus{Modified: 1, Staged: 1}},
+		{"1 MT N... 100644 100644 100644 aaaaaaaa bbbbbbbb file.txt", types.WorkdirStatus{Staged: 1, TypeChanged: 1}},
+		{"1 MA N... 100644 100644 100644 aaaaaaaa bbbbbbbb file.txt", types.WorkdirStatus{Staged: 1}},
+		{"1 MD N... 100644 100644 100644 aaaaaaaa bbbbbbbb file.txt", types.WorkdirStatus{Staged: 1, Deleted: 1}},
+		{"1 T. N... 100644 100644 100644 aaaaaaaa bbbbbbbb file.txt", types.WorkdirStatus{Staged: 1, TypeChanged: 1}},
+		{"1 TM N... 100644 100644 100644 aaaaaaaa bbbbbbbb file.txt", types.WorkdirStatus{Modified: 1, Staged: 1, TypeChanged: 1}},
+		{"1 TT N... 100644 100644 100644 aaaaaaaa bbbbbbbb file.txt", types.WorkdirStatus{Staged: 1, TypeChanged: 1}},
+		{"1 TA N... 100644 100644 100644 aaaaaaaa bbbbbbbb file.txt", types.WorkdirStatus{Staged: 1, TypeChanged: 1}},
+		{"1 TD N... 100644 100644 100644 aaaaaaaa bbbbbbbb file.txt", types.WorkdirStatus{Staged: 1, Deleted: 1, TypeChanged: 1}},
+		{"1 A. N... 100644 100644 100644 aaaaaaaa bbbbbbbb file.txt", types.WorkdirStatus{Staged: 1}},
+		{"1 AM N... 100644 100644 100644 aaaaaaaa bbbbbbbb file.txt", types.WorkdirStatus{Modified: 1, Staged: 1}},
+		{"1 AT N... 100644 100644 100644 aaaaaaaa bbbbbbbb file.txt", types.WorkdirStatus{Staged: 1, TypeChanged: 1}},
+		{"1 AA N... 100644 100644 100644 aaaaaaaa bbbbbbbb file.txt", types.WorkdirStatus{Staged: 1}},
+		{"1 AD N... 100644 100644 100644 aaaaaaaa bbbbbbbb file.txt", types.WorkdirStatus{Staged: 1, Deleted: 1}},
+		{"1 D. N... 100644 100644 100644 aaaaaaaa bbbbbbbb file.txt", types.WorkdirStatus{Staged: 1, Deleted: 1}},
+		{"1 DM N... 100644 100644 100644 aaaaaaaa bbbbbbbb file.txt", types.WorkdirStatus{Modified: 1, Staged: 1, Deleted: 1}},
+		{"1 DT N... 100644 100644 100644 aaaaaaaa bbbbbbbb file.txt", types.WorkdirStatus{Staged: 1, Deleted: 1, TypeChanged: 1}},
+		{"1 DA N... 100644 100644 100644 aaaaaaaa bbbbbbbb file.txt", types.WorkdirStatus{Staged: 1, Deleted: 1}},
+		{"1 DD N... 100644 100644 100644 aaaaaaaa bbbbbbbb file.txt", types.WorkdirStatus{Staged: 1, Deleted: 1}},
+		{"2 R. N... 100644 100644 100644 aaaaaaaa bbbbbbbb R100 new.txt\told.txt", types.WorkdirStatus{Staged: 1, Renamed: 1}},
+		{"2 RM N... 100644 100644 100644 aaaaaaaa bbbbbbbb R100 new.txt\told.txt", types.WorkdirStatus{Modified: 1, Staged: 1, Renamed: 1}},
+		{"2 RT N... 100644 100644 100644 aaaaaaaa bbbbbbbb R100 new.txt\told.txt", types.WorkdirStatus{Staged: 1, Renamed: 1, TypeChanged: 1}},
+		{"2 RD N... 100644 100644 100644 aaaaaaaa bbbbbbbb R100 new.txt\told.txt", types.WorkdirStatus{Staged: 1, Deleted: 1, Renamed: 1}},
+		{"2 C. N... 100644 100644 100644 aaaaaaaa bbbbbbbb C100 new.txt\told.txt", types.WorkdirStatus{Staged: 1}},
+		{"2 CM N... 100644 100644 100644 aaaaaaaa bbbbbbbb C100 new.txt\told.txt", types.WorkdirStatus{Modified: 1, Staged: 1}},
+		{"2 CT N... 100644 100644 100644 aaaaaaaa bbbbbbbb C100 new.txt\told.txt", types.WorkdirStatus{Staged: 1, TypeChanged: 1}},
+		{"2 CD N... 100644 100644 100644 aaaaaaaa bbbbbbbb C100 new.txt\told.txt", types.WorkdirStatus{Staged: 1, Deleted: 1}},
+		{"u DD N... 100644 100644 100644 100644 aaaaaaaa bbbbbbbb cccccccc file.txt", types.WorkdirStatus{Conflicted: 1}},
+		{"u AU N... 100644 100644 100644 100644 aaaaaaaa bbbbbbbb cccccccc file.txt", types.WorkdirStatus{Conflicted: 1}},
+		{"u UD N... 100644 100644 100644 100644 aaaaaaaa bbbbbbbb cccccccc file.txt", types.WorkdirStatus{Conflicted: 1}},
+		{"u UA N... 100644 100644 100644 100644 aaaaaaaa bbbbbbbb cccccccc file.txt", types.WorkdirStatus{Conflicted: 1}},
+		{"u DU N... 100644 100644 100644 100644 aaaaaaaa bbbbbbbb cccccccc file.txt", types.WorkdirStatus{Conflicted: 1}},
+		{"u AA N... 100644 100644 100644 100644 aaaaaaaa bbbbbbbb cccccccc file.txt", types.WorkdirStatus{Conflicted: 1}},
+		{"u UU N... 100644 100644 100644 100644 aaaaaaaa bbbbbbbb cccccccc file.txt", types.WorkdirStatus{Conflicted: 1}},
+		{"? untracked.txt", types.WorkdirStatus{Untracked: 1}},
+		{"! ignored.txt", types.WorkdirStatus{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(strings.Fields(tt.line)[0]+" "+strings.Fields(tt.line)[1], func(t *testing.T) {
+			status, _ := parsePorcelainV2(tt.line+"\n", logger)
+			if status != tt.want {
+				t.Errorf("parsePorcelainV2(%q) = %+v, want %+v", tt.line, status, tt.want)
+			}
+		})
+	}
+}
+
+func TestParsePorcelainV2Paths(t *testing.T) {
+	logger, _ := logger.NewLogger([]string{}, "")
+	output := "1 .M N... 100644 100644 100644 aaaaaaaa aaaaaaaa my file.txt\n" +
+		"1 M. N... 100644 100644 100644 aaaaaaaa bbbbbbbb other.txt\n" +
+		"2 R. N... 100644 100644 100644 aaaaaaaa aaaaaaaa R100 new name.txt\told name.txt\n" +
+		"? new file.txt\n" +
+		"? \"caf\\303\\251.txt\"\n"
+
+	status, paths := parsePorcelainV2(output, logger)
+
+	wantStatus := types.WorkdirStatus{Modified: 1, Staged: 2, Untracked: 2, Renamed: 1}
+	if status != wantStatus {
+		t.Errorf("Status = %+v, want %+v", status, wantStatus)
+	}
+
+	wantPaths := []string{"my file.txt", "other.txt", "new name.txt", "new file.txt", "café.txt"}
+	if !reflect.DeepEqual(paths, wantPaths) {
+		t.Errorf("Paths = %q, want %q", paths, wantPaths)
+	}
+}
+
+func TestBranchActionsReal(t *testing.T) {
+	testEnv := setupTestRepos(t)
+	logger, _ := logger.NewLogger([]string{}, "")
+	ctx := context.Background()
+
+	dir := t.TempDir()
+	remote := filepath.Join(dir, "remote.git")
+	work := filepath.Join(dir, "work")
+	run := func(dir string, args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		if output, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v failed: %v\n%s", args, err, output)
+		}
+	}
+	run(dir, "clone", "--quiet", "--bare", filepath.Join(testEnv, "remote_repo.git"), remote)
+	run(dir, "clone", "--quiet", remote, work)
+	run(work, "config", "user.email", "test@example.com")
+	run(work, "config", "user.name", "Test User")
+
+	run(work, "branch", "--quiet", "feature")
+	run(work, "branch", "--quiet", "topic")
+	run(work, "branch", "--quiet", "--set-upstream-to=origin/master", "topic")
+	if _, _, err := GetPushDestination(ctx, work, "feature"); err == nil {
+		t.Error("Expected an error for a branch without upstream")
+	}
+	run(work, "push", "--quiet", "-u", "origin", "feature")
+	remoteName, ref, err := GetPushDestination(ctx, work, "feature")
+	if err != nil || remoteName != "origin" || ref != "refs/heads/feature" {
+		t.Errorf("GetPushDestination = %q, %q, %v; want origin, refs/heads/feature", remoteName, ref, err)
+	}
+
+	// Triangular workflow: pull from origin, push to a fork
+	run(work, "remote", "add", "fork", filepath.Join(dir, "fork.git"))
+	run(work, "config", "remote.pushDefault", "fork")
+	remoteName, ref, err = GetPushDestination(ctx, work, "feature")
+	if err != nil || remoteName != "fork" || ref != "refs/heads/feature" {
+		t.Errorf("GetPushDestination = %q, %q, %v; want fork, refs/heads/feature", remoteName, ref, err)
+	}
+	run(work, "config", "push.default", "upstream")
+	if _, _, err := GetPushDestination(ctx, work, "feature"); err == nil {
+		t.Error("Expected an error when push.default upstream pushes to another remote")
+	}
+	run(work, "config", "--unset", "push.default")
+	run(work, "config", "--unset", "remote.pushDefault")
+
+	// Simple pushes refuse a differently named upstream, and a local
+	// upstream is nothing to push to
+	if _, _, err := GetPushDestination(ctx, work, "topic"); err == nil {
+		t.Error("Expected an error for an upstream with a different name")
+	}
+	run(work, "branch", "--quiet", "--set-upstream-to=master", "topic")
+	if _, _, err := GetPushDestination(ctx, work, "topic"); err == nil || !strings.Contains(err.Error(), "local branch") {
+		t.Errorf("Expected an error for a local upstream, got %v", err)
+	}
+	run(work, "push", "--quiet", "--set-upstream", "origin", "topic")
+	run(work, "config", "push.default", "upstream")
+	remoteName, ref, err = GetPushDestination(ctx, work, "topic")
+	if err != nil || remoteName != "origin" || ref != "refs/heads/topic" {
+		t.Errorf("GetPushDestination = %q, %q, %v; want origin, refs/heads/topic", remoteName, ref, err)
+	}
+	run(work, "config", "--unset", "push.default")
+
+	// Push a branch that is not checked out
+	run(work, "checkout", "--quiet", "feature")
+	run(work, "commit", "--quiet", "--allow-empty", "-m", "Feature work")
+	run(work, "checkout", "--quiet", "-")
+	if err := PushBranch(ctx, work, "feature", logger); err != nil {
+		t.Fatalf("PushBranch failed: %v", err)
+	}
+	cmd := exec.Command("git", "rev-parse", "refs/heads/feature")
+	cmd.Dir = remote
+	remoteTip, _ := cmd.Output()
+	cmd = exec.Command("git", "rev-parse", "refs/heads/feature")
+	cmd.Dir = work
+	localTip, _ := cmd.Output()
+	if len(remoteTip) == 0 || string(remoteTip) != string(localTip) {
+		t.Errorf("Remote feature at %q after push, want %q", remoteTip, localTip)
+	}
+
+	// git branch -d checks against the upstream while it exists, so make it
+	// gone as prune-gone would find it
+	run(work, "push", "--quiet", "origin", "--delete", "feature")
+	run(work, "fetch", "--quiet", "--prune")
+
+	merged, err := IsMerged(ctx, work, "feature")
+	if err != nil || merged {
+		t.Errorf("IsMerged(feature) = %v, %v; want false", merged, err)
+	}
+	if err := DeleteBranch(ctx, work, "feature", logger); err == nil || !strings.HasPrefix(err.Error(), "git branch -d failed: ") {
+		t.Errorf("Expected git branch -d failure for an unmerged branch, got %v", err)
+	}
+
+	run(work, "merge", "--quiet", "--ff-only", "refs/heads/feature")
+	merged, err = IsMerged(ctx, work, "feature")
+	if err != nil || !merged {
+		t.Errorf("IsMerged(feature) = %v, %v after merge; want true", merged, err)
+	}
+	if err := DeleteBranch(ctx, work, "feature", logger); err != nil {
+		t.Errorf("DeleteBranch failed: %v", err)
+	}
+}
+
+func TestGetUnpushedTagsReal(t *testing.T) {
+	testEnv := setupTestRepos(t)
+	logger, _ := logger.NewLogger([]string{}, "")
+	ctx := context.Background()
+
+	dir := t.TempDir()
+	remote := filepath.Join(dir, "remote.git")
+	work := filepath.Join(dir, "work")
+	run := func(dir string, args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		if output, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v failed: %v\n%s", args, err, output)
+		}
+	}
+	run(dir, "clone", "--quiet", "--bare", filepath.Join(testEnv, "remote_repo.git"), remote)
+	run(dir, "clone", "--quiet", remote, work)
+	run(work, "config", "user.email", "test@example.com")
+	run(work, "config", "user.name", "Test User")
+
+	run(work, "tag", "v1.0")
+	run(work, "push", "--quiet", "origin", "v1.0")
+	run(work, "tag", "v1.1") // on a pushed commit, only ls-remote can tell
+	run(work, "commit", "--quiet", "--allow-empty", "-m", "Release")
+	run(work, "tag", "-a", "-m", "Release 2.0", "v2.0")
+
+	tags, partial, err := GetUnpushedTags(ctx, work, false, logger)
+	if err != nil {
+		t.Fatalf("GetUnpushedTags failed: %v", err)
+	}
+	if strings.Join(tags, ",") != "v2.0" || !partial {
+		t.Errorf("Unpushed tags from remote-tracking branches = %v, %v; want [v2.0], partial", tags, partial)
+	}
+
+	tags, partial, err = GetUnpushedTags(ctx, work, true, logger)
+	if err != nil {
+		t.Fatalf("GetUnpushedTags failed: %v", err)
+	}
+	if strings.Join(tags, ",") != "v1.1,v2.0" || partial {
+		t.Errorf("Unpushed tags from ls-remote = %v, %v; want [v1.1 v2.0], complete", tags, partial)
+	}
+
+	// An unreachable remote falls back to remote-tracking branches
+	run(work, "remote", "set-url", "origin", filepath.Join(dir, "missing.git"))
+	tags, partial, err = GetUnpushedTags(ctx, work, true, logger)
+	if err != nil || strings.Join(tags, ",") != "v2.0" || !partial {
+		t.Errorf("GetUnpushedTags with unreachable remote = %v, %v, %v; want [v2.0], partial", tags, partial, err)
+	}
+
+	// Without remotes there is nowhere to push tags to
+	run(work, "remote", "remove", "origin")
+	tags, partial, err = GetUnpushedTags(ctx, work, false, logger)
+	if err != nil || len(tags) != 0 || partial {
+		t.Errorf("GetUnpushedTags without remotes = %v, %v, %v; want none", tags, partial, err)
+	}
+}
+
+func TestParseTagLists(t *testing.T) {
+	logger, _ := logger.NewLogger([]string{}, "")
+
+	tags, err := parseLocalTags("v1.0\x00aaa\x00\nv2.0\x00bbb\x00ccc\nbroken\n", logger)
+	if err != nil {
+		t.Fatalf("parseLocalTags failed: %v", err)
+	}
+	want := []localTag{{name: "v1.0", commit: "aaa"}, {name: "v2.0", commit: "ccc"}}
+	if len(tags) != len(want) || tags[0] != want[0] || tags[1] != want[1] {
+		t.Errorf("parseLocalTags = %+v, want %+v", tags, want)
+	}
+
+	remote := parseLsRemoteTags("aaa\trefs/tags/v1.0\nbbb\trefs/tags/v2.0^{}\nccc\trefs/heads/main\n")
+	if len(remote) != 2 || !remote["v1.0"] || !remote["v2.0"] {
+		t.Errorf("parseLsRemoteTags = %v", remote)
+	}
+}
+
+func TestCompareAllRemotesReal(t *testing.T) {
+	testEnv := setupTestRepos(t)
+	logger, _ := logger.NewLogger([]string{}, "")
+	ctx := context.Background()
+
+	dir := t.TempDir()
+	canonical := filepath.Join(dir, "canonical.git")
+	fork := filepath.Join(dir, "fork.git")
+	other := filepath.Join(dir, "other")
+	work := filepath.Join(dir, "work")
+	run := func(dir string, args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-c", "user.name=Test User", "-c", "user.email=test@example.com"}, args...)...)
+		cmd.Dir = dir
+		if output, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v failed: %v\n%s", args, err, output)
+		}
+	}
+	run(dir, "clone", "--quiet", "--bare", filepath.Join(testEnv, "remote_repo.git"), canonical)
+	run(dir, "clone", "--quiet", "--bare", canonical, fork)
+	run(dir, "clone", "--quiet", fork, work)
+	run(work, "remote", "add", "upstream", canonical)
+
+	// The canonical repository moves on, the fork's main does not
+	run(dir, "clone", "--quiet", canonical, other)
+	run(other, "commit", "--quiet", "--allow-empty", "-m", "Upstream 1")
+	run(other, "commit", "--quiet", "--allow-empty", "-m", "Upstream 2")
+	run(other, "push", "--quiet")
+	run(work, "fetch", "--quiet", "upstream")
+
+	result, err := GetRepoStatus(ctx, work, BackendExec, logger)
+	if err != nil {
+		t.Fatalf("GetRepoStatus failed: %v", err)
+	}
+	if result.HasUnsynced || len(result.Branches) != 0 {
+		t.Fatalf("Expected main to be in sync with origin, got %+v", result.Branches)
+	}
+
+	if err := CompareAllRemotes(ctx, work, result, logger); err != nil {
+		t.Fatalf("CompareAllRemotes failed: %v", err)
+	}
+	if !result.HasUnsynced || len(result.Branches) != 1 {
+		t.Fatalf("Expected main to be listed as behind upstream, got %+v", result.Branches)
+	}
+	b := result.Branches[0]
+	want := []types.RemoteSyncStatus{{Ref: "upstream/" + b.Name, Remote: "upstream", Behind: 2}}
+	if !b.Current || b.Upstream != "origin/"+b.Name || b.Remote != "origin" || !reflect.DeepEqual(b.Remotes, want) {
+		t.Errorf("Branch = %+v, want current, tracking origin, with remotes %+v", b, want)
+	}
+
+	// A branch already listed keeps its own state and gains the comparisons
+	run(work, "commit", "--quiet", "--allow-empty", "-m", "Local")
+	result, err = GetRepoStatus(ctx, work, BackendNative, logger)
+	if err != nil {
+		t.Fatalf("GetRepoStatus failed: %v", err)
+	}
+	if err := CompareAllRemotes(ctx, work, result, logger); err != nil {
+		t.Fatalf("CompareAllRemotes failed: %v", err)
+	}
+	want = []types.RemoteSyncStatus{{Ref: "upstream/" + b.Name, Remote: "upstream", Ahead: 1, Behind: 2}}
+	if len(result.Branches) != 1 || result.Branches[0].Ahead != 1 || !reflect.DeepEqual(result.Branches[0].Remotes, want) {
+		t.Errorf("Branches = %+v, want ahead 1 with remotes %+v", result.Branches, want)
+	}
+}
+
+func TestPushDestinationReal(t *testing.T) {
+	testEnv := setupTestRepos(t)
+	logger, _ := logger.NewLogger([]string{}, "")
+	ctx := context.Background()
+
+	dir := t.TempDir()
+	canonical := filepath.Join(dir, "canonical.git")
+	fork := filepath.Join(dir, "fork.git")
+	work := filepath.Join(dir, "work")
+	run := func(dir string, args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-c", "user.name=Test User", "-c", "user.email=test@example.com"}, args...)...)
+		cmd.Dir = dir
+		if output, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v failed: %v\n%s", args, err, output)
+		}
+	}
+	run(dir, "clone", "--quiet", "--bare", filepath.Join(testEnv, "remote_repo.git"), canonical)
+	run(dir, "clone", "--quiet", "--bare", canonical, fork)
+	run(dir, "clone", "--quiet", canonical, work)
+	run(work, "remote", "add", "fork", fork)
+	run(work, "fetch", "--quiet", "fork")
+	run(work, "config", "remote.pushDefault", "fork")
+	run(work, "config", "push.default", "current")
+
+	status := func(backend string) []types.BranchSyncStatus {
+		t.Helper()
+		result, err := GetRepoStatus(ctx, work, backend, logger)
+		if err != nil {
+			t.Fatalf("GetRepoStatus failed: %v", err)
+		}
+		return result.Branches
+	}
+
+	// Pushed to the fork but not merged upstream: ahead of origin only
+	run(work, "commit", "--quiet", "--allow-empty", "-m", "Pushed")
+	run(work, "push", "--quiet")
+	run(work, "commit", "--quiet", "--allow-empty", "-m", "Not pushed")
+	branches := status(BackendExec)
+	if len(branches) != 1 {
+		t.Fatalf("Expected one branch, got %+v", branches)
+	}
+	b := branches[0]
+	if b.Upstream != "origin/"+b.Name || b.Ahead != 2 || b.Push != "fork/"+b.Name || b.PushAhead != 1 || b.PushBehind != 0 || b.PushGone {
+		t.Errorf("Branch = %+v, want ahead 2 of origin and 1 of fork", b)
+	}
+
+	// A new branch tracking origin's branch has not been pushed to the fork
+	run(work, "checkout", "--quiet", "-b", "feature", "--track", "origin/"+b.Name)
+	run(work, "commit", "--quiet", "--allow-empty", "-m", "Feature")
+	branches = status(BackendExec)
+	if len(branches) != 2 || branches[0].Name != "feature" {
+		t.Fatalf("Expected feature and %s, got %+v", b.Name, branches)
+	}
+	if f := branches[0]; f.Push != "fork/feature" || !f.PushGone || f.PushAhead != 0 {
+		t.Errorf("Branch = %+v, want never pushed to fork/feature", f)
+	}
+
+	// The native backend leaves push destinations to git
+	if native := status(BackendNative); !reflect.DeepEqual(native, branches) {
+		t.Errorf("Native branches = %+v, want %+v", native, branches)
+	}
+
+	// Without a separate push destination nothing is recorded
+	run(work, "config", "--unset", "remote.pushDefault")
+	run(work, "config", "--unset", "push.default")
+	for _, b := range status(BackendExec) {
+		if b.Push != "" || b.PushAhead != 0 || b.PushGone {
+			t.Errorf("Branch %s has push destination %q", b.Name, b.Push)
+		}
+	}
+}
+
+func TestActivityTimesReal(t *testing.T) {
+	logger, _ := logger.NewLogger([]string{}, "")
+	ctx := context.Background()
+
+	repo := t.TempDir()
+	run := func(env []string, args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-c", "user.name=Test User", "-c", "user.email=test@example.com"}, args...)...)
+		cmd.Dir = repo
+		cmd.Env = append(os.Environ(), env...)
+		if output, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v failed: %v\n%s", args, err, output)
+		}
+	}
+	run(nil, "init", "--quiet", "--initial-branch=main")
+	// A commit authored long before it was committed, as after a rebase
+	run([]string{"GIT_AUTHOR_DATE=@1600000000 +0000", "GIT_COMMITTER_DATE=@1700000000 +0000"},
+		"commit", "--quiet", "--allow-empty", "-m", "Rebased")
+	run(nil, "checkout", "--quiet", "-b", "feature")
+
+	modified := time.Unix(1710000000, 0)
+	for _, name := range []string{"old.txt", "new.txt"} {
+		file := filepath.Join(repo, name)
+		if err := os.WriteFile(file, []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(file, modified, modified); err != nil {
+			t.Fatal(err)
+		}
+		modified = modified.Add(time.Hour)
+	}
+
+	for _, backend := range Backends {
+		result, err := GetRepoStatus(ctx, repo, backend, logger)
+		if err != nil {
+			t.Fatalf("GetRepoStatus(%s) failed: %v", backend, err)
+		}
+		if !result.LastCommit.Equal(time.Unix(1700000000, 0)) || !result.LastAuthored.Equal(time.Unix(1600000000, 0)) {
+			t.Errorf("%s: LastCommit = %v, LastAuthored = %v", backend, result.LastCommit, result.LastAuthored)
+		}
+		if want := time.Unix(1710003600, 0); !result.LastModified.Equal(want) {
+			t.Errorf("%s: LastModified = %v, want %v", backend, result.LastModified, want)
+		}
+		if len(result.Branches) != 2 || !result.Branches[0].LastAuthored.Equal(time.Unix(1600000000, 0)) {
+			t.Errorf("%s: Branches = %+v, want both authored at 1600000000", backend, result.Branches)
+		}
+	}
+}
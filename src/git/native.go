@@ -0,0 +1,767 @@
+package git
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gitstatus/src/logger"
+	"gitstatus/src/types"
+)
+
+// errNativeUnsupported is returned when the native backend finds repository
+// features it does not implement; callers fall back to running git
+var errNativeUnsupported = errors.New("not supported by the native backend")
+
+// abbrevLength is the length of abbreviated object names, git's minimum
+const abbrevLength = 7
+
+// systemConfigFile is the system-wide config of git installed under /usr.
+// Other installations keep theirs elsewhere; GIT_CONFIG_SYSTEM points the
+// native backend at it.
+const systemConfigFile = "/etc/gitconfig"
+
+// supportedExtensions are the extensions.* settings that do not change how
+// refs or objects are stored
+var supportedExtensions = map[string]bool{
+	"noop":            true,
+	"preciousobjects": true,
+}
+
+// nativeRepo reads a repository's refs, config and objects directly from its
+// git directory
+type nativeRepo struct {
+	gitDir    string // per-worktree directory holding HEAD and its reflog
+	commonDir string // directory holding refs, packed-refs, config and objects
+	config    map[string][]string
+	refs      map[string]objectID // ref name to the object it points at
+	symrefs   map[string]string   // ref name to the ref it points at
+	shallow   map[objectID]bool
+	objects   *objectStore
+}
+
+// nativeStatus is what the native backend reads for GetRepoStatus
+type nativeStatus struct {
+	Branches        []types.BranchSyncStatus
+	DetachedHead    types.DetachedHead
+	HasDetachedHead bool
+	HeadTime        time.Time // committer time of HEAD
+	HeadAuthorTime  time.Time // author time of HEAD
+	Stash           types.StashStatus
+}
+
+// readNativeStatus computes branch, detached HEAD and stash status of the
+// repository at path without running git
+func readNativeStatus(path string, logger *logger.Logger) (*nativeStatus, error) {
+	repo, err := openNativeRepo(path)
+	if err != nil {
+		return nil, err
+	}
+	defer repo.objects.close()
+
+	status := &nativeStatus{}
+
+	status.Branches, err = repo.branches(logger)
+	if err != nil {
+		return nil, err
+	}
+
+	status.DetachedHead, status.HasDetachedHead, err = repo.detachedHead(path)
+	if err != nil {
+		return nil, err
+	}
+
+	status.HeadTime, status.HeadAuthorTime, err = repo.headTime()
+	if err != nil {
+		return nil, err
+	}
+
+	status.Stash, err = repo.stash()
+	if err != nil {
+		return nil, err
+	}
+
+	return status, nil
+}
+
+// openNativeRepo loads the config and refs of the repository at path and
+// opens its object database
+func openNativeRepo(path string) (*nativeRepo, error) {
+	gitDir, err := ResolveGitDir(path)
+	if err != nil {
+		return nil, err
+	}
+
+	repo := &nativeRepo{gitDir: gitDir, commonDir: gitDir}
+	if content, err := os.ReadFile(filepath.Join(gitDir, "commondir")); err == nil {
+		common := strings.TrimSpace(string(content))
+		if !filepath.IsAbs(common) {
+			common = filepath.Join(gitDir, common)
+		}
+		repo.commonDir = filepath.Clean(common)
+	}
+
+	if err := repo.loadConfig(); err != nil {
+		return nil, err
+	}
+	if err := repo.loadRefs(); err != nil {
+		return nil, err
+	}
+	if err := repo.loadShallow(); err != nil {
+		return nil, err
+	}
+
+	repo.objects, err = openObjectStore(filepath.Join(repo.commonDir, "objects"))
+	if err != nil {
+		return nil, err
+	}
+	return repo, nil
+}
+
+// loadConfig reads the system and global config files and then the
+// repository config, later values overriding earlier ones as in git, and
+// rejects settings that change where refs, objects or config live
+func (r *nativeRepo) loadConfig() error {
+	files, err := userConfigFiles()
+	if err != nil {
+		return err
+	}
+	r.config = make(map[string][]string)
+	for _, file := range files {
+		if err := r.mergeConfigFile(file, false); err != nil {
+			return err
+		}
+	}
+	if err := r.mergeConfigFile(filepath.Join(r.commonDir, "config"), true); err != nil {
+		return err
+	}
+
+	for key := range r.config {
+		if ext, ok := strings.CutPrefix(key, "extensions."); ok && !supportedExtensions[ext] {
+			return fmt.Errorf("%w: extensions.%s", errNativeUnsupported, ext)
+		}
+	}
+
+	if version := r.configValue("core.repositoryformatversion"); version != "" && version != "0" && version != "1" {
+		return fmt.Errorf("%w: repository format version %s", errNativeUnsupported, version)
+	}
+	return nil
+}
+
+// mergeConfigFile adds the settings of file to r.config. A missing file is
+// skipped unless required is set.
+func (r *nativeRepo) mergeConfigFile(file string, required bool) error {
+	content, err := os.ReadFile(file)
+	if os.IsNotExist(err) && !required {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	config, err := parseConfig(string(content))
+	if err != nil {
+		return fmt.Errorf("%s: %w", file, err)
+	}
+	for key, values := range config {
+		if strings.HasPrefix(key, "include.") || strings.HasPrefix(key, "includeif.") {
+			return fmt.Errorf("%w: config includes in %s", errNativeUnsupported, file)
+		}
+		r.config[key] = append(r.config[key], values...)
+	}
+	return nil
+}
+
+// userConfigFiles returns the system and global config files git reads
+// before the repository config, in that order. Config given in the
+// environment is not read and makes the native backend fall back to git.
+func userConfigFiles() ([]string, error) {
+	for _, name := range []string{"GIT_CONFIG_PARAMETERS", "GIT_CONFIG_COUNT"} {
+		if os.Getenv(name) != "" {
+			return nil, fmt.Errorf("%w: %s", errNativeUnsupported, name)
+		}
+	}
+
+	var files []string
+	if os.Getenv("GIT_CONFIG_NOSYSTEM") == "" {
+		if file := os.Getenv("GIT_CONFIG_SYSTEM"); file != "" {
+			files = append(files, file)
+		} else {
+			files = append(files, systemConfigFile)
+		}
+	}
+
+	if file := os.Getenv("GIT_CONFIG_GLOBAL"); file != "" {
+		return append(files, file), nil
+	}
+	home, _ := os.UserHomeDir()
+	if xdg := os.Getenv("XDG_CONFIG_HOME"); xdg != "" {
+		files = append(files, filepath.Join(xdg, "git", "config"))
+	} else if home != "" {
+		files = append(files, filepath.Join(home, ".config", "git", "config"))
+	}
+	if home != "" {
+		files = append(files, filepath.Join(home, ".gitconfig"))
+	}
+	return files, nil
+}
+
+// configValue returns the last value of key, or "" if it is not set
+func (r *nativeRepo) configValue(key string) string {
+	values := r.config[key]
+	if len(values) == 0 {
+		return ""
+	}
+	return values[len(values)-1]
+}
+
+// loadRefs reads packed-refs and then the loose refs, which take precedence
+func (r *nativeRepo) loadRefs() error {
+	r.refs = make(map[string]objectID)
+	r.symrefs = make(map[string]string)
+
+	if err := r.loadPackedRefs(); err != nil {
+		return err
+	}
+
+	refsDir := filepath.Join(r.commonDir, "refs")
+	return filepath.WalkDir(refsDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == refsDir {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() || strings.HasSuffix(path, ".lock") {
+			return nil
+		}
+
+		rel, err := filepath.Rel(r.commonDir, path)
+		if err != nil {
+			return err
+		}
+		name := filepath.ToSlash(rel)
+
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		value := strings.TrimSpace(string(content))
+
+		if target, ok := strings.CutPrefix(value, "ref:"); ok {
+			r.symrefs[name] = strings.TrimSpace(target)
+			delete(r.refs, name)
+			return nil
+		}
+		id, err := parseObjectID(value)
+		if err != nil {
+			return fmt.Errorf("ref %s: %w", name, err)
+		}
+		r.refs[name] = id
+		return nil
+	})
+}
+
+// loadPackedRefs reads the packed-refs file, skipping the peeled "^" lines
+func (r *nativeRepo) loadPackedRefs() error {
+	f, err := os.Open(filepath.Join(r.commonDir, "packed-refs"))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" || line[0] == '#' || line[0] == '^' {
+			continue
+		}
+		hexID, name, ok := strings.Cut(line, " ")
+		if !ok {
+			return fmt.Errorf("corrupt packed-refs line %q", line)
+		}
+		id, err := parseObjectID(hexID)
+		if err != nil {
+			return fmt.Errorf("packed-refs: %w", err)
+		}
+		r.refs[name] = id
+	}
+	return scanner.Err()
+}
+
+// loadShallow reads the commits whose parents are missing in a shallow clone
+func (r *nativeRepo) loadShallow() error {
+	r.shallow = make(map[objectID]bool)
+
+	content, err := os.ReadFile(filepath.Join(r.commonDir, "shallow"))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, line := range strings.Fields(string(content)) {
+		id, err := parseObjectID(line)
+		if err != nil {
+			return fmt.Errorf("shallow: %w", err)
+		}
+		r.shallow[id] = true
+	}
+	return nil
+}
+
+// resolveRef follows symbolic refs and returns the object name refers to
+func (r *nativeRepo) resolveRef(name string) (objectID, bool) {
+	for depth := 0; depth < 5; depth++ {
+		if id, ok := r.refs[name]; ok {
+			return id, true
+		}
+		target, ok := r.symrefs[name]
+		if !ok {
+			return objectID{}, false
+		}
+		name = target
+	}
+	return objectID{}, false
+}
+
+// readHead returns the branch HEAD is on, or the commit of a detached HEAD
+func (r *nativeRepo) readHead() (string, objectID, error) {
+	content, err := os.ReadFile(filepath.Join(r.gitDir, "HEAD"))
+	if err != nil {
+		return "", objectID{}, err
+	}
+	value := strings.TrimSpace(string(content))
+
+	if target, ok := strings.CutPrefix(value, "ref:"); ok {
+		return strings.TrimSpace(target), objectID{}, nil
+	}
+	id, err := parseObjectID(value)
+	if err != nil {
+		return "", objectID{}, fmt.Errorf("HEAD: %w", err)
+	}
+	return "", id, nil
+}
+
+// branches reports every local branch like git branch -vv: whether it is
+// current, its upstream state and how far it is ahead of and behind it
+func (r *nativeRepo) branches(logger *logger.Logger) ([]types.BranchSyncStatus, error) {
+	if setting := r.pushSetting(); setting != "" {
+		return nil, fmt.Errorf("%w: %s", errNativeUnsupported, setting)
+	}
+
+	headRef, _, err := r.readHead()
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for ref := range r.refs {
+		if strings.HasPrefix(ref, "refs/heads/") {
+			names = append(names, ref)
+		}
+	}
+	sort.Strings(names)
+
+	var branches []types.BranchSyncStatus
+	for _, ref := range names {
+		name := strings.TrimPrefix(ref, "refs/heads/")
+		b := types.BranchSyncStatus{
+			Name:    name,
+			Current: ref == headRef,
+		}
+
+		tip, ok, err := r.objects.peelToCommit(r.refs[ref])
+		if err != nil || !ok {
+			return nil, fmt.Errorf("branch %s does not point at a commit: %v", name, err)
+		}
+		b.LastCommit = time.Unix(r.objects.commits[tip].time, 0)
+		b.LastAuthored = time.Unix(r.objects.commits[tip].authorTime, 0)
+
+		upstream := r.upstreamRef(name)
+		if upstream != "" {
+			b.Upstream = strings.TrimPrefix(strings.TrimPrefix(upstream, "refs/remotes/"), "refs/heads/")
+			b.Remote = r.upstreamRemote(name)
+		}
+		switch upstreamID, exists := r.resolveRef(upstream); {
+		case upstream == "":
+			b.NoUpstream = true
+		case !exists:
+			b.Gone = true
+		default:
+			b.Ahead, b.Behind, err = countExclusive(r.objects, []objectID{tip}, []objectID{upstreamID}, r.shallow, true)
+			if err != nil {
+				return nil, fmt.Errorf("comparing %s with %s: %w", name, upstream, err)
+			}
+		}
+
+		logger.Debug("Native: branch %s (Current: %v, Upstream: %s, Ahead: %d, Behind: %d, Gone: %v)",
+			b.Name, b.Current, upstream, b.Ahead, b.Behind, b.Gone)
+		branches = append(branches, b)
+	}
+
+	return branches, nil
+}
+
+// headTime returns the committer and author times of the commit HEAD points
+// at, or zero times on a branch without commits
+func (r *nativeRepo) headTime() (time.Time, time.Time, error) {
+	headRef, head, err := r.readHead()
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	if headRef != "" {
+		var ok bool
+		if head, ok = r.resolveRef(headRef); !ok {
+			return time.Time{}, time.Time{}, nil
+		}
+	}
+
+	c, err := r.objects.commit(head)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	return time.Unix(c.time, 0), time.Unix(c.authorTime, 0), nil
+}
+
+// upstreamRef returns the remote-tracking ref (or local branch for remote
+// ".") that branch.<name>.merge maps to, or "" if there is none
+func (r *nativeRepo) upstreamRef(branch string) string {
+	merge := r.configValue("branch." + branch + ".merge")
+	if merge == "" {
+		return ""
+	}
+	if !strings.HasPrefix(merge, "refs/") {
+		merge = "refs/heads/" + merge
+	}
+
+	remote := r.upstreamRemote(branch)
+	switch remote {
+	case "":
+		return ""
+	case ".":
+		return merge
+	}
+
+	tracking := ""
+	for _, refspec := range r.config["remote."+remote+".fetch"] {
+		if dst, ok := mapRefspec(refspec, merge); ok {
+			tracking = dst
+		}
+	}
+	return tracking
+}
+
+// pushSetting returns the first config setting that can make branches push
+// somewhere other than their upstream, or "" if there is none. Push
+// destinations are only resolved by git.
+func (r *nativeRepo) pushSetting() string {
+	if r.configValue("remote.pushdefault") != "" {
+		return "remote.pushDefault"
+	}
+	if value := r.configValue("push.default"); value == "current" || value == "matching" {
+		return "push.default=" + value
+	}
+	for key := range r.config {
+		if strings.HasPrefix(key, "branch.") && strings.HasSuffix(key, ".pushremote") {
+			return key
+		}
+	}
+	return ""
+}
+
+// upstreamRemote returns the remote branch is configured to track, "." for a
+// local branch, or "" if there is none. Like git, a branch.<name>.merge
+// without a remote is no upstream.
+func (r *nativeRepo) upstreamRemote(branch string) string {
+	return r.configValue("branch." + branch + ".remote")
+}
+
+// mapRefspec maps ref through the src:dst fetch refspec, which may contain
+// one "*" on each side
+func mapRefspec(refspec, ref string) (string, bool) {
+	refspec = strings.TrimPrefix(refspec, "+")
+	if strings.HasPrefix(refspec, "^") {
+		return "", false
+	}
+	src, dst, ok := strings.Cut(refspec, ":")
+	if !ok || dst == "" {
+		return "", false
+	}
+
+	srcPrefix, srcSuffix, srcGlob := strings.Cut(src, "*")
+	if !srcGlob {
+		if src != ref {
+			return "", false
+		}
+		return dst, true
+	}
+	if !strings.HasPrefix(ref, srcPrefix) || !strings.HasSuffix(ref, srcSuffix) ||
+		len(ref) < len(srcPrefix)+len(srcSuffix) {
+		return "", false
+	}
+	matched := ref[len(srcPrefix) : len(ref)-len(srcSuffix)]
+
+	dstPrefix, dstSuffix, dstGlob := strings.Cut(dst, "*")
+	if !dstGlob {
+		return "", false
+	}
+	return dstPrefix + matched + dstSuffix, true
+}
+
+// detachedHead describes a detached HEAD the way git branch does, using the
+// last checkout recorded in the HEAD reflog. HEAD detached by a rebase or
+// bisect is not reported, the operation state covers it.
+func (r *nativeRepo) detachedHead(path string) (types.DetachedHead, bool, error) {
+	headRef, head, err := r.readHead()
+	if err != nil || headRef != "" {
+		return types.DetachedHead{}, false, err
+	}
+
+	op, err := GetOperationState(path)
+	if err != nil {
+		return types.DetachedHead{}, false, err
+	}
+	switch op.Operation {
+	case types.OperationRebase, types.OperationAm, types.OperationBisect:
+		return types.DetachedHead{}, false, nil
+	}
+
+	detached := types.DetachedHead{
+		Commit: head.String()[:abbrevLength],
+		From:   head.String()[:abbrevLength],
+	}
+
+	if target, switchedTo, ok := r.lastCheckout(); ok {
+		detached.From = switchedTo.String()[:abbrevLength]
+		if target != "HEAD" {
+			if ref, ok := r.dwimRef(target); ok {
+				refID, _ := r.resolveRef(ref)
+				peeled, _, _ := r.objects.peelToCommit(refID)
+				if refID == switchedTo || peeled == switchedTo {
+					ref = strings.TrimPrefix(ref, "refs/tags/")
+					ref = strings.TrimPrefix(ref, "refs/remotes/")
+					detached.From = ref
+				}
+			}
+		}
+		detached.Moved = switchedTo != head
+	}
+
+	var tips []objectID
+	for ref, id := range r.refs {
+		if !strings.HasPrefix(ref, "refs/heads/") && !strings.HasPrefix(ref, "refs/tags/") &&
+			!strings.HasPrefix(ref, "refs/remotes/") {
+			continue
+		}
+		tip, ok, err := r.objects.peelToCommit(id)
+		if err != nil {
+			return types.DetachedHead{}, false, fmt.Errorf("ref %s: %w", ref, err)
+		}
+		if ok {
+			tips = append(tips, tip)
+		}
+	}
+
+	detached.Orphaned, _, err = countExclusive(r.objects, []objectID{head}, tips, r.shallow, false)
+	if err != nil {
+		return types.DetachedHead{}, false, err
+	}
+
+	return detached, true, nil
+}
+
+// lastCheckout finds the last "checkout: moving from A to B" entry of the
+// HEAD reflog and returns B and the commit HEAD was moved to
+func (r *nativeRepo) lastCheckout() (string, objectID, bool) {
+	content, err := os.ReadFile(filepath.Join(r.gitDir, "logs", "HEAD"))
+	if err != nil {
+		return "", objectID{}, false
+	}
+
+	lines := strings.Split(strings.TrimRight(string(content), "\n"), "\n")
+	for i := len(lines) - 1; i >= 0; i-- {
+		entry, message, ok := strings.Cut(lines[i], "\t")
+		if !ok || !strings.HasPrefix(message, "checkout: moving from ") {
+			continue
+		}
+		to := strings.LastIndex(message, " to ")
+		if to < 0 {
+			continue
+		}
+		fields := strings.Fields(entry)
+		if len(fields) < 2 {
+			continue
+		}
+		id, err := parseObjectID(fields[1])
+		if err != nil {
+			continue
+		}
+		return message[to+len(" to "):], id, true
+	}
+	return "", objectID{}, false
+}
+
+// dwimRef expands a short ref name with git's lookup rules and returns the
+// full name when exactly one ref matches
+func (r *nativeRepo) dwimRef(name string) (string, bool) {
+	candidates := []string{
+		"refs/" + name,
+		"refs/tags/" + name,
+		"refs/heads/" + name,
+		"refs/remotes/" + name,
+		"refs/remotes/" + name + "/HEAD",
+	}
+	if strings.HasPrefix(name, "refs/") {
+		candidates = append([]string{name}, candidates...)
+	}
+
+	found := ""
+	for _, ref := range candidates {
+		if _, ok := r.resolveRef(ref); ok {
+			if found != "" {
+				return "", false
+			}
+			found = ref
+		}
+	}
+	return found, found != ""
+}
+
+// stash counts the entries of the refs/stash reflog and finds the oldest
+// stash commit
+func (r *nativeRepo) stash() (types.StashStatus, error) {
+	status := types.StashStatus{}
+
+	stashID, ok := r.resolveRef("refs/stash")
+	if !ok {
+		return status, nil
+	}
+
+	ids := []objectID{stashID}
+	if content, err := os.ReadFile(filepath.Join(r.commonDir, "logs", "refs", "stash")); err == nil {
+		ids = nil
+		for _, line := range strings.Split(string(content), "\n") {
+			fields := strings.Fields(line)
+			if len(fields) < 2 {
+				continue
+			}
+			id, err := parseObjectID(fields[1])
+			if err != nil {
+				return status, fmt.Errorf("stash reflog: %w", err)
+			}
+			ids = append(ids, id)
+		}
+	}
+
+	for _, id := range ids {
+		c, err := r.objects.commit(id)
+		if err != nil {
+			return status, err
+		}
+		status.Count++
+		created := time.Unix(c.time, 0)
+		if status.Oldest.IsZero() || created.Before(status.Oldest) {
+			status.Oldest = created
+		}
+	}
+	return status, nil
+}
+
+// parseConfig parses git config syntax into a map from "section.key" or
+// "section.subsection.key" to every value set for it, in order. Section and
+// key names are lowercased; subsection names are case sensitive.
+func parseConfig(content string) (map[string][]string, error) {
+	config := make(map[string][]string)
+	section := ""
+
+	lines := strings.Split(content, "\n")
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
+		for strings.HasSuffix(line, "\\") && i+1 < len(lines) {
+			i++
+			line = strings.TrimSuffix(line, "\\") + strings.TrimSpace(lines[i])
+		}
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+
+		if line[0] == '[' {
+			end := strings.LastIndex(line, "]")
+			if end < 0 {
+				return nil, fmt.Errorf("bad config line %d: %q", i+1, line)
+			}
+			header := strings.TrimSpace(line[1:end])
+			name, sub, hasSub := strings.Cut(header, " ")
+			if hasSub {
+				sub = strings.TrimSpace(sub)
+				unquoted, err := strconv.Unquote(sub)
+				if err != nil {
+					return nil, fmt.Errorf("bad config section on line %d: %q", i+1, line)
+				}
+				section = strings.ToLower(name) + "." + unquoted
+			} else if dot := strings.Index(header, "."); dot >= 0 {
+				// deprecated [section.subsection] syntax
+				section = strings.ToLower(header[:dot]) + "." + strings.ToLower(header[dot+1:])
+			} else {
+				section = strings.ToLower(header)
+			}
+			continue
+		}
+
+		if section == "" {
+			return nil, fmt.Errorf("config line %d outside a section: %q", i+1, line)
+		}
+
+		key, value, hasValue := strings.Cut(line, "=")
+		key = strings.ToLower(strings.TrimSpace(key))
+		if !hasValue {
+			value = "true"
+		}
+		config[section+"."+key] = append(config[section+"."+key], parseConfigValue(value))
+	}
+
+	return config, nil
+}
+
+// parseConfigValue strips comments and surrounding quotes from a config
+// value and resolves its escape sequences
+func parseConfigValue(value string) string {
+	var b strings.Builder
+	quoted := false
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		switch {
+		case c == '"':
+			quoted = !quoted
+		case c == '\\' && i+1 < len(value):
+			i++
+			switch value[i] {
+			case 'n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			case 'b':
+				b.WriteByte('\b')
+			default:
+				b.WriteByte(value[i])
+			}
+		case (c == '#' || c == ';') && !quoted:
+			return strings.TrimSpace(b.String())
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return strings.TrimSpace(b.String())
+}
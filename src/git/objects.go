@@ -0,0 +1,593 @@
+package git
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// objectID is a SHA-1 object name
+type objectID [20]byte
+
+func (id objectID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+// parseObjectID parses a 40 character hex object name
+func parseObjectID(s string) (objectID, error) {
+	var id objectID
+	if len(s) != 2*len(id) {
+		return id, fmt.Errorf("invalid object name %q", s)
+	}
+	if _, err := hex.Decode(id[:], []byte(s)); err != nil {
+		return id, fmt.Errorf("invalid object name %q: %w", s, err)
+	}
+	return id, nil
+}
+
+// objectType is the type of a git object, numbered as in packfiles
+type objectType int
+
+const (
+	objectCommit   objectType = 1
+	objectTree     objectType = 2
+	objectBlob     objectType = 3
+	objectTag      objectType = 4
+	objectOfsDelta objectType = 6
+	objectRefDelta objectType = 7
+)
+
+var objectTypeNames = map[string]objectType{
+	"commit": objectCommit,
+	"tree":   objectTree,
+	"blob":   objectBlob,
+	"tag":    objectTag,
+}
+
+// errObjectNotFound is returned for objects in neither a pack nor the loose
+// object directories, e.g. in partial clones
+var errObjectNotFound = errors.New("object not found")
+
+// maxDeltaCache bounds the number of delta bases kept per packfile
+const maxDeltaCache = 256
+
+// objectStore reads objects from a repository's object database: loose
+// objects and packfiles with version 2 indexes, in the repository itself and
+// in its alternates.
+type objectStore struct {
+	dirs    []string
+	packs   []*packFile
+	commits map[objectID]*commitInfo
+}
+
+// commitInfo holds the parts of a commit needed to walk history
+type commitInfo struct {
+	parents    []objectID
+	time       int64 // committer timestamp
+	authorTime int64
+}
+
+// openObjectStore opens the object database at objectsDir
+func openObjectStore(objectsDir string) (*objectStore, error) {
+	store := &objectStore{commits: make(map[objectID]*commitInfo)}
+
+	dirs, err := readAlternates(objectsDir)
+	if err != nil {
+		return nil, err
+	}
+	store.dirs = dirs
+
+	for _, dir := range dirs {
+		indexes, err := filepath.Glob(filepath.Join(dir, "pack", "*.idx"))
+		if err != nil {
+			store.close()
+			return nil, err
+		}
+		for _, idx := range indexes {
+			pack, err := openPack(store, idx)
+			if err != nil {
+				store.close()
+				return nil, err
+			}
+			store.packs = append(store.packs, pack)
+		}
+	}
+
+	return store, nil
+}
+
+// readAlternates returns objectsDir followed by the object directories listed
+// in its info/alternates file, recursively
+func readAlternates(objectsDir string) ([]string, error) {
+	dirs := []string{objectsDir}
+	seen := map[string]bool{objectsDir: true}
+
+	for i := 0; i < len(dirs); i++ {
+		content, err := os.ReadFile(filepath.Join(dirs[i], "info", "alternates"))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, line := range strings.Split(string(content), "\n") {
+			line = strings.TrimSpace(line)
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			if !filepath.IsAbs(line) {
+				line = filepath.Join(dirs[i], line)
+			}
+			line = filepath.Clean(line)
+			if !seen[line] {
+				seen[line] = true
+				dirs = append(dirs, line)
+			}
+		}
+	}
+
+	return dirs, nil
+}
+
+func (s *objectStore) close() {
+	for _, pack := range s.packs {
+		pack.file.Close()
+	}
+	s.packs = nil
+}
+
+// read returns the type and content of the object id, with deltas resolved
+func (s *objectStore) read(id objectID) (objectType, []byte, error) {
+	for _, pack := range s.packs {
+		if offset, ok := pack.find(id); ok {
+			return pack.readAt(offset)
+		}
+	}
+
+	hexID := id.String()
+	for _, dir := range s.dirs {
+		f, err := os.Open(filepath.Join(dir, hexID[:2], hexID[2:]))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return 0, nil, err
+		}
+		defer f.Close()
+		return readLooseObject(f)
+	}
+
+	return 0, nil, fmt.Errorf("%w: %s", errObjectNotFound, hexID)
+}
+
+// readLooseObject decodes a zlib compressed "<type> <size>\0<content>" object
+func readLooseObject(r io.Reader) (objectType, []byte, error) {
+	zr, err := zlib.NewReader(r)
+	if err != nil {
+		return 0, nil, fmt.Errorf("corrupt loose object: %w", err)
+	}
+	defer zr.Close()
+
+	data, err := io.ReadAll(zr)
+	if err != nil {
+		return 0, nil, fmt.Errorf("corrupt loose object: %w", err)
+	}
+
+	nul := bytes.IndexByte(data, 0)
+	if nul < 0 {
+		return 0, nil, errors.New("corrupt loose object: missing header")
+	}
+	header := strings.SplitN(string(data[:nul]), " ", 2)
+	typ, ok := objectTypeNames[header[0]]
+	if !ok || len(header) != 2 {
+		return 0, nil, fmt.Errorf("corrupt loose object: bad header %q", data[:nul])
+	}
+	size, err := strconv.Atoi(header[1])
+	if err != nil || size != len(data)-nul-1 {
+		return 0, nil, fmt.Errorf("corrupt loose object: bad size in header %q", data[:nul])
+	}
+
+	return typ, data[nul+1:], nil
+}
+
+// commit returns the parents and committer time of the commit id. Results are
+// cached for the lifetime of the store.
+func (s *objectStore) commit(id objectID) (*commitInfo, error) {
+	if c, ok := s.commits[id]; ok {
+		return c, nil
+	}
+
+	typ, data, err := s.read(id)
+	if err != nil {
+		return nil, err
+	}
+	if typ != objectCommit {
+		return nil, fmt.Errorf("object %s is not a commit", id)
+	}
+
+	c, err := parseCommit(data)
+	if err != nil {
+		return nil, fmt.Errorf("commit %s: %w", id, err)
+	}
+	s.commits[id] = c
+	return c, nil
+}
+
+// peelToCommit follows annotated tags until it reaches a commit. ok is false
+// when id ends up at another kind of object.
+func (s *objectStore) peelToCommit(id objectID) (objectID, bool, error) {
+	for {
+		if _, cached := s.commits[id]; cached {
+			return id, true, nil
+		}
+
+		typ, data, err := s.read(id)
+		if err != nil {
+			return id, false, err
+		}
+		switch typ {
+		case objectCommit:
+			c, err := parseCommit(data)
+			if err != nil {
+				return id, false, fmt.Errorf("commit %s: %w", id, err)
+			}
+			s.commits[id] = c
+			return id, true, nil
+		case objectTag:
+			target, err := parseTagTarget(data)
+			if err != nil {
+				return id, false, fmt.Errorf("tag %s: %w", id, err)
+			}
+			id = target
+		default:
+			return id, false, nil
+		}
+	}
+}
+
+// parseCommit reads the parent, author and committer headers of a commit object
+func parseCommit(data []byte) (*commitInfo, error) {
+	c := &commitInfo{}
+	for len(data) > 0 {
+		line := data
+		if nl := bytes.IndexByte(data, '\n'); nl >= 0 {
+			line, data = data[:nl], data[nl+1:]
+		} else {
+			data = nil
+		}
+		if len(line) == 0 {
+			break
+		}
+
+		switch {
+		case bytes.HasPrefix(line, []byte("parent ")):
+			id, err := parseObjectID(string(line[len("parent "):]))
+			if err != nil {
+				return nil, err
+			}
+			c.parents = append(c.parents, id)
+		case bytes.HasPrefix(line, []byte("author ")):
+			t, err := parseSignatureTime(line)
+			if err != nil {
+				return nil, err
+			}
+			c.authorTime = t
+		case bytes.HasPrefix(line, []byte("committer ")):
+			t, err := parseSignatureTime(line)
+			if err != nil {
+				return nil, err
+			}
+			c.time = t
+		}
+	}
+	return c, nil
+}
+
+// parseSignatureTime returns the timestamp of an author or committer line,
+// e.g. "committer Name <email> 1700000000 +0100"
+func parseSignatureTime(line []byte) (int64, error) {
+	header, _, _ := bytes.Cut(line, []byte(" "))
+	fields := strings.Fields(string(line[bytes.LastIndexByte(line, '>')+1:]))
+	if len(fields) < 1 {
+		return 0, fmt.Errorf("bad %s line %q", header, line)
+	}
+	t, err := strconv.ParseInt(fields[0], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("bad %s time in %q", header, line)
+	}
+	return t, nil
+}
+
+// parseTagTarget returns the object an annotated tag points at
+func parseTagTarget(data []byte) (objectID, error) {
+	line := data
+	if nl := bytes.IndexByte(data, '\n'); nl >= 0 {
+		line = data[:nl]
+	}
+	if !bytes.HasPrefix(line, []byte("object ")) {
+		return objectID{}, errors.New("missing object header")
+	}
+	return parseObjectID(string(line[len("object "):]))
+}
+
+// packFile is a packfile together with its version 2 index
+type packFile struct {
+	store      *objectStore
+	file       *os.File
+	size       int64
+	fanout     [256]uint32
+	names      []byte // sorted object names, 20 bytes each
+	offsets    []byte // 4 byte offsets, MSB set for an index into large
+	large      []byte // 8 byte offsets of objects beyond 2 GiB
+	deltaBases map[int64]packedObject
+}
+
+// packedObject is a fully resolved object read from a packfile
+type packedObject struct {
+	typ  objectType
+	data []byte
+}
+
+var packIndexMagic = []byte{0xff, 't', 'O', 'c'}
+
+// openPack loads the index at idxPath and opens the matching .pack file
+func openPack(store *objectStore, idxPath string) (*packFile, error) {
+	idx, err := os.ReadFile(idxPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(idx) < 8+256*4 || !bytes.Equal(idx[:4], packIndexMagic) {
+		return nil, fmt.Errorf("%w: pack index %s is not version 2", errNativeUnsupported, idxPath)
+	}
+	if version := binary.BigEndian.Uint32(idx[4:8]); version != 2 {
+		return nil, fmt.Errorf("%w: pack index %s has version %d", errNativeUnsupported, idxPath, version)
+	}
+
+	pack := &packFile{store: store, deltaBases: make(map[int64]packedObject)}
+	for i := range pack.fanout {
+		pack.fanout[i] = binary.BigEndian.Uint32(idx[8+4*i:])
+	}
+
+	count := int(pack.fanout[255])
+	namesStart := 8 + 256*4
+	offsetsStart := namesStart + count*20 + count*4
+	largeStart := offsetsStart + count*4
+	if len(idx) < largeStart+40 {
+		return nil, fmt.Errorf("pack index %s is truncated", idxPath)
+	}
+	pack.names = idx[namesStart : namesStart+count*20]
+	pack.offsets = idx[offsetsStart:largeStart]
+	pack.large = idx[largeStart : len(idx)-40]
+
+	packPath := strings.TrimSuffix(idxPath, ".idx") + ".pack"
+	pack.file, err = os.Open(packPath)
+	if err != nil {
+		return nil, err
+	}
+	info, err := pack.file.Stat()
+	if err != nil {
+		pack.file.Close()
+		return nil, err
+	}
+	pack.size = info.Size()
+
+	return pack, nil
+}
+
+// find returns the offset of id in the packfile
+func (p *packFile) find(id objectID) (int64, bool) {
+	lo := 0
+	if id[0] > 0 {
+		lo = int(p.fanout[id[0]-1])
+	}
+	hi := int(p.fanout[id[0]])
+
+	i := lo + sort.Search(hi-lo, func(i int) bool {
+		return bytes.Compare(p.names[(lo+i)*20:(lo+i+1)*20], id[:]) >= 0
+	})
+	if i >= hi || !bytes.Equal(p.names[i*20:(i+1)*20], id[:]) {
+		return 0, false
+	}
+
+	offset := binary.BigEndian.Uint32(p.offsets[i*4:])
+	if offset&0x80000000 == 0 {
+		return int64(offset), true
+	}
+	large := int(offset&0x7fffffff) * 8
+	if large+8 > len(p.large) {
+		return 0, false
+	}
+	return int64(binary.BigEndian.Uint64(p.large[large:])), true
+}
+
+// readAt reads the object stored at offset, resolving delta chains
+func (p *packFile) readAt(offset int64) (objectType, []byte, error) {
+	if obj, ok := p.deltaBases[offset]; ok {
+		return obj.typ, obj.data, nil
+	}
+
+	if offset < 0 || offset >= p.size {
+		return 0, nil, fmt.Errorf("corrupt pack %s: offset %d out of range", p.file.Name(), offset)
+	}
+	r := bufio.NewReader(io.NewSectionReader(p.file, offset, p.size-offset))
+
+	c, err := r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	typ := objectType((c >> 4) & 7)
+	size := int64(c & 0x0f)
+	for shift := 4; c&0x80 != 0; shift += 7 {
+		if c, err = r.ReadByte(); err != nil {
+			return 0, nil, err
+		}
+		size |= int64(c&0x7f) << shift
+	}
+
+	switch typ {
+	case objectCommit, objectTree, objectBlob, objectTag:
+		data, err := inflate(r, size)
+		return typ, data, err
+
+	case objectOfsDelta:
+		c, err := r.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+		distance := int64(c & 0x7f)
+		for c&0x80 != 0 {
+			if c, err = r.ReadByte(); err != nil {
+				return 0, nil, err
+			}
+			distance = (distance+1)<<7 | int64(c&0x7f)
+		}
+		delta, err := inflate(r, size)
+		if err != nil {
+			return 0, nil, err
+		}
+		baseOffset := offset - distance
+		baseType, base, err := p.readAt(baseOffset)
+		if err != nil {
+			return 0, nil, err
+		}
+		p.cacheBase(baseOffset, baseType, base)
+		data, err := applyDelta(base, delta)
+		return baseType, data, err
+
+	case objectRefDelta:
+		var baseID objectID
+		if _, err := io.ReadFull(r, baseID[:]); err != nil {
+			return 0, nil, err
+		}
+		delta, err := inflate(r, size)
+		if err != nil {
+			return 0, nil, err
+		}
+		baseType, base, err := p.store.read(baseID)
+		if err != nil {
+			return 0, nil, err
+		}
+		data, err := applyDelta(base, delta)
+		return baseType, data, err
+
+	default:
+		return 0, nil, fmt.Errorf("corrupt pack %s: unknown object type %d at offset %d", p.file.Name(), typ, offset)
+	}
+}
+
+// cacheBase remembers a delta base so chains sharing it are not re-inflated
+func (p *packFile) cacheBase(offset int64, typ objectType, data []byte) {
+	if len(p.deltaBases) >= maxDeltaCache {
+		p.deltaBases = make(map[int64]packedObject)
+	}
+	p.deltaBases[offset] = packedObject{typ: typ, data: data}
+}
+
+// inflate decompresses a zlib stream expected to hold exactly size bytes
+func inflate(r io.Reader, size int64) ([]byte, error) {
+	zr, err := zlib.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("corrupt pack data: %w", err)
+	}
+	defer zr.Close()
+
+	data := make([]byte, size)
+	if _, err := io.ReadFull(zr, data); err != nil {
+		return nil, fmt.Errorf("corrupt pack data: %w", err)
+	}
+	return data, nil
+}
+
+var errCorruptDelta = errors.New("corrupt delta")
+
+// applyDelta reconstructs an object from its base and a git delta: two
+// varint sizes followed by copy-from-base and insert instructions.
+func applyDelta(base, delta []byte) ([]byte, error) {
+	srcSize, n := deltaVarint(delta)
+	if n == 0 || srcSize != uint64(len(base)) {
+		return nil, errCorruptDelta
+	}
+	delta = delta[n:]
+
+	dstSize, n := deltaVarint(delta)
+	if n == 0 {
+		return nil, errCorruptDelta
+	}
+	delta = delta[n:]
+
+	out := make([]byte, 0, dstSize)
+	for len(delta) > 0 {
+		op := delta[0]
+		delta = delta[1:]
+
+		switch {
+		case op&0x80 != 0:
+			var offset, size uint64
+			for i := uint(0); i < 4; i++ {
+				if op&(1<<i) != 0 {
+					if len(delta) == 0 {
+						return nil, errCorruptDelta
+					}
+					offset |= uint64(delta[0]) << (8 * i)
+					delta = delta[1:]
+				}
+			}
+			for i := uint(0); i < 3; i++ {
+				if op&(0x10<<i) != 0 {
+					if len(delta) == 0 {
+						return nil, errCorruptDelta
+					}
+					size |= uint64(delta[0]) << (8 * i)
+					delta = delta[1:]
+				}
+			}
+			if size == 0 {
+				size = 0x10000
+			}
+			if offset+size > uint64(len(base)) {
+				return nil, errCorruptDelta
+			}
+			out = append(out, base[offset:offset+size]...)
+		case op != 0:
+			if int(op) > len(delta) {
+				return nil, errCorruptDelta
+			}
+			out = append(out, delta[:op]...)
+			delta = delta[op:]
+		default:
+			return nil, errCorruptDelta
+		}
+	}
+
+	if uint64(len(out)) != dstSize {
+		return nil, errCorruptDelta
+	}
+	return out, nil
+}
+
+// deltaVarint decodes a little-endian base-128 size, returning 0 bytes read
+// on truncated input
+func deltaVarint(b []byte) (uint64, int) {
+	var v uint64
+	for i, c := range b {
+		if i >= 10 {
+			return 0, 0
+		}
+		v |= uint64(c&0x7f) << (7 * uint(i))
+		if c&0x80 == 0 {
+			return v, i + 1
+		}
+	}
+	return 0, 0
+}
@@ -0,0 +1,85 @@
+package git
+
+import (
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"gitstatus/src/types"
+)
+
+// GetOperationState inspects the git directory of the working tree at path
+// for an unfinished rebase, am, merge, cherry-pick, revert or bisect.
+func GetOperationState(path string) (types.OperationState, error) {
+	return getOperationState(osFileSystem{}, path)
+}
+
+// getOperationState is GetOperationState reading fsys
+func getOperationState(fsys FileSystem, path string) (types.OperationState, error) {
+	gitDir, err := resolveGitDir(fsys, path)
+	if err != nil {
+		return types.OperationState{}, err
+	}
+
+	// rebase -i and the default merge backend keep their state in rebase-merge
+	if dir := filepath.Join(gitDir, "rebase-merge"); isDir(fsys, dir) {
+		return types.OperationState{
+			Operation: types.OperationRebase,
+			Step:      readCounter(fsys, filepath.Join(dir, "msgnum")),
+			Total:     readCounter(fsys, filepath.Join(dir, "end")),
+		}, nil
+	}
+
+	// git am and the apply backend of rebase share rebase-apply
+	if dir := filepath.Join(gitDir, "rebase-apply"); isDir(fsys, dir) {
+		op := types.OperationRebase
+		if exists(fsys, filepath.Join(dir, "applying")) {
+			op = types.OperationAm
+		}
+		return types.OperationState{
+			Operation: op,
+			Step:      readCounter(fsys, filepath.Join(dir, "next")),
+			Total:     readCounter(fsys, filepath.Join(dir, "last")),
+		}, nil
+	}
+
+	markers := []struct {
+		file string
+		op   types.Operation
+	}{
+		{"MERGE_HEAD", types.OperationMerge},
+		{"CHERRY_PICK_HEAD", types.OperationCherryPick},
+		{"REVERT_HEAD", types.OperationRevert},
+		{"BISECT_LOG", types.OperationBisect},
+	}
+	for _, m := range markers {
+		if exists(fsys, filepath.Join(gitDir, m.file)) {
+			return types.OperationState{Operation: m.op}, nil
+		}
+	}
+
+	return types.OperationState{}, nil
+}
+
+// readCounter reads a step counter file, returning 0 if it is missing or malformed
+func readCounter(fsys FileSystem, path string) int {
+	content, err := fsys.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(string(content)))
+	if err != nil {
+		return 0
+	}
+	return n
+}
+
+func isDir(fsys FileSystem, path string) bool {
+	info, err := fsys.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+func exists(fsys FileSystem, path string) bool {
+	_, err := fsys.Stat(path)
+	return err == nil
+}
@@ -0,0 +1,245 @@
+package git
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"gitstatus/src/defaults"
+	"gitstatus/src/logger"
+	"gitstatus/src/types"
+)
+
+// localBranch is a local branch as listed by git for-each-ref
+type localBranch struct {
+	name         string
+	current      bool
+	upstream     string // e.g. "origin/main"
+	remote       string // remote of the upstream
+	merge        string // branch on the remote, e.g. "refs/heads/main"
+	push         string // push destination, e.g. "fork/main"
+	lastCommit   time.Time
+	lastAuthored time.Time
+}
+
+// CompareAllRemotes compares every local branch with the branch of the same
+// name on each remote other than the one it tracks or pushes to, e.g. main
+// with upstream/main in a fork whose main tracks origin/main. The comparisons are
+// stored in the Remotes of result.Branches; branches in sync with their
+// upstream are added when they differ from another remote.
+func CompareAllRemotes(ctx context.Context, path string, result *types.RepoResult, logger *logger.Logger) error {
+	remotes, err := getRemotes(ctx, path)
+	if err != nil || len(remotes) == 0 {
+		return err
+	}
+
+	locals, tracking, err := getBranchesAndTracking(ctx, path, logger)
+	if err != nil {
+		return err
+	}
+
+	listed := make(map[string]int)
+	for i, b := range result.Branches {
+		listed[b.Name] = i
+	}
+	seen := make(map[string]bool)
+
+	var branches []types.BranchSyncStatus
+	for _, lb := range locals {
+		seen[lb.name] = true
+
+		// The same branch on other remotes is the one tracked, not the local name
+		target := strings.TrimPrefix(lb.merge, "refs/heads/")
+		if target == "" {
+			target = lb.name
+		}
+
+		var comparisons []types.RemoteSyncStatus
+		for _, remote := range remotes {
+			ref := remote + "/" + target
+			if remote == lb.remote || ref == lb.push || !tracking[ref] {
+				continue
+			}
+			ahead, behind, err := countAheadBehind(ctx, path, "refs/heads/"+lb.name, "refs/remotes/"+ref)
+			if err != nil {
+				return err
+			}
+			logger.Debug("Branch %s in %s: ahead %d, behind %d of %s", lb.name, path, ahead, behind, ref)
+			comparisons = append(comparisons, types.RemoteSyncStatus{Ref: ref, Remote: remote, Ahead: ahead, Behind: behind})
+		}
+
+		if i, ok := listed[lb.name]; ok {
+			b := result.Branches[i]
+			b.Remotes = comparisons
+			branches = append(branches, b)
+			continue
+		}
+		if differs(comparisons) {
+			b := types.BranchSyncStatus{
+				Name:         lb.name,
+				Current:      lb.current,
+				Upstream:     lb.upstream,
+				Remote:       lb.remote,
+				LastCommit:   lb.lastCommit,
+				LastAuthored: lb.lastAuthored,
+				Remotes:      comparisons,
+			}
+			if lb.push != lb.upstream {
+				b.Push = lb.push
+				b.PushGone = lb.push != "" && !tracking[lb.push]
+			}
+			branches = append(branches, b)
+			result.HasUnsynced = true
+		}
+	}
+
+	for _, b := range result.Branches {
+		if !seen[b.Name] {
+			branches = append(branches, b)
+		}
+	}
+	result.Branches = branches
+	return nil
+}
+
+// comparePushRefs sets the push destination of the branches that push
+// somewhere other than their upstream, as in triangular workflows with
+// remote.pushDefault or branch.<name>.pushRemote, and counts how far each is
+// ahead of and behind it
+func comparePushRefs(ctx context.Context, path string, branches []types.BranchSyncStatus, refs map[string]branchRef, logger *logger.Logger) error {
+	for i := range branches {
+		b := &branches[i]
+		pushRef := refs[b.Name].push
+		push := strings.TrimPrefix(strings.TrimPrefix(pushRef, "refs/remotes/"), "refs/heads/")
+		if pushRef == "" || push == b.Upstream {
+			continue
+		}
+		b.Push = push
+
+		exists, err := refExists(ctx, path, pushRef)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			b.PushGone = true
+			logger.Debug("Branch %s in %s was never pushed to %s", b.Name, path, push)
+			continue
+		}
+		b.PushAhead, b.PushBehind, err = countAheadBehind(ctx, path, "refs/heads/"+b.Name, pushRef)
+		if err != nil {
+			return err
+		}
+		logger.Debug("Branch %s in %s: ahead %d, behind %d of push destination %s", b.Name, path, b.PushAhead, b.PushBehind, push)
+	}
+	return nil
+}
+
+// refExists reports whether the fully qualified ref exists
+func refExists(ctx context.Context, path string, ref string) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(defaults.DefaultGitCommandTimeoutSeconds)*time.Second)
+	defer cancel()
+
+	_, err := runGit(ctx, path, nil, "rev-parse", "--verify", "--quiet", ref)
+	if exitCode(err) == 1 {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("git rev-parse failed: %w", err)
+	}
+	return true, nil
+}
+
+// differs reports whether any comparison found commits on either side
+func differs(comparisons []types.RemoteSyncStatus) bool {
+	for _, c := range comparisons {
+		if c.Ahead > 0 || c.Behind > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// getBranchesAndTracking lists the local branches sorted by name, and the
+// remote-tracking branches as "<remote>/<branch>"
+func getBranchesAndTracking(ctx context.Context, path string, logger *logger.Logger) ([]localBranch, map[string]bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(defaults.DefaultGitCommandTimeoutSeconds)*time.Second)
+	defer cancel()
+
+	output, err := runGit(ctx, path, nil, "for-each-ref",
+		"--format=%(refname)%00%(HEAD)%00%(upstream:short)%00%(upstream:remotename)%00%(upstream:remoteref)%00%(committerdate:unix)%00%(authordate:unix)%00%(push:short)",
+		"refs/heads", "refs/remotes")
+	if err != nil {
+		return nil, nil, fmt.Errorf("git for-each-ref failed: %w", err)
+	}
+	return parseBranchesAndTracking(string(output), logger)
+}
+
+// parseBranchesAndTracking parses the NUL-separated fields of
+// getBranchesAndTracking
+func parseBranchesAndTracking(output string, logger *logger.Logger) ([]localBranch, map[string]bool, error) {
+	var locals []localBranch
+	tracking := make(map[string]bool)
+	scanner := newLineScanner(output)
+
+	for scanner.Scan() {
+		fields := strings.Split(scanner.Text(), "\x00")
+		if len(fields) != 8 {
+			logger.Debug("Skipping ref line (format mismatch): %q", scanner.Text())
+			continue
+		}
+
+		ref := fields[0]
+		if strings.HasPrefix(ref, "refs/remotes/") {
+			if !strings.HasSuffix(ref, "/HEAD") {
+				tracking[strings.TrimPrefix(ref, "refs/remotes/")] = true
+			}
+			continue
+		}
+
+		lb := localBranch{
+			name:     strings.TrimPrefix(ref, "refs/heads/"),
+			current:  fields[1] == "*",
+			upstream: fields[2],
+			remote:   fields[3],
+			merge:    fields[4],
+			push:     fields[7],
+		}
+		if seconds, err := strconv.ParseInt(fields[5], 10, 64); err == nil {
+			lb.lastCommit = time.Unix(seconds, 0)
+		}
+		if seconds, err := strconv.ParseInt(fields[6], 10, 64); err == nil {
+			lb.lastAuthored = time.Unix(seconds, 0)
+		}
+		locals = append(locals, lb)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, fmt.Errorf("failed to read ref list: %w", err)
+	}
+	return locals, tracking, nil
+}
+
+// countAheadBehind counts the commits only on local and only on other
+func countAheadBehind(ctx context.Context, path string, local string, other string) (int, int, error) {
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(defaults.DefaultGitCommandTimeoutSeconds)*time.Second)
+	defer cancel()
+
+	output, err := runGit(ctx, path, nil, "rev-list", "--left-right", "--count", local+"..."+other)
+	if err != nil {
+		return 0, 0, fmt.Errorf("git rev-list failed: %w", err)
+	}
+	counts := strings.Fields(string(output))
+	if len(counts) != 2 {
+		return 0, 0, fmt.Errorf("unexpected git rev-list output %q", strings.TrimSpace(string(output)))
+	}
+	ahead, err := strconv.Atoi(counts[0])
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to parse ahead count %q: %w", counts[0], err)
+	}
+	behind, err := strconv.Atoi(counts[1])
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to parse behind count %q: %w", counts[1], err)
+	}
+	return ahead, behind, nil
+}
@@ -0,0 +1,119 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gitstatus/src/types"
+)
+
+// ErrNotRepository is returned when a directory has no .git entry
+var ErrNotRepository = errors.New("not a git repository")
+
+// ResolveGitDir returns the git directory of the working tree at path.
+// A .git directory is returned as is, a .git file ("gitdir: <path>") is
+// followed to the directory it points at.
+func ResolveGitDir(path string) (string, error) {
+	return resolveGitDir(osFileSystem{}, path)
+}
+
+// resolveGitDir is ResolveGitDir reading fsys
+func resolveGitDir(fsys FileSystem, path string) (string, error) {
+	dotGit := filepath.Join(path, ".git")
+	info, err := fsys.Stat(dotGit)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", ErrNotRepository
+		}
+		return "", err
+	}
+
+	if info.IsDir() {
+		return dotGit, nil
+	}
+
+	content, err := fsys.ReadFile(dotGit)
+	if err != nil {
+		return "", err
+	}
+
+	line := strings.TrimSpace(string(content))
+	if !strings.HasPrefix(line, "gitdir:") {
+		return "", fmt.Errorf("invalid gitfile %s: missing gitdir", dotGit)
+	}
+
+	gitDir := strings.TrimSpace(strings.TrimPrefix(line, "gitdir:"))
+	if !filepath.IsAbs(gitDir) {
+		gitDir = filepath.Join(path, gitDir)
+	}
+
+	info, err = fsys.Stat(gitDir)
+	if err != nil {
+		return "", fmt.Errorf("gitfile %s points to missing git directory: %w", dotGit, err)
+	}
+	if !info.IsDir() {
+		return "", fmt.Errorf("gitfile %s points to %s which is not a directory", dotGit, gitDir)
+	}
+
+	return filepath.Clean(gitDir), nil
+}
+
+// DetectRepo reports what kind of repository lives at path and, for linked
+// worktrees and submodules, the working tree they belong to.
+func DetectRepo(path string) (types.RepoKind, string, error) {
+	return detectRepo(osFileSystem{}, path)
+}
+
+// detectRepo is DetectRepo reading fsys
+func detectRepo(fsys FileSystem, path string) (types.RepoKind, string, error) {
+	gitDir, err := resolveGitDir(fsys, path)
+	if err != nil {
+		return "", "", err
+	}
+
+	if gitDir == filepath.Join(path, ".git") {
+		return types.RepoKindRepository, "", nil
+	}
+
+	if commonDir, err := fsys.ReadFile(filepath.Join(gitDir, "commondir")); err == nil {
+		common := strings.TrimSpace(string(commonDir))
+		if !filepath.IsAbs(common) {
+			common = filepath.Join(gitDir, common)
+		}
+		common = filepath.Clean(common)
+
+		// The common dir of a non-bare repository is the main worktree's .git
+		parent := common
+		if filepath.Base(common) == ".git" {
+			parent = filepath.Dir(common)
+		}
+		return types.RepoKindWorktree, parent, nil
+	}
+
+	if strings.Contains(filepath.ToSlash(gitDir), "/.git/modules/") {
+		if parent := findSuperproject(fsys, path); parent != "" {
+			return types.RepoKindSubmodule, parent, nil
+		}
+	}
+
+	return types.RepoKindGitfile, "", nil
+}
+
+// findSuperproject returns the nearest ancestor of path that is a git
+// working tree, or "" if there is none.
+func findSuperproject(fsys FileSystem, path string) string {
+	dir := filepath.Dir(path)
+	for {
+		if _, err := fsys.Stat(filepath.Join(dir, ".git")); err == nil {
+			return dir
+		}
+		next := filepath.Dir(dir)
+		if next == dir {
+			return ""
+		}
+		dir = next
+	}
+}
@@ -0,0 +1,121 @@
+package git
+
+import "container/heap"
+
+// Flags painted on commits while comparing two sets of tips
+const (
+	reachLeft  uint8 = 1 << iota // reachable from a left tip
+	reachRight                   // reachable from a right tip
+	reachBoth  = reachLeft | reachRight
+)
+
+// countExclusive counts the commits reachable from left but not from right,
+// and, when countRight is set, those reachable from right but not from left,
+// like git rev-list --left-right --count. Commits are visited newest first by
+// committer time and the walk stops once nothing reachable from only one side
+// is left to visit, so like git the result relies on commit timestamps being
+// roughly monotonic. Commits listed in shallow are treated as having no
+// parents. Without countRight the right count is not meaningful.
+func countExclusive(store *objectStore, left, right []objectID, shallow map[objectID]bool, countRight bool) (int, int, error) {
+	flags := make(map[objectID]uint8)
+	processed := make(map[objectID]uint8)
+	queue := &commitQueue{}
+
+	// pending counts queued entries that can still change the result
+	pending := 0
+	matters := func(f uint8) bool {
+		if countRight {
+			return f != reachBoth
+		}
+		return f == reachLeft
+	}
+
+	push := func(id objectID, f uint8) error {
+		if flags[id]|f == flags[id] {
+			return nil
+		}
+		flags[id] |= f
+
+		c, err := store.commit(id)
+		if err != nil {
+			return err
+		}
+		if matters(flags[id]) {
+			pending++
+		}
+		heap.Push(queue, queuedCommit{id: id, time: c.time, flags: flags[id]})
+		return nil
+	}
+
+	for _, id := range left {
+		if err := push(id, reachLeft); err != nil {
+			return 0, 0, err
+		}
+	}
+	for _, id := range right {
+		if err := push(id, reachRight); err != nil {
+			return 0, 0, err
+		}
+	}
+
+	for pending > 0 {
+		entry := heap.Pop(queue).(queuedCommit)
+		if matters(entry.flags) {
+			pending--
+		}
+
+		f := flags[entry.id]
+		if processed[entry.id] == f {
+			continue
+		}
+		processed[entry.id] = f
+
+		if shallow[entry.id] {
+			continue
+		}
+		c, err := store.commit(entry.id)
+		if err != nil {
+			return 0, 0, err
+		}
+		for _, parent := range c.parents {
+			if err := push(parent, f); err != nil {
+				return 0, 0, err
+			}
+		}
+	}
+
+	onlyLeft, onlyRight := 0, 0
+	for _, f := range flags {
+		switch f {
+		case reachLeft:
+			onlyLeft++
+		case reachRight:
+			onlyRight++
+		}
+	}
+	return onlyLeft, onlyRight, nil
+}
+
+type queuedCommit struct {
+	id    objectID
+	time  int64
+	flags uint8 // flags of the commit when it was queued
+}
+
+// commitQueue is a max-heap of commits ordered by committer time
+type commitQueue []queuedCommit
+
+func (q commitQueue) Len() int           { return len(q) }
+func (q commitQueue) Less(i, j int) bool { return q[i].time > q[j].time }
+func (q commitQueue) Swap(i, j int)      { q[i], q[j] = q[j], q[i] }
+
+func (q *commitQueue) Push(x any) {
+	*q = append(*q, x.(queuedCommit))
+}
+
+func (q *commitQueue) Pop() any {
+	old := *q
+	item := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return item
+}
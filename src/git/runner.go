@@ -0,0 +1,190 @@
+package git
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Runner runs git commands for the git package. dir is the working directory
+// and env holds "KEY=value" entries added to the inherited environment. The
+// returned output combines stdout and stderr.
+type Runner interface {
+	Run(ctx context.Context, dir string, env []string, args ...string) ([]byte, error)
+}
+
+// runnerKey is the context key of the Runner set by WithRunner
+type runnerKey struct{}
+
+// WithRunner returns a copy of ctx whose git commands run through r instead
+// of ExecRunner, e.g. a ReplayRunner serving canned output. Since the runner
+// travels with the context, tests using different runners can run in
+// parallel.
+func WithRunner(ctx context.Context, r Runner) context.Context {
+	return context.WithValue(ctx, runnerKey{}, r)
+}
+
+// runGit runs git with args in dir through the Runner of ctx
+func runGit(ctx context.Context, dir string, env []string, args ...string) ([]byte, error) {
+	if r, ok := ctx.Value(runnerKey{}).(Runner); ok {
+		return r.Run(ctx, dir, env, args...)
+	}
+	return ExecRunner{}.Run(ctx, dir, env, args...)
+}
+
+// FileSystem reads the repository files the git package inspects without
+// running git: .git files, worktree links, the markers of an operation in
+// progress and the modification times of changed files. A Runner that also
+// implements FileSystem serves these reads; otherwise they go to the disk.
+// The native backend always reads the disk.
+type FileSystem interface {
+	Stat(name string) (fs.FileInfo, error)
+	Lstat(name string) (fs.FileInfo, error)
+	ReadFile(name string) ([]byte, error)
+}
+
+// fileSystem returns the FileSystem of the Runner of ctx, or the disk
+func fileSystem(ctx context.Context) FileSystem {
+	if fsys, ok := ctx.Value(runnerKey{}).(FileSystem); ok {
+		return fsys
+	}
+	return osFileSystem{}
+}
+
+// osFileSystem reads the disk
+type osFileSystem struct{}
+
+func (osFileSystem) Stat(name string) (fs.FileInfo, error)  { return os.Stat(name) }
+func (osFileSystem) Lstat(name string) (fs.FileInfo, error) { return os.Lstat(name) }
+func (osFileSystem) ReadFile(name string) ([]byte, error)   { return os.ReadFile(name) }
+
+// ExecRunner runs the git binary found in PATH. Messages are forced to
+// English with LC_ALL=C since the parsers match git's wording.
+type ExecRunner struct{}
+
+func (ExecRunner) Run(ctx context.Context, dir string, env []string, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "LC_ALL=C")
+	cmd.Env = append(cmd.Env, env...)
+	return cmd.CombinedOutput()
+}
+
+// Call is a git command and its result, as recorded by RecordingRunner and
+// served by ReplayRunner
+type Call struct {
+	Dir    string // working directory, "" in a ReplayRunner matches any
+	Args   []string
+	Output string
+	Err    error
+}
+
+// RecordingRunner passes commands to Runner and records every call
+type RecordingRunner struct {
+	Runner Runner
+
+	mu    sync.Mutex
+	calls []Call
+}
+
+func (r *RecordingRunner) Run(ctx context.Context, dir string, env []string, args ...string) ([]byte, error) {
+	output, err := r.Runner.Run(ctx, dir, env, args...)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls = append(r.calls, Call{Dir: dir, Args: args, Output: string(output), Err: err})
+
+	return output, err
+}
+
+// Calls returns the calls recorded so far, in the order they finished
+func (r *RecordingRunner) Calls() []Call {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Call(nil), r.calls...)
+}
+
+// ErrNoRecording is returned by ReplayRunner for a command it has no call for
+var ErrNoRecording = errors.New("no recorded call")
+
+// ReplayRunner serves recorded output without running git or reading the
+// disk. Each command is answered by the first call with the same arguments
+// and directory; calls can be answered any number of times, and a command
+// without one fails with ErrNoRecording and is listed by Unrecorded. As a
+// FileSystem it serves Files, such as an fstest.MapFS, where an absolute path
+// is found without its leading "/", e.g. /work/repo/.git as "work/repo/.git".
+// The native backend is not replayed.
+type ReplayRunner struct {
+	Calls []Call
+	Files fs.FS // nil holds no files
+
+	mu         sync.Mutex
+	unrecorded []string
+}
+
+func (r *ReplayRunner) Run(ctx context.Context, dir string, env []string, args ...string) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, call := range r.Calls {
+		if (call.Dir == "" || call.Dir == dir) && equalArgs(call.Args, args) {
+			return []byte(call.Output), call.Err
+		}
+	}
+
+	command := fmt.Sprintf("git %s in %s", strings.Join(args, " "), dir)
+	r.mu.Lock()
+	r.unrecorded = append(r.unrecorded, command)
+	r.mu.Unlock()
+	return nil, fmt.Errorf("%w for %s", ErrNoRecording, command)
+}
+
+// Unrecorded returns the commands Run had no call for, in the order they ran
+func (r *ReplayRunner) Unrecorded() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.unrecorded...)
+}
+
+func (r *ReplayRunner) Stat(name string) (fs.FileInfo, error) {
+	if r.Files == nil {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+	}
+	return fs.Stat(r.Files, mapPath(name))
+}
+
+// Lstat is Stat, since Files holds no symlinks
+func (r *ReplayRunner) Lstat(name string) (fs.FileInfo, error) {
+	return r.Stat(name)
+}
+
+func (r *ReplayRunner) ReadFile(name string) ([]byte, error) {
+	if r.Files == nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	return fs.ReadFile(r.Files, mapPath(name))
+}
+
+// mapPath returns the name of the absolute path name in a ReplayRunner's Files
+func mapPath(name string) string {
+	return strings.TrimPrefix(filepath.ToSlash(filepath.Clean(name)), "/")
+}
+
+func equalArgs(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,55 @@
+package git
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"gitstatus/src/defaults"
+	"gitstatus/src/logger"
+	"gitstatus/src/types"
+)
+
+// GetStashStatus counts the entries of refs/stash and finds the oldest one
+func GetStashStatus(ctx context.Context, path string, logger *logger.Logger) (types.StashStatus, error) {
+	logger.Debug("Checking stash for: %s", path)
+
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(defaults.DefaultGitCommandTimeoutSeconds)*time.Second)
+	defer cancel()
+
+	output, err := runGit(ctx, path, nil, "stash", "list", "--format=%ct")
+	if err != nil {
+		return types.StashStatus{}, fmt.Errorf("git stash list failed: %w", err)
+	}
+
+	return parseStashList(string(output), logger), nil
+}
+
+// parseStashList parses one commit timestamp (seconds since epoch) per stash entry
+func parseStashList(output string, logger *logger.Logger) types.StashStatus {
+	status := types.StashStatus{}
+	scanner := newLineScanner(output)
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		status.Count++
+
+		seconds, err := strconv.ParseInt(line, 10, 64)
+		if err != nil {
+			logger.Error("Failed to parse stash timestamp '%s': %v", line, err)
+			continue
+		}
+		created := time.Unix(seconds, 0)
+		if status.Oldest.IsZero() || created.Before(status.Oldest) {
+			status.Oldest = created
+		}
+	}
+
+	return status
+}
@@ -0,0 +1,186 @@
+package git
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	"gitstatus/src/defaults"
+	"gitstatus/src/logger"
+)
+
+// Pull fast-forwards the current branch of the repository at path to its
+// upstream with git pull --ff-only. It never merges or rebases, and like
+// FetchRepo never prompts for credentials.
+func Pull(ctx context.Context, path string, logger *logger.Logger) error {
+	return runRemoteCommand(ctx, path, logger, "pull", "--ff-only")
+}
+
+// Push pushes the current branch of the repository at path with git push,
+// never prompting for credentials
+func Push(ctx context.Context, path string, logger *logger.Logger) error {
+	return runRemoteCommand(ctx, path, logger, "push")
+}
+
+// PushBranch pushes branch to where git push would send it when checked
+// out, whether or not it is
+func PushBranch(ctx context.Context, path string, branch string, logger *logger.Logger) error {
+	remote, ref, err := GetPushDestination(ctx, path, branch)
+	if err != nil {
+		return err
+	}
+	return runRemoteCommand(ctx, path, logger, "push", remote, "refs/heads/"+branch+":"+ref)
+}
+
+// ErrNoPushDestination is wrapped by GetPushDestination when git push would
+// refuse to push a branch. The message says why, e.g. "upstream is a local
+// branch".
+var ErrNoPushDestination = errors.New("no push destination")
+
+// GetPushDestination returns the remote and the remote ref (e.g. "fork" and
+// "refs/heads/main") git push sends branch to. The remote follows
+// branch.<name>.pushRemote and remote.pushDefault before the upstream
+// remote, and the ref push.default. Branches whose upstream is a local
+// branch have no push destination.
+func GetPushDestination(ctx context.Context, path string, branch string) (string, string, error) {
+	remote, err := getPushRemote(ctx, path, branch)
+	if err != nil {
+		return "", "", err
+	}
+	switch remote {
+	case "":
+		return "", "", fmt.Errorf("%w: no remote configured", ErrNoPushDestination)
+	case ".":
+		return "", "", fmt.Errorf("%w: upstream is a local branch", ErrNoPushDestination)
+	}
+
+	upstreamRemote, err := getConfig(ctx, path, "branch."+branch+".remote")
+	if err != nil {
+		return "", "", err
+	}
+	merge, err := getConfig(ctx, path, "branch."+branch+".merge")
+	if err != nil {
+		return "", "", err
+	}
+	mode, err := getConfig(ctx, path, "push.default")
+	if err != nil {
+		return "", "", err
+	}
+
+	// The same name on the push remote, unless the branch pushes back to the
+	// branch it tracks, as with push.default upstream and simple
+	same := "refs/heads/" + branch
+	triangular := remote != upstreamRemote || merge == ""
+	switch mode {
+	case "nothing":
+		return "", "", fmt.Errorf("%w: push.default is nothing", ErrNoPushDestination)
+	case "current", "matching":
+		return remote, same, nil
+	case "upstream":
+		if triangular {
+			return "", "", fmt.Errorf("%w: push remote %s is not the upstream remote and push.default is upstream", ErrNoPushDestination, remote)
+		}
+		return remote, merge, nil
+	default: // simple
+		if !triangular && merge != same {
+			return "", "", fmt.Errorf("%w: upstream branch %s has a different name and push.default is simple", ErrNoPushDestination, strings.TrimPrefix(merge, "refs/heads/"))
+		}
+		return remote, same, nil
+	}
+}
+
+// getPushRemote returns the remote branch is pushed to as git resolves it
+func getPushRemote(ctx context.Context, path string, branch string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(defaults.DefaultGitCommandTimeoutSeconds)*time.Second)
+	defer cancel()
+
+	output, err := runGit(ctx, path, nil, "for-each-ref", "--format=%(push:remotename)", "refs/heads/"+branch)
+	if err != nil {
+		return "", fmt.Errorf("git for-each-ref failed: %w", err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// getConfig returns the value of a git config key, "" when it is not set
+func getConfig(ctx context.Context, path string, key string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(defaults.DefaultGitCommandTimeoutSeconds)*time.Second)
+	defer cancel()
+
+	output, err := runGit(ctx, path, nil, "config", "--get", key)
+	if exitCode(err) == 1 {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("git config failed: %w", err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// IsMerged reports whether branch is fully merged into HEAD, which is what
+// git branch -d requires of a branch whose upstream is gone
+func IsMerged(ctx context.Context, path string, branch string) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(defaults.DefaultGitCommandTimeoutSeconds)*time.Second)
+	defer cancel()
+
+	_, err := runGit(ctx, path, nil, "merge-base", "--is-ancestor", "refs/heads/"+branch, "HEAD")
+	if exitCode(err) == 1 {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("git merge-base failed: %w", err)
+	}
+	return true, nil
+}
+
+// DeleteBranch deletes branch with git branch -d, which refuses to delete
+// a branch that is not fully merged
+func DeleteBranch(ctx context.Context, path string, branch string, logger *logger.Logger) error {
+	logger.Debug("Deleting branch %s in: %s", branch, path)
+
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(defaults.DefaultGitCommandTimeoutSeconds)*time.Second)
+	defer cancel()
+
+	output, err := runGit(ctx, path, nil, "branch", "-d", branch)
+	if err != nil {
+		logger.Debug("git branch -d failed in %s. Error: %v. Output: %s", path, err, string(output))
+		return fmt.Errorf("git branch -d failed: %s", firstErrorLine(string(output)))
+	}
+	return nil
+}
+
+// exitCode returns the exit status of a git command that ran and failed, or
+// -1 for any other error
+func exitCode(err error) int {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+	return -1
+}
+
+// runRemoteCommand runs a git command that talks to a remote, bounded by the
+// fetch timeout. Failures are reported with git's first error line.
+func runRemoteCommand(ctx context.Context, path string, logger *logger.Logger, args ...string) error {
+	logger.Debug("Running git %v in: %s", args, path)
+
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(defaults.DefaultGitFetchTimeoutSeconds)*time.Second)
+	defer cancel()
+
+	output, err := runGit(ctx, path, nonInteractiveEnv(), args...)
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return fmt.Errorf("git %s timed out after %ds", args[0], defaults.DefaultGitFetchTimeoutSeconds)
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		logger.Debug("git %v failed in %s. Error: %v. Output: %s", args, path, err, string(output))
+		return fmt.Errorf("git %s failed: %s", args[0], firstErrorLine(string(output)))
+	}
+
+	logger.Debug("git %s output for %s:\n%s", args[0], path, string(output))
+	return nil
+}
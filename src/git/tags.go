@@ -0,0 +1,178 @@
+package git
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"gitstatus/src/defaults"
+	"gitstatus/src/logger"
+)
+
+// localTag is a tag of the repository and the commit it points at
+type localTag struct {
+	name   string
+	commit string // peeled object of an annotated tag, the object itself otherwise
+}
+
+// GetUnpushedTags returns the local tags that no remote has, sorted by name.
+// With advertised set the tags each remote advertises are listed with git
+// ls-remote, which talks to the remotes. Otherwise, or when a remote cannot
+// be reached, a tag counts as unpushed when its commit is not reachable from
+// any remote-tracking branch, which misses tags on pushed commits; the
+// returned bool reports such a partial result. Repositories without remotes
+// have nothing to push to and report no tags.
+func GetUnpushedTags(ctx context.Context, path string, advertised bool, logger *logger.Logger) ([]string, bool, error) {
+	remotes, err := getRemotes(ctx, path)
+	if err != nil || len(remotes) == 0 {
+		return nil, false, err
+	}
+
+	tags, err := getLocalTags(ctx, path, logger)
+	if err != nil || len(tags) == 0 {
+		return nil, false, err
+	}
+
+	if advertised {
+		remoteTags, err := getAdvertisedTags(ctx, path, remotes, logger)
+		if err == nil {
+			var unpushed []string
+			for _, tag := range tags {
+				if !remoteTags[tag.name] {
+					unpushed = append(unpushed, tag.name)
+				}
+			}
+			return unpushed, false, nil
+		}
+		logger.Info("Cannot list remote tags of %s, using remote-tracking branches: %v", path, err)
+	}
+
+	local, err := getCommitsNotOnRemotes(ctx, path, logger)
+	if err != nil {
+		return nil, false, err
+	}
+	var unpushed []string
+	for _, tag := range tags {
+		if local[tag.commit] {
+			unpushed = append(unpushed, tag.name)
+		}
+	}
+	return unpushed, true, nil
+}
+
+// getRemotes returns the names of the configured remotes
+func getRemotes(ctx context.Context, path string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(defaults.DefaultGitCommandTimeoutSeconds)*time.Second)
+	defer cancel()
+
+	output, err := runGit(ctx, path, nil, "remote")
+	if err != nil {
+		return nil, fmt.Errorf("git remote failed: %w", err)
+	}
+	return strings.Fields(string(output)), nil
+}
+
+// getLocalTags lists the tags of the repository sorted by name
+func getLocalTags(ctx context.Context, path string, logger *logger.Logger) ([]localTag, error) {
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(defaults.DefaultGitCommandTimeoutSeconds)*time.Second)
+	defer cancel()
+
+	output, err := runGit(ctx, path, nil, "for-each-ref", "--format=%(refname:strip=2)%00%(objectname)%00%(*objectname)", "refs/tags")
+	if err != nil {
+		return nil, fmt.Errorf("git for-each-ref failed: %w", err)
+	}
+	return parseLocalTags(string(output), logger)
+}
+
+// parseLocalTags parses "<name>\0<object>\0<peeled object>" lines
+func parseLocalTags(output string, logger *logger.Logger) ([]localTag, error) {
+	var tags []localTag
+	scanner := newLineScanner(output)
+
+	for scanner.Scan() {
+		fields := strings.Split(scanner.Text(), "\x00")
+		if len(fields) != 3 {
+			logger.Debug("Skipping tag line (format mismatch): %q", scanner.Text())
+			continue
+		}
+		tag := localTag{name: fields[0], commit: fields[1]}
+		if fields[2] != "" {
+			tag.commit = fields[2]
+		}
+		tags = append(tags, tag)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read tag list: %w", err)
+	}
+	return tags, nil
+}
+
+// getAdvertisedTags returns the names of the tags any of remotes advertises
+func getAdvertisedTags(ctx context.Context, path string, remotes []string, logger *logger.Logger) (map[string]bool, error) {
+	tags := make(map[string]bool)
+	for _, remote := range remotes {
+		output, err := runRemoteLsTags(ctx, path, remote, logger)
+		if err != nil {
+			return nil, err
+		}
+		for name := range parseLsRemoteTags(output) {
+			tags[name] = true
+		}
+	}
+	return tags, nil
+}
+
+// runRemoteLsTags runs git ls-remote --tags for remote, bounded by the fetch
+// timeout and without prompting for credentials
+func runRemoteLsTags(ctx context.Context, path string, remote string, logger *logger.Logger) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(defaults.DefaultGitFetchTimeoutSeconds)*time.Second)
+	defer cancel()
+
+	output, err := runGit(ctx, path, nonInteractiveEnv(), "ls-remote", "--tags", "--refs", remote)
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return "", fmt.Errorf("git ls-remote %s timed out after %ds", remote, defaults.DefaultGitFetchTimeoutSeconds)
+		}
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		logger.Debug("git ls-remote failed in %s. Error: %v. Output: %s", path, err, string(output))
+		return "", fmt.Errorf("git ls-remote %s failed: %s", remote, firstErrorLine(string(output)))
+	}
+	return string(output), nil
+}
+
+// parseLsRemoteTags parses "<object>\trefs/tags/<name>" lines
+func parseLsRemoteTags(output string) map[string]bool {
+	tags := make(map[string]bool)
+	scanner := newLineScanner(output)
+
+	for scanner.Scan() {
+		_, ref, ok := strings.Cut(scanner.Text(), "\t")
+		if !ok || !strings.HasPrefix(ref, "refs/tags/") {
+			continue
+		}
+		tags[strings.TrimSuffix(strings.TrimPrefix(ref, "refs/tags/"), "^{}")] = true
+	}
+	return tags
+}
+
+// getCommitsNotOnRemotes returns the commits reachable from a tag but from
+// no remote-tracking branch
+func getCommitsNotOnRemotes(ctx context.Context, path string, logger *logger.Logger) (map[string]bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(defaults.DefaultGitCommandTimeoutSeconds)*time.Second)
+	defer cancel()
+
+	output, err := runGit(ctx, path, nil, "rev-list", "--tags", "--not", "--remotes")
+	if err != nil {
+		logger.Debug("git rev-list failed in %s. Error: %v. Output: %s", path, err, string(output))
+		return nil, fmt.Errorf("git rev-list failed: %w", err)
+	}
+
+	commits := make(map[string]bool)
+	for _, hash := range strings.Fields(string(output)) {
+		commits[hash] = true
+	}
+	return commits, nil
+}
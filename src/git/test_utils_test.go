@@ -36,6 +36,21 @@ func setupTestRepos(t *testing.T) string {
 		runCmd(dir, "git", args...)
 	}
 
+	// gitConflict runs a git command that is expected to stop on a conflict
+	gitConflict := func(dir string, args ...string) {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		if output, err := cmd.CombinedOutput(); err == nil {
+			t.Fatalf("Expected 'git %v' to stop on a conflict in %s\nOutput: %s", args, dir, output)
+		}
+	}
+
+	writeFile := func(dir string, name string, content string) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
 	remoteRepoPath := filepath.Join(testEnvPath, "remote_repo.git")
 	if err := os.MkdirAll(remoteRepoPath, 0755); err != nil {
 		t.Fatalf("Failed to create remote_repo.git: %v", err)
@@ -114,6 +129,88 @@ func setupTestRepos(t *testing.T) string {
 
 	git(pathGone, "fetch", "-p")
 
+	pathWithWorktree := cloneRepo("repo_with_worktree")
+	git(pathWithWorktree, "worktree", "add", "-b", "worktree-branch", filepath.Join(testEnvPath, "repo_worktree"))
+
+	pathWithSubmodule := cloneRepo("repo_with_submodule")
+	git(pathWithSubmodule, "-c", "protocol.file.allow=always", "submodule", "add", remoteRepoPath, "sub")
+
+	if err := os.MkdirAll(filepath.Join(testEnvPath, "gitdirs"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	git(testEnvPath, "clone", "--separate-git-dir", filepath.Join(testEnvPath, "gitdirs", "repo_gitfile.git"),
+		remoteRepoPath, "repo_gitfile")
+
+	pathStale := cloneRepo("repo_stale")
+	git(pathStale, "checkout", "-b", "stale-branch")
+	git(pathStale, "push", "-u", "origin", "stale-branch")
+
+	pathStaleSetup := cloneRepo("temp_stale_setup")
+	git(pathStaleSetup, "checkout", "stale-branch")
+	runCmd(pathStaleSetup, "touch", "stale_file")
+	git(pathStaleSetup, "add", "stale_file")
+	git(pathStaleSetup, "commit", "-m", "Commit not yet fetched")
+	git(pathStaleSetup, "push")
+	os.RemoveAll(pathStaleSetup)
+
+	pathBadRemote := cloneRepo("repo_bad_remote")
+	git(pathBadRemote, "remote", "set-url", "origin", filepath.Join(testEnvPath, "missing_remote.git"))
+
+	pathStashed := cloneRepo("repo_stashed")
+	for _, name := range []string{"first_stash", "second_stash"} {
+		runCmd(pathStashed, "touch", name)
+		git(pathStashed, "add", name)
+		git(pathStashed, "stash")
+	}
+
+	pathMerging := cloneRepo("repo_merging")
+	git(pathMerging, "checkout", "-b", "conflicting")
+	writeFile(pathMerging, "initial_file", "theirs\n")
+	git(pathMerging, "commit", "-am", "Their change")
+	git(pathMerging, "checkout", "master")
+	writeFile(pathMerging, "initial_file", "ours\n")
+	git(pathMerging, "commit", "-am", "Our change")
+	gitConflict(pathMerging, "merge", "conflicting")
+
+	pathRebasing := cloneRepo("repo_rebasing")
+	git(pathRebasing, "checkout", "-b", "topic")
+	writeFile(pathRebasing, "initial_file", "topic 1\n")
+	git(pathRebasing, "commit", "-am", "Topic 1")
+	writeFile(pathRebasing, "initial_file", "topic 2\n")
+	git(pathRebasing, "commit", "-am", "Topic 2")
+	git(pathRebasing, "checkout", "master")
+	writeFile(pathRebasing, "initial_file", "master\n")
+	git(pathRebasing, "commit", "-am", "Master change")
+	git(pathRebasing, "checkout", "topic")
+	gitConflict(pathRebasing, "rebase", "--merge", "master")
+
+	pathDetached := cloneRepo("repo_detached")
+	git(pathDetached, "checkout", "--detach")
+	writeFile(pathDetached, "initial_file", "detached\n")
+	git(pathDetached, "commit", "-am", "Detached commit")
+
+	pathDetachedClean := cloneRepo("repo_detached_clean")
+	git(pathDetachedClean, "checkout", "--detach", "origin/master")
+
+	// repo_packed keeps everything in a packfile with deltas and packed-refs
+	pathPacked := cloneRepo("repo_packed")
+	lines := ""
+	for i := 0; i < 200; i++ {
+		lines += fmt.Sprintf("line %d of a file long enough to be stored as a delta\n", i)
+	}
+	for i := 0; i < 3; i++ {
+		lines += fmt.Sprintf("change %d\n", i)
+		writeFile(pathPacked, "packed_file", lines)
+		git(pathPacked, "add", "packed_file")
+		git(pathPacked, "commit", "-m", fmt.Sprintf("Packed change %d", i))
+	}
+	git(pathPacked, "tag", "-a", "v1.0", "-m", "Release 1.0")
+	git(pathPacked, "checkout", "-b", "feature", "HEAD~1")
+	git(pathPacked, "checkout", "--detach", "v1.0")
+	writeFile(pathPacked, "packed_file", lines+"detached\n")
+	git(pathPacked, "commit", "-am", "Detached change")
+	git(pathPacked, "gc", "--aggressive", "--quiet")
+
 	if err := os.MkdirAll(filepath.Join(testEnvPath, "not_a_repo"), 0755); err != nil {
 		t.Fatal(err)
 	}
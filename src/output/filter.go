@@ -0,0 +1,85 @@
+package output
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"gitstatus/src/types"
+)
+
+// ageUnits are the units accepted by ParseAge besides Go duration units
+var ageUnits = map[string]time.Duration{
+	"d": 24 * time.Hour,
+	"w": 7 * 24 * time.Hour,
+}
+
+// ParseAge parses the argument of -stale and -since: a number of days or
+// weeks such as "30d" or "2w", or a Go duration such as "12h" or "90m"
+func ParseAge(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	for suffix, unit := range ageUnits {
+		if n, ok := strings.CutSuffix(s, suffix); ok {
+			count, err := strconv.Atoi(n)
+			if err != nil || count < 0 {
+				return 0, fmt.Errorf("invalid age %q (expected e.g. 30d, 2w or 12h)", s)
+			}
+			return time.Duration(count) * unit, nil
+		}
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid age %q (expected e.g. 30d, 2w or 12h)", s)
+	}
+	return d, nil
+}
+
+// LastActivity returns when res was last worked on: the later of its HEAD
+// commit and the most recent modification of an uncommitted file
+func LastActivity(res types.RepoResult) time.Time {
+	if res.LastModified.After(res.LastCommit) {
+		return res.LastModified
+	}
+	return res.LastCommit
+}
+
+// filterByAge applies cfg.Stale and cfg.Since. Branches are kept when their
+// tip was committed in the selected period, and a repository when its last
+// activity or one of its remaining branches is. Repositories that failed to
+// be analyzed are always kept.
+func filterByAge(results []types.RepoResult, cfg types.Config, now time.Time) []types.RepoResult {
+	if cfg.Stale == 0 && cfg.Since == 0 {
+		return results
+	}
+
+	inPeriod := func(t time.Time) bool {
+		if t.IsZero() {
+			return false
+		}
+		age := now.Sub(t)
+		return (cfg.Stale == 0 || age >= cfg.Stale) && (cfg.Since == 0 || age <= cfg.Since)
+	}
+
+	var filtered []types.RepoResult
+	for _, res := range results {
+		if res.Error != nil {
+			filtered = append(filtered, res)
+			continue
+		}
+
+		var branches []types.BranchSyncStatus
+		for _, b := range res.Branches {
+			if inPeriod(b.LastCommit) {
+				branches = append(branches, b)
+			}
+		}
+		if len(branches) == 0 && !inPeriod(LastActivity(res)) {
+			continue
+		}
+		res.Branches = branches
+		res.HasUnsynced = len(branches) > 0
+		filtered = append(filtered, res)
+	}
+	return filtered
+}
@@ -0,0 +1,263 @@
+package output
+
+import (
+	"encoding/json"
+	"os"
+	"time"
+
+	"gitstatus/src/types"
+)
+
+// JSONSchemaVersion is bumped whenever a field of the JSON report is renamed,
+// removed or changes meaning. Adding fields does not change the version.
+const JSONSchemaVersion = 1
+
+type jsonReport struct {
+	SchemaVersion int         `json:"schema_version"`
+	Repositories  []jsonRepo  `json:"repositories"`
+	Summary       jsonSummary `json:"summary"`
+}
+
+type jsonSummary struct {
+	Scanned    int   `json:"scanned"`
+	Matched    int   `json:"matched"`
+	Clean      int   `json:"clean"`
+	Unpushed   int   `json:"unpushed"`
+	Behind     int   `json:"behind"`
+	Gone       int   `json:"gone"`
+	NoUpstream int   `json:"no_upstream"`
+	Dirty      int   `json:"dirty"`
+	Tags       int   `json:"unpushed_tags"`
+	Errors     int   `json:"errors"`
+	ElapsedMS  int64 `json:"elapsed_ms"`
+}
+
+type jsonRepo struct {
+	Path           string        `json:"path"`
+	Root           string        `json:"root"`
+	Kind           string        `json:"kind"`
+	Parent         string        `json:"parent"`
+	Branch         string        `json:"branch"`
+	LastCommit     *time.Time    `json:"last_commit"`
+	LastAuthored   *time.Time    `json:"last_authored"`
+	LastModified   *time.Time    `json:"last_modified"`
+	HasUnsynced    bool          `json:"has_unsynced"`
+	HasUncommitted bool          `json:"has_uncommitted"`
+	Branches       []jsonBranch  `json:"branches"`
+	DetachedHead   *jsonDetached `json:"detached_head"`
+	Workdir        jsonWorkdir   `json:"workdir"`
+	Operation      *jsonOp       `json:"operation"`
+	HasStash       bool          `json:"has_stash"`
+	Stash          jsonStash     `json:"stash"`
+	UnpushedTags   []string      `json:"unpushed_tags"`
+	TagsPartial    bool          `json:"unpushed_tags_partial"`
+	FetchError     *string       `json:"fetch_error"`
+	Error          *string       `json:"error"`
+}
+
+type jsonBranch struct {
+	Name          string       `json:"name"`
+	Current       bool         `json:"current"`
+	Upstream      string       `json:"upstream"`
+	Remote        string       `json:"remote"`
+	Ahead         int          `json:"ahead"`
+	Behind        int          `json:"behind"`
+	Gone          bool         `json:"gone"`
+	NoUpstream    bool         `json:"no_upstream"`
+	Push          *jsonPush    `json:"push"`
+	LastCommit    *time.Time   `json:"last_commit"`
+	LastAuthored  *time.Time   `json:"last_authored"`
+	Remotes       []jsonRemote `json:"remotes"`
+	AheadCommits  []jsonCommit `json:"ahead_commits"`
+	BehindCommits []jsonCommit `json:"behind_commits"`
+}
+
+type jsonCommit struct {
+	Hash    string     `json:"hash"`
+	Subject string     `json:"subject"`
+	Author  string     `json:"author"`
+	Time    *time.Time `json:"time"`
+}
+
+type jsonPush struct {
+	Ref    string `json:"ref"`
+	Ahead  int    `json:"ahead"`
+	Behind int    `json:"behind"`
+	Gone   bool   `json:"gone"`
+}
+
+type jsonRemote struct {
+	Ref    string `json:"ref"`
+	Remote string `json:"remote"`
+	Ahead  int    `json:"ahead"`
+	Behind int    `json:"behind"`
+}
+
+type jsonDetached struct {
+	Commit   string `json:"commit"`
+	From     string `json:"from"`
+	Moved    bool   `json:"moved"`
+	Orphaned int    `json:"orphaned"`
+}
+
+type jsonWorkdir struct {
+	Modified    int `json:"modified"`
+	Staged      int `json:"staged"`
+	Untracked   int `json:"untracked"`
+	Deleted     int `json:"deleted"`
+	Renamed     int `json:"renamed"`
+	Conflicted  int `json:"conflicted"`
+	TypeChanged int `json:"type_changed"`
+}
+
+type jsonOp struct {
+	Name  string `json:"name"`
+	Step  int    `json:"step"`
+	Total int    `json:"total"`
+}
+
+type jsonStash struct {
+	Count  int        `json:"count"`
+	Oldest *time.Time `json:"oldest"`
+}
+
+func printJSON(results []types.RepoResult, summary Summary, cfg types.Config) error {
+	report := jsonReport{
+		SchemaVersion: JSONSchemaVersion,
+		Repositories:  []jsonRepo{},
+		Summary: jsonSummary{
+			Scanned:    summary.Scanned,
+			Matched:    summary.Matched,
+			Clean:      summary.Clean,
+			Unpushed:   summary.Unpushed,
+			Behind:     summary.Behind,
+			Gone:       summary.Gone,
+			NoUpstream: summary.NoUpstream,
+			Dirty:      summary.Dirty,
+			Tags:       summary.Tags,
+			Errors:     summary.Errors,
+			ElapsedMS:  summary.Elapsed.Milliseconds(),
+		},
+	}
+
+	for _, res := range results {
+		if cfg.SummaryOnly {
+			break
+		}
+		if res.Error == nil && !NeedsAttention(res) && !cfg.ShowAll {
+			continue
+		}
+		report.Repositories = append(report.Repositories, newJSONRepo(res))
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(report)
+}
+
+func newJSONRepo(res types.RepoResult) jsonRepo {
+	repo := jsonRepo{
+		Path:           res.Path,
+		Root:           res.Root,
+		Kind:           string(res.Kind),
+		Parent:         res.Parent,
+		Branch:         res.Branch,
+		LastCommit:     utcTime(res.LastCommit),
+		LastAuthored:   utcTime(res.LastAuthored),
+		LastModified:   utcTime(res.LastModified),
+		HasUnsynced:    res.HasUnsynced,
+		HasUncommitted: res.HasUncommitted,
+		Branches:       []jsonBranch{},
+		UnpushedTags:   []string{},
+		Workdir: jsonWorkdir{
+			Modified:    res.Uncommitted.Modified,
+			Staged:      res.Uncommitted.Staged,
+			Untracked:   res.Uncommitted.Untracked,
+			Deleted:     res.Uncommitted.Deleted,
+			Renamed:     res.Uncommitted.Renamed,
+			Conflicted:  res.Uncommitted.Conflicted,
+			TypeChanged: res.Uncommitted.TypeChanged,
+		},
+	}
+
+	if res.HasDetachedHead {
+		repo.DetachedHead = &jsonDetached{
+			Commit:   res.DetachedHead.Commit,
+			From:     res.DetachedHead.From,
+			Moved:    res.DetachedHead.Moved,
+			Orphaned: res.DetachedHead.Orphaned,
+		}
+	}
+
+	if res.HasOperation {
+		repo.Operation = &jsonOp{
+			Name:  string(res.Operation.Operation),
+			Step:  res.Operation.Step,
+			Total: res.Operation.Total,
+		}
+	}
+
+	repo.HasStash = res.HasStash
+	repo.Stash.Count = res.Stash.Count
+	repo.Stash.Oldest = utcTime(res.Stash.Oldest)
+
+	repo.UnpushedTags = append(repo.UnpushedTags, res.UnpushedTags...)
+	repo.TagsPartial = res.TagsPartial
+
+	repo.FetchError = errorString(res.FetchError)
+	repo.Error = errorString(res.Error)
+
+	for _, b := range res.Branches {
+		branch := jsonBranch{
+			Name:          b.Name,
+			Current:       b.Current,
+			Upstream:      b.Upstream,
+			Remote:        b.Remote,
+			Ahead:         b.Ahead,
+			Behind:        b.Behind,
+			Gone:          b.Gone,
+			NoUpstream:    b.NoUpstream,
+			LastCommit:    utcTime(b.LastCommit),
+			LastAuthored:  utcTime(b.LastAuthored),
+			Remotes:       []jsonRemote{},
+			AheadCommits:  newJSONCommits(b.AheadCommits),
+			BehindCommits: newJSONCommits(b.BehindCommits),
+		}
+		if b.Push != "" {
+			branch.Push = &jsonPush{Ref: b.Push, Ahead: b.PushAhead, Behind: b.PushBehind, Gone: b.PushGone}
+		}
+		for _, r := range b.Remotes {
+			branch.Remotes = append(branch.Remotes, jsonRemote{Ref: r.Ref, Remote: r.Remote, Ahead: r.Ahead, Behind: r.Behind})
+		}
+		repo.Branches = append(repo.Branches, branch)
+	}
+
+	return repo
+}
+
+// newJSONCommits converts commits for JSON, an empty array when there are none
+func newJSONCommits(commits []types.Commit) []jsonCommit {
+	converted := []jsonCommit{}
+	for _, c := range commits {
+		converted = append(converted, jsonCommit{Hash: c.Hash, Subject: c.Subject, Author: c.Author, Time: utcTime(c.Time)})
+	}
+	return converted
+}
+
+// utcTime converts t to UTC for JSON, or null when t is the zero time
+func utcTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	utc := t.UTC()
+	return &utc
+}
+
+// errorString converts err to a JSON string, or null when err is nil
+func errorString(err error) *string {
+	if err == nil {
+		return nil
+	}
+	msg := err.Error()
+	return &msg
+}
@@ -4,31 +4,98 @@ import (
 	"fmt"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"gitstatus/src/logger"
 	"gitstatus/src/types"
 )
 
 const (
-	ColorReset   = "\033[0m"
-	ColorRed     = "\033[31m"
-	ColorGreen   = "\033[32m"
-	ColorYellow  = "\033[33m"
-	ColorCyan    = "\033[36m"
-	ColorMagenta = "\033[35m"
+	ColorReset      = "\033[0m"
+	ColorRed        = "\033[31m"
+	ColorGreen      = "\033[32m"
+	ColorYellow     = "\033[33m"
+	ColorCyan       = "\033[36m"
+	ColorMagenta    = "\033[35m"
+	ColorBlue       = "\033[34m"
+	ColorBoldRed    = "\033[1;31m"
+	ColorBoldYellow = "\033[1;33m"
 )
 
-func PrintResults(results []types.RepoResult, cfg types.Config, logger *logger.Logger) {
-	hasIssues := false
-	for _, res := range results {
-		if res.HasUnsynced || res.HasUncommitted {
-			hasIssues = true
-			break
+// operationLabels are the markers shown for operations left in progress
+var operationLabels = map[types.Operation]string{
+	types.OperationRebase:     "REBASING",
+	types.OperationAm:         "APPLYING",
+	types.OperationMerge:      "MERGING",
+	types.OperationCherryPick: "CHERRY-PICKING",
+	types.OperationRevert:     "REVERTING",
+	types.OperationBisect:     "BISECTING",
+}
+
+// Output formats accepted in types.Config.Format
+const (
+	FormatText  = "text"
+	FormatJSON  = "json"
+	FormatTree  = "tree"
+	FormatTable = "table"
+)
+
+// Formats lists every supported output format
+var Formats = []string{FormatText, FormatJSON, FormatTree, FormatTable}
+
+// IsValidFormat reports whether format names a supported output format
+func IsValidFormat(format string) bool {
+	for _, f := range Formats {
+		if f == format {
+			return true
 		}
 	}
+	return false
+}
+
+// PrintResults prints results in cfg.Format, keeping only the repositories
+// and branches in the period selected by cfg.Stale and cfg.Since, and
+// returns the results it kept. elapsed is the scan time shown by the summary.
+func PrintResults(results []types.RepoResult, cfg types.Config, elapsed time.Duration, logger *logger.Logger) []types.RepoResult {
+	scanned := len(results)
+	results = filterByAge(results, cfg, time.Now())
+	summary := Summarize(results, elapsed)
+	summary.Scanned = scanned
+
+	if cfg.Format == FormatJSON {
+		if err := printJSON(results, summary, cfg); err != nil {
+			logger.Error("Failed to write JSON output: %v", err)
+		}
+		return results
+	}
 
-	if !hasIssues && !cfg.ShowAll {
-		fmt.Println("No git repositories with unsynced status or uncommitted changes found.")
+	if !cfg.SummaryOnly {
+		switch cfg.Format {
+		case FormatTree:
+			printTree(results, cfg, logger)
+		case FormatTable:
+			printTable(results, cfg, logger)
+		default:
+			printText(results, cfg, logger)
+		}
+	}
+
+	switch {
+	case cfg.SummaryOnly:
+		printSummary(summary, cfg.NoColor)
+	case cfg.Summary:
+		fmt.Println()
+		printSummary(summary, cfg.NoColor)
+	}
+	return results
+}
+
+// NoIssuesMessage is printed by the text formats when nothing needs attention
+const NoIssuesMessage = "No git repositories with unsynced status or uncommitted changes found."
+
+func printText(results []types.RepoResult, cfg types.Config, logger *logger.Logger) {
+	if !anyNeedsAttention(results) && !cfg.ShowAll {
+		fmt.Println(NoIssuesMessage)
 		return
 	}
 
@@ -38,82 +105,259 @@ func PrintResults(results []types.RepoResult, cfg types.Config, logger *logger.L
 			continue
 		}
 
-		if !res.HasUnsynced && !res.HasUncommitted && !cfg.ShowAll {
+		if !NeedsAttention(res) && !cfg.ShowAll {
 			continue
 		}
 
+		label := formatRepoLabel(res)
+		path := DisplayPath(res, cfg)
+
+		if res.HasOperation {
+			line := formatOperationLine(path, res.Operation, cfg.NoColor)
+			fmt.Println(line + label)
+		}
+
+		if res.FetchError != nil {
+			line := formatFetchErrorLine(path, res.FetchError, cfg.NoColor)
+			fmt.Println(line + label)
+		}
+
+		if res.HasDetachedHead {
+			line := formatDetachedLine(path, res.DetachedHead, cfg.NoColor)
+			fmt.Println(line + label)
+		}
+
 		for _, b := range res.Branches {
-			line := formatBranchLine(res.Path, b, cfg.NoColor)
-			fmt.Println(line)
+			line := formatBranchLine(path, b, cfg.NoColor)
+			fmt.Println(line + label)
+			for _, commit := range commitLines(b, cfg.NoColor) {
+				fmt.Println(commitIndent + commit)
+			}
+		}
+
+		if res.HasUncommitted || res.HasStash {
+			line := formatWorkdirLine(path, res.Uncommitted, res.Stash, res.LastModified, cfg.NoColor)
+			fmt.Println(line + label)
 		}
 
-		if res.HasUncommitted {
-			line := formatWorkdirLine(res.Path, res.Uncommitted, cfg.NoColor)
-			fmt.Println(line)
+		if res.HasUnpushedTags {
+			line := formatTagsLine(path, res.UnpushedTags, res.TagsPartial, cfg.NoColor)
+			fmt.Println(line + label)
 		}
 
-		if cfg.ShowAll && !res.HasUnsynced && !res.HasUncommitted {
-			line := formatCleanRepoLine(res.Path, cfg.NoColor)
-			fmt.Println(line)
+		if cfg.ShowAll && !NeedsAttention(res) {
+			line := formatCleanRepoLine(path, cfg.NoColor)
+			fmt.Println(line + label)
 		}
 	}
 }
 
-func formatBranchLine(repoPath string, b types.BranchSyncStatus, noColor bool) string {
-	branchPath := filepath.Join(repoPath, b.Name)
+// DisplayPath returns the path printed for res: absolute by default, relative
+// to the scan root it was found under with -relative. A repository that is
+// the scan root itself is shown by its directory name.
+func DisplayPath(res types.RepoResult, cfg types.Config) string {
+	if !cfg.Relative || res.Root == "" {
+		return res.Path
+	}
+	rel, err := filepath.Rel(res.Root, res.Path)
+	if err != nil {
+		return res.Path
+	}
+	if rel == "." {
+		return filepath.Base(res.Path)
+	}
+	return rel
+}
+
+// NeedsAttention reports whether a repository has anything worth showing
+// when clean repositories are hidden. A detached HEAD only counts when it
+// carries commits no ref points at; submodules are routinely detached.
+func NeedsAttention(res types.RepoResult) bool {
+	return res.HasUnsynced || res.HasUncommitted || res.HasStash || res.HasOperation ||
+		hasOrphanedCommits(res) || res.HasUnpushedTags || res.FetchError != nil
+}
+
+// anyNeedsAttention reports whether any of results needs attention
+func anyNeedsAttention(results []types.RepoResult) bool {
+	for _, res := range results {
+		if NeedsAttention(res) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasOrphanedCommits reports whether res has a detached HEAD with commits
+// that git gc may eventually delete
+func hasOrphanedCommits(res types.RepoResult) bool {
+	return res.HasDetachedHead && res.DetachedHead.Orphaned > 0
+}
+
+// formatRepoLabel returns the " [worktree of /path]" style suffix for repos
+// that belong to another working tree, or "" for standalone repositories.
+func formatRepoLabel(res types.RepoResult) string {
+	if res.Parent == "" {
+		return ""
+	}
+	return fmt.Sprintf(" [%s of %s]", res.Kind, res.Parent)
+}
 
-	parts := []string{branchPath}
+func formatBranchLine(repoPath string, b types.BranchSyncStatus, noColor bool) string {
+	return colorize(filepath.Join(repoPath, b.Name)+branchDetails(b), branchColor(b), noColor)
+}
 
+// branchDetails returns the " [current] (ahead 1, behind 2)" part of a branch
+// line. When the branch was compared with other remotes every count names
+// its ref, e.g. "(ahead origin/main 2, behind upstream/main 12)".
+func branchDetails(b types.BranchSyncStatus) string {
+	text := ""
 	if b.Current {
-		parts = append(parts, "[current]")
+		text += " [current]"
 	}
 
+	named := len(b.Remotes) > 0 || b.Push != ""
 	details := []string{}
 	if b.NoUpstream {
 		details = append(details, "no upstream")
 	} else if b.Gone {
 		details = append(details, "gone")
 	} else {
-		if b.Ahead > 0 {
-			details = append(details, fmt.Sprintf("ahead %d", b.Ahead))
-		}
-		if b.Behind > 0 {
-			details = append(details, fmt.Sprintf("behind %d", b.Behind))
-		}
+		details = append(details, syncDetails(b.Upstream, b.Ahead, b.Behind, named)...)
+	}
+	if b.PushGone {
+		details = append(details, "not pushed to "+b.Push)
+	} else if b.Push != "" {
+		details = append(details, syncDetails(b.Push, b.PushAhead, b.PushBehind, true)...)
+	}
+	for _, r := range b.Remotes {
+		details = append(details, syncDetails(r.Ref, r.Ahead, r.Behind, true)...)
+	}
+	if !b.LastCommit.IsZero() {
+		details = append(details, "last commit "+formatAge(b.LastCommit, time.Now()))
 	}
 
 	if len(details) > 0 {
-		parts = append(parts, fmt.Sprintf("(%s)", strings.Join(details, ", ")))
+		text += fmt.Sprintf(" (%s)", strings.Join(details, ", "))
 	}
+	return text
+}
 
-	line := strings.Join(parts, " ")
+// commitIndent indents the commits listed under a branch line
+const commitIndent = "    "
 
-	if noColor {
-		return line
+// commitLines lists the commits b is ahead of and behind its upstream, e.g.
+// "↑ 1a2b3c4 Fix login redirect (Alice)", noting how many were left out of
+// a list cut short by the commit limit
+func commitLines(b types.BranchSyncStatus, noColor bool) []string {
+	var lines []string
+	for _, list := range []struct {
+		arrow   string
+		commits []types.Commit
+		total   int
+		color   string
+	}{
+		{"↑", b.AheadCommits, b.Ahead, ColorGreen},
+		{"↓", b.BehindCommits, b.Behind, ColorRed},
+	} {
+		for _, c := range list.commits {
+			lines = append(lines, fmt.Sprintf("%s %s %s (%s)", list.arrow, colorize(c.Hash, list.color, noColor), c.Subject, c.Author))
+		}
+		if more := list.total - len(list.commits); len(list.commits) > 0 && more > 0 {
+			lines = append(lines, fmt.Sprintf("... %d more", more))
+		}
 	}
+	return lines
+}
 
-	if b.NoUpstream {
-		return ColorCyan + line + ColorReset
+// syncDetails returns "ahead 1" and "behind 2", with ref before the count
+// when named is set, leaving out zero counts
+func syncDetails(ref string, ahead, behind int, named bool) []string {
+	var details []string
+	for _, c := range []struct {
+		label string
+		n     int
+	}{{"ahead", ahead}, {"behind", behind}} {
+		switch {
+		case c.n == 0:
+		case named:
+			details = append(details, fmt.Sprintf("%s %s %d", c.label, ref, c.n))
+		default:
+			details = append(details, fmt.Sprintf("%s %d", c.label, c.n))
+		}
 	}
-	if b.Gone {
-		return ColorMagenta + line + ColorReset
+	return details
+}
+
+// branchColor returns the color for a branch's sync state, or "" if none. A
+// branch in sync with its upstream is colored by how it compares with its
+// push destination, and then with the other remotes.
+func branchColor(b types.BranchSyncStatus) string {
+	ahead, behind := b.Ahead > 0, b.Behind > 0
+	if !ahead && !behind {
+		ahead, behind = b.PushAhead > 0, b.PushBehind > 0
 	}
-	if b.Ahead > 0 && b.Behind > 0 {
-		return ColorYellow + line + ColorReset
+	if !ahead && !behind {
+		for _, r := range b.Remotes {
+			ahead = ahead || r.Ahead > 0
+			behind = behind || r.Behind > 0
+		}
 	}
-	if b.Ahead > 0 {
-		return ColorGreen + line + ColorReset
+
+	switch {
+	case b.NoUpstream:
+		return ColorCyan
+	case b.Gone:
+		return ColorMagenta
+	case ahead && behind:
+		return ColorYellow
+	case ahead:
+		return ColorGreen
+	case behind:
+		return ColorRed
 	}
-	if b.Behind > 0 {
-		return ColorRed + line + ColorReset
+	return ""
+}
+
+// colorize wraps text in color unless noColor is set or color is ""
+func colorize(text string, color string, noColor bool) string {
+	if noColor || color == "" {
+		return text
 	}
+	return color + text + ColorReset
+}
 
-	return line
+func formatDetachedLine(repoPath string, h types.DetachedHead, noColor bool) string {
+	line := fmt.Sprintf("%s (%s)", filepath.Join(repoPath, "HEAD"), detachedDetails(h))
+	return colorize(line, ColorBlue, noColor)
+}
+
+// detachedDetails describes a detached HEAD, e.g. "detached from v1.0 1a2b3c4, 2 commits only on HEAD"
+func detachedDetails(h types.DetachedHead) string {
+	state := "detached at"
+	if h.Moved {
+		state = "detached from"
+	}
+	details := []string{fmt.Sprintf("%s %s", state, h.From)}
+	if h.Commit != "" && h.Commit != h.From {
+		details[0] += " " + h.Commit
+	}
+	if h.Orphaned > 0 {
+		details = append(details, Pluralize(h.Orphaned, "commit")+" only on HEAD")
+	}
+	return strings.Join(details, ", ")
 }
 
-func formatWorkdirLine(repoPath string, w types.WorkdirStatus, noColor bool) string {
-	parts := []string{repoPath}
+func formatWorkdirLine(repoPath string, w types.WorkdirStatus, stash types.StashStatus, lastModified time.Time, noColor bool) string {
+	line := repoPath
+	if details := workdirDetails(w, stash, lastModified); details != "" {
+		line += " (" + details + ")"
+	}
+	return colorize(line, ColorYellow, noColor)
+}
 
+// workdirDetails lists the non-zero working directory and stash counts, and
+// when the most recently changed file was modified
+func workdirDetails(w types.WorkdirStatus, stash types.StashStatus, lastModified time.Time) string {
 	details := []string{}
 	if w.Modified > 0 {
 		details = append(details, fmt.Sprintf("modified %d", w.Modified))
@@ -124,24 +368,101 @@ func formatWorkdirLine(repoPath string, w types.WorkdirStatus, noColor bool) str
 	if w.Untracked > 0 {
 		details = append(details, fmt.Sprintf("untracked %d", w.Untracked))
 	}
+	if w.Deleted > 0 {
+		details = append(details, fmt.Sprintf("deleted %d", w.Deleted))
+	}
+	if w.Renamed > 0 {
+		details = append(details, fmt.Sprintf("renamed %d", w.Renamed))
+	}
+	if w.TypeChanged > 0 {
+		details = append(details, fmt.Sprintf("type changed %d", w.TypeChanged))
+	}
+	if w.Conflicted > 0 {
+		details = append(details, fmt.Sprintf("conflicted %d", w.Conflicted))
+	}
+	if len(details) > 0 && !lastModified.IsZero() {
+		details = append(details, "last modified "+formatAge(lastModified, time.Now()))
+	}
+	if stash.Count > 0 {
+		details = append(details, fmt.Sprintf("stashes %d", stash.Count))
+		if !stash.Oldest.IsZero() {
+			details = append(details, "oldest stash "+formatAge(stash.Oldest, time.Now()))
+		}
+	}
+	return strings.Join(details, ", ")
+}
 
-	if len(details) > 0 {
-		parts = append(parts, fmt.Sprintf("(%s)", strings.Join(details, ", ")))
+// maxListedTags caps the unpushed tags named on a line
+const maxListedTags = 5
+
+func formatTagsLine(repoPath string, tags []string, partial bool, noColor bool) string {
+	return colorize(fmt.Sprintf("%s (%s)", repoPath, tagsDetails(tags, partial)), ColorGreen, noColor)
+}
+
+// tagsDetails names the unpushed tags, e.g. "tags not pushed: v1.2.0, v1.3.0",
+// noting when partial that the remotes' tags were not listed
+func tagsDetails(tags []string, partial bool) string {
+	label := "tags not pushed: "
+	if len(tags) == 1 {
+		label = "tag not pushed: "
+	}
+	text := label + strings.Join(tags, ", ")
+	if len(tags) > maxListedTags {
+		text = fmt.Sprintf("%s%s and %d more", label, strings.Join(tags[:maxListedTags], ", "), len(tags)-maxListedTags)
+	}
+	if partial {
+		text += "; remote tags not listed, tags on pushed commits not checked"
 	}
+	return text
+}
+
+func formatCleanRepoLine(repoPath string, noColor bool) string {
+	return colorize(repoPath+" (clean)", ColorGreen, noColor)
+}
 
-	line := strings.Join(parts, " ")
+func formatOperationLine(repoPath string, op types.OperationState, noColor bool) string {
+	return colorize(fmt.Sprintf("%s [%s]", repoPath, OperationLabel(op)), ColorBoldYellow, noColor)
+}
 
-	if noColor {
-		return line
+// OperationLabel returns the marker for an operation, e.g. "REBASING 3/7"
+func OperationLabel(op types.OperationState) string {
+	label, ok := operationLabels[op.Operation]
+	if !ok {
+		label = strings.ToUpper(string(op.Operation))
+	}
+	if op.Total > 0 {
+		label = fmt.Sprintf("%s %d/%d", label, op.Step, op.Total)
 	}
+	return label
+}
 
-	return ColorYellow + line + ColorReset
+func formatFetchErrorLine(repoPath string, err error, noColor bool) string {
+	return colorize(fmt.Sprintf("%s (fetch failed: %v)", repoPath, err), ColorBoldRed, noColor)
 }
 
-func formatCleanRepoLine(repoPath string, noColor bool) string {
-	line := repoPath + " (clean)"
-	if noColor {
-		return line
+// formatAge describes how long before now t was, e.g. "3 days ago"
+func formatAge(t time.Time, now time.Time) string {
+	d := now.Sub(t)
+	switch {
+	case d < time.Minute:
+		return "just now"
+	case d < time.Hour:
+		return Pluralize(int(d/time.Minute), "minute") + " ago"
+	case d < 24*time.Hour:
+		return Pluralize(int(d/time.Hour), "hour") + " ago"
+	default:
+		return Pluralize(int(d/(24*time.Hour)), "day") + " ago"
+	}
+}
+
+// Pluralize returns n followed by unit, in the plural unless n is 1, e.g.
+// "1 commit", "3 commits", "2 repositories"
+func Pluralize(n int, unit string) string {
+	if n == 1 {
+		return fmt.Sprintf("%d %s", n, unit)
+	}
+	if stem, ok := strings.CutSuffix(unit, "y"); ok && !strings.ContainsAny(stem[len(stem)-1:], "aeiou") {
+		return fmt.Sprintf("%d %sies", n, stem)
 	}
-	return ColorGreen + line + ColorReset
+	return fmt.Sprintf("%d %ss", n, unit)
 }
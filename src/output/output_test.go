@@ -3,11 +3,14 @@ package output
 import (
 	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"io"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"gitstatus/src/git"
 	"gitstatus/src/logger"
@@ -88,6 +91,90 @@ func TestFormatBranchLineGone(t *testing.T) {
 	}
 }
 
+func TestFormatBranchLineAllRemotes(t *testing.T) {
+	b := types.BranchSyncStatus{
+		Name:     "main",
+		Current:  true,
+		Upstream: "origin/main",
+		Remote:   "origin",
+		Ahead:    2,
+		Remotes: []types.RemoteSyncStatus{
+			{Ref: "upstream/main", Remote: "upstream", Behind: 12},
+			{Ref: "mirror/main", Remote: "mirror"},
+		},
+	}
+
+	result := formatBranchLine("/repo", b, true)
+	if result != "/repo/main [current] (ahead origin/main 2, behind upstream/main 12)" {
+		t.Errorf("Unexpected line: %s", result)
+	}
+
+	// In sync with its upstream, the branch is colored by the other remotes
+	b.Ahead = 0
+	result = formatBranchLine("/repo", b, false)
+	if result != ColorRed+"/repo/main [current] (behind upstream/main 12)"+ColorReset {
+		t.Errorf("Unexpected line: %q", result)
+	}
+}
+
+func TestFormatBranchLinePush(t *testing.T) {
+	b := types.BranchSyncStatus{
+		Name:      "feature",
+		Upstream:  "origin/main",
+		Remote:    "origin",
+		Ahead:     3,
+		Push:      "fork/feature",
+		PushAhead: 1,
+	}
+
+	result := formatBranchLine("/repo", b, true)
+	if result != "/repo/feature (ahead origin/main 3, ahead fork/feature 1)" {
+		t.Errorf("Unexpected line: %s", result)
+	}
+
+	b.PushAhead, b.PushGone = 0, true
+	result = formatBranchLine("/repo", b, true)
+	if result != "/repo/feature (ahead origin/main 3, not pushed to fork/feature)" {
+		t.Errorf("Unexpected line: %s", result)
+	}
+
+	// In sync with its upstream, the branch is colored by its push destination
+	b.Ahead, b.PushGone, b.PushBehind = 0, false, 2
+	result = formatBranchLine("/repo", b, false)
+	if result != ColorRed+"/repo/feature (behind fork/feature 2)"+ColorReset {
+		t.Errorf("Unexpected line: %q", result)
+	}
+}
+
+func TestCommitLines(t *testing.T) {
+	b := types.BranchSyncStatus{
+		Name:   "main",
+		Ahead:  3,
+		Behind: 1,
+		AheadCommits: []types.Commit{
+			{Hash: "1a2b3c4", Subject: "Fix login redirect", Author: "Alice"},
+		},
+		BehindCommits: []types.Commit{
+			{Hash: "9f8e7d6", Subject: "Bump version", Author: "Bob"},
+		},
+	}
+
+	got := strings.Join(commitLines(b, true), "\n")
+	want := "↑ 1a2b3c4 Fix login redirect (Alice)\n... 2 more\n↓ 9f8e7d6 Bump version (Bob)"
+	if got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+
+	if lines := commitLines(types.BranchSyncStatus{Name: "main", Ahead: 3}, true); len(lines) != 0 {
+		t.Errorf("Expected no lines without listed commits, got %q", lines)
+	}
+
+	colored := commitLines(b, false)
+	if !strings.Contains(colored[0], ColorGreen+"1a2b3c4"+ColorReset) || !strings.Contains(colored[2], ColorRed+"9f8e7d6"+ColorReset) {
+		t.Errorf("Expected colored hashes, got %q", colored)
+	}
+}
+
 func TestFormatWorkdirLineModified(t *testing.T) {
 	w := types.WorkdirStatus{
 		Modified:  3,
@@ -95,15 +182,732 @@ func TestFormatWorkdirLineModified(t *testing.T) {
 		Untracked: 0,
 	}
 
-	result := formatWorkdirLine("/repo", w, false)
+	result := formatWorkdirLine("/repo", w, types.StashStatus{}, time.Time{}, false)
 
 	if !strings.Contains(result, "modified 3") {
 		t.Error("Expected result to contain 'modified 3'")
 	}
 }
 
+func TestFormatFetchErrorLine(t *testing.T) {
+	result := formatFetchErrorLine("/repo", errors.New("remote unreachable: fatal: no route"), false)
+
+	if !strings.Contains(result, "/repo (fetch failed: remote unreachable") {
+		t.Errorf("Unexpected fetch error line: %s", result)
+	}
+	if !strings.HasPrefix(result, ColorBoldRed) {
+		t.Errorf("Expected bold red color for fetch failure, got: %s", result)
+	}
+}
+
+func TestDisplayPath(t *testing.T) {
+	res := types.RepoResult{Path: "/work/team/api", Root: "/work"}
+
+	if got := DisplayPath(res, types.Config{}); got != "/work/team/api" {
+		t.Errorf("Expected absolute path by default, got %q", got)
+	}
+	if got := DisplayPath(res, types.Config{Relative: true}); got != filepath.Join("team", "api") {
+		t.Errorf("Expected path relative to root, got %q", got)
+	}
+
+	res = types.RepoResult{Path: "/work/api", Root: "/work/api"}
+	if got := DisplayPath(res, types.Config{Relative: true}); got != "api" {
+		t.Errorf("Expected directory name for repo at the root, got %q", got)
+	}
+}
+
+func TestFormatOperationLine(t *testing.T) {
+	tests := []struct {
+		op   types.OperationState
+		want string
+	}{
+		{types.OperationState{Operation: types.OperationRebase, Step: 3, Total: 7}, "/repo [REBASING 3/7]"},
+		{types.OperationState{Operation: types.OperationMerge}, "/repo [MERGING]"},
+		{types.OperationState{Operation: types.OperationCherryPick}, "/repo [CHERRY-PICKING]"},
+	}
+
+	for _, tt := range tests {
+		if got := formatOperationLine("/repo", tt.op, true); got != tt.want {
+			t.Errorf("formatOperationLine(%+v) = %q, want %q", tt.op, got, tt.want)
+		}
+	}
+
+	colored := formatOperationLine("/repo", types.OperationState{Operation: types.OperationBisect}, false)
+	if !strings.HasPrefix(colored, ColorBoldYellow) {
+		t.Errorf("Expected bold yellow for operation in progress, got: %s", colored)
+	}
+}
+
+func TestFormatDetachedLine(t *testing.T) {
+	tests := []struct {
+		head types.DetachedHead
+		want string
+	}{
+		{types.DetachedHead{Commit: "1a2b3c4", From: "v1.0"}, "/repo/HEAD (detached at v1.0 1a2b3c4)"},
+		{types.DetachedHead{Commit: "1a2b3c4", From: "1a2b3c4"}, "/repo/HEAD (detached at 1a2b3c4)"},
+		{types.DetachedHead{Commit: "5d6e7f8", From: "v1.0", Moved: true, Orphaned: 1}, "/repo/HEAD (detached from v1.0 5d6e7f8, 1 commit only on HEAD)"},
+		{types.DetachedHead{Commit: "5d6e7f8", From: "v1.0", Moved: true, Orphaned: 3}, "/repo/HEAD (detached from v1.0 5d6e7f8, 3 commits only on HEAD)"},
+	}
+
+	for _, tt := range tests {
+		if got := formatDetachedLine("/repo", tt.head, true); got != tt.want {
+			t.Errorf("formatDetachedLine(%+v) = %q, want %q", tt.head, got, tt.want)
+		}
+	}
+
+	colored := formatDetachedLine("/repo", types.DetachedHead{Commit: "1a2b3c4", From: "v1.0"}, false)
+	if !strings.HasPrefix(colored, ColorBlue) {
+		t.Errorf("Expected blue for detached HEAD, got: %s", colored)
+	}
+
+	detached := types.RepoResult{HasDetachedHead: true, DetachedHead: types.DetachedHead{Commit: "1a2b3c4", From: "v1.0"}}
+	if NeedsAttention(detached) {
+		t.Error("Detached HEAD without orphaned commits should not need attention")
+	}
+	detached.DetachedHead.Orphaned = 1
+	if !NeedsAttention(detached) {
+		t.Error("Detached HEAD with orphaned commits should need attention")
+	}
+}
+
+func TestFormatRepoLabel(t *testing.T) {
+	res := types.RepoResult{Path: "/work/wt", Kind: types.RepoKindWorktree, Parent: "/work/main"}
+	if got := formatRepoLabel(res); got != " [worktree of /work/main]" {
+		t.Errorf("Unexpected label: %q", got)
+	}
+
+	res = types.RepoResult{Path: "/work/main", Kind: types.RepoKindRepository}
+	if got := formatRepoLabel(res); got != "" {
+		t.Errorf("Expected no label for standalone repo, got %q", got)
+	}
+}
+
+func TestPrintJSONSchema(t *testing.T) {
+	results := []types.RepoResult{
+		{
+			Path:        "/repo/a",
+			HasUnsynced: true,
+			Branches: []types.BranchSyncStatus{
+				{Name: "main", Current: true, Upstream: "origin/main", Remote: "origin", Ahead: 2, Behind: 1,
+					Push: "fork/main", PushAhead: 3,
+					AheadCommits: []types.Commit{{Hash: "1a2b3c4", Subject: "Fix login redirect", Author: "Alice", Time: time.Unix(1700000000, 0)}},
+					Remotes:      []types.RemoteSyncStatus{{Ref: "upstream/main", Remote: "upstream", Behind: 4}}},
+				{Name: "dev", NoUpstream: true, LastAuthored: time.Unix(1700000000, 0)},
+			},
+			Uncommitted:    types.WorkdirStatus{Modified: 1, Staged: 2, Untracked: 3},
+			HasUncommitted: true,
+			LastModified:   time.Date(2024, 6, 3, 11, 0, 0, 0, time.FixedZone("CEST", 2*3600)),
+		},
+		{
+			Path:  "/repo/b",
+			Error: errors.New("git command failed"),
+		},
+		{
+			Path: "/repo/clean",
+		},
+	}
+
+	out := captureOutput(func() {
+		if err := printJSON(results, Summarize(results, 0), types.Config{Format: FormatJSON}); err != nil {
+			t.Fatalf("printJSON failed: %v", err)
+		}
+	})
+
+	var report struct {
+		SchemaVersion int `json:"schema_version"`
+		Repositories  []struct {
+			Path         string     `json:"path"`
+			LastAuthored *time.Time `json:"last_authored"`
+			LastModified *time.Time `json:"last_modified"`
+			Branches     []struct {
+				Name         string     `json:"name"`
+				LastAuthored *time.Time `json:"last_authored"`
+				AheadCommits []struct {
+					Hash    string     `json:"hash"`
+					Subject string     `json:"subject"`
+					Author  string     `json:"author"`
+					Time    *time.Time `json:"time"`
+				} `json:"ahead_commits"`
+				BehindCommits []struct{} `json:"behind_commits"`
+				Current       bool       `json:"current"`
+				Upstream      string     `json:"upstream"`
+				Remote        string     `json:"remote"`
+				Ahead         int        `json:"ahead"`
+				Behind        int        `json:"behind"`
+				Push          *struct {
+					Ref    string `json:"ref"`
+					Ahead  int    `json:"ahead"`
+					Behind int    `json:"behind"`
+					Gone   bool   `json:"gone"`
+				} `json:"push"`
+				Remotes []struct {
+					Ref    string `json:"ref"`
+					Remote string `json:"remote"`
+					Ahead  int    `json:"ahead"`
+					Behind int    `json:"behind"`
+				} `json:"remotes"`
+			} `json:"branches"`
+			Workdir struct {
+				Modified  int `json:"modified"`
+				Staged    int `json:"staged"`
+				Untracked int `json:"untracked"`
+			} `json:"workdir"`
+			Error *string `json:"error"`
+		} `json:"repositories"`
+	}
+	if err := json.Unmarshal([]byte(out), &report); err != nil {
+		t.Fatalf("Output is not valid JSON: %v\n%s", err, out)
+	}
+
+	if report.SchemaVersion != JSONSchemaVersion {
+		t.Errorf("schema_version = %d, want %d", report.SchemaVersion, JSONSchemaVersion)
+	}
+	if len(report.Repositories) != 2 {
+		t.Fatalf("Expected 2 repositories (clean one filtered), got %d", len(report.Repositories))
+	}
+
+	a := report.Repositories[0]
+	if len(a.Branches) != 2 || a.Branches[0].Name != "main" || !a.Branches[0].Current ||
+		a.Branches[0].Ahead != 2 || a.Branches[0].Behind != 1 {
+		t.Errorf("Unexpected branches: %+v", a.Branches)
+	}
+	if br := a.Branches[0]; br.Upstream != "origin/main" || br.Remote != "origin" || len(br.Remotes) != 1 ||
+		br.Remotes[0].Ref != "upstream/main" || br.Remotes[0].Remote != "upstream" || br.Remotes[0].Behind != 4 {
+		t.Errorf("Unexpected upstream and remotes: %+v", br)
+	}
+	if p := a.Branches[0].Push; p == nil || p.Ref != "fork/main" || p.Ahead != 3 || p.Behind != 0 || p.Gone {
+		t.Errorf("Unexpected push destination: %+v", p)
+	}
+	if a.Branches[1].Push != nil {
+		t.Errorf("Expected null push for a branch pushing to its upstream, got %+v", a.Branches[1].Push)
+	}
+	if a.LastAuthored != nil || a.LastModified == nil || a.LastModified.Location() != time.UTC || a.LastModified.Hour() != 9 {
+		t.Errorf("Unexpected last_authored %v and last_modified %v", a.LastAuthored, a.LastModified)
+	}
+	if a.Branches[0].LastAuthored != nil || a.Branches[1].LastAuthored == nil || a.Branches[1].LastAuthored.Unix() != 1700000000 {
+		t.Errorf("Unexpected branch last_authored: %v, %v", a.Branches[0].LastAuthored, a.Branches[1].LastAuthored)
+	}
+	if c := a.Branches[0].AheadCommits; len(c) != 1 || c[0].Hash != "1a2b3c4" || c[0].Subject != "Fix login redirect" ||
+		c[0].Author != "Alice" || c[0].Time == nil || c[0].Time.Unix() != 1700000000 {
+		t.Errorf("Unexpected ahead_commits: %+v", c)
+	}
+	if br := a.Branches[1]; br.AheadCommits == nil || len(br.AheadCommits) != 0 || br.BehindCommits == nil {
+		t.Errorf("Expected empty commit arrays, got %+v and %+v", br.AheadCommits, br.BehindCommits)
+	}
+	if a.Workdir.Modified != 1 || a.Workdir.Staged != 2 || a.Workdir.Untracked != 3 {
+		t.Errorf("Unexpected workdir: %+v", a.Workdir)
+	}
+	if a.Error != nil {
+		t.Errorf("Expected null error, got %q", *a.Error)
+	}
+
+	b := report.Repositories[1]
+	if b.Error == nil || *b.Error != "git command failed" {
+		t.Errorf("Expected error string, got %v", b.Error)
+	}
+}
+
+func TestFormatTagsLine(t *testing.T) {
+	tests := []struct {
+		tags    []string
+		partial bool
+		want    string
+	}{
+		{[]string{"v1.0"}, false, "/repo (tag not pushed: v1.0)"},
+		{[]string{"v1.0", "v1.1"}, false, "/repo (tags not pushed: v1.0, v1.1)"},
+		{[]string{"a", "b", "c", "d", "e", "f", "g"}, false, "/repo (tags not pushed: a, b, c, d, e and 2 more)"},
+		{[]string{"v1.0"}, true, "/repo (tag not pushed: v1.0; remote tags not listed, tags on pushed commits not checked)"},
+	}
+	for _, tt := range tests {
+		if got := formatTagsLine("/repo", tt.tags, tt.partial, true); got != tt.want {
+			t.Errorf("formatTagsLine(%v) = %q, want %q", tt.tags, got, tt.want)
+		}
+	}
+
+	if got := formatTagsLine("/repo", []string{"v1.0"}, false, false); got != ColorGreen+"/repo (tag not pushed: v1.0)"+ColorReset {
+		t.Errorf("Colored tags line = %q", got)
+	}
+}
+
+func TestFormatWorkdirLineStash(t *testing.T) {
+	stash := types.StashStatus{Count: 2, Oldest: time.Now().Add(-72 * time.Hour)}
+
+	result := formatWorkdirLine("/repo", types.WorkdirStatus{}, stash, time.Time{}, true)
+
+	if result != "/repo (stashes 2, oldest stash 3 days ago)" {
+		t.Errorf("Unexpected workdir line: %q", result)
+	}
+}
+
+func TestFormatActivity(t *testing.T) {
+	b := types.BranchSyncStatus{Name: "feature", Upstream: "origin/feature", Ahead: 3, LastCommit: time.Now().Add(-41 * 24 * time.Hour)}
+	if result := formatBranchLine("/repo", b, true); result != "/repo/feature (ahead 3, last commit 41 days ago)" {
+		t.Errorf("Unexpected branch line: %q", result)
+	}
+
+	w := types.WorkdirStatus{Modified: 2}
+	if result := formatWorkdirLine("/repo", w, types.StashStatus{}, time.Now().Add(-5*time.Hour), true); result != "/repo (modified 2, last modified 5 hours ago)" {
+		t.Errorf("Unexpected workdir line: %q", result)
+	}
+}
+
+func TestPluralize(t *testing.T) {
+	tests := []struct {
+		n    int
+		unit string
+		want string
+	}{
+		{1, "commit", "1 commit"},
+		{0, "commit", "0 commits"},
+		{1, "repository", "1 repository"},
+		{2, "repository", "2 repositories"},
+		{3, "day", "3 days"},
+	}
+	for _, tt := range tests {
+		if got := Pluralize(tt.n, tt.unit); got != tt.want {
+			t.Errorf("Pluralize(%d, %q) = %q, want %q", tt.n, tt.unit, got, tt.want)
+		}
+	}
+}
+
+func TestFormatAge(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		t    time.Time
+		want string
+	}{
+		{now.Add(-10 * time.Second), "just now"},
+		{now.Add(-1 * time.Minute), "1 minute ago"},
+		{now.Add(-45 * time.Minute), "45 minutes ago"},
+		{now.Add(-5 * time.Hour), "5 hours ago"},
+		{now.Add(-41 * 24 * time.Hour), "41 days ago"},
+	}
+
+	for _, tt := range tests {
+		if got := formatAge(tt.t, now); got != tt.want {
+			t.Errorf("formatAge(%v) = %q, want %q", now.Sub(tt.t), got, tt.want)
+		}
+	}
+}
+
+func TestPrintTree(t *testing.T) {
+	logger, _ := logger.NewLogger([]string{}, "")
+
+	results := []types.RepoResult{
+		{
+			Path:        "/work/api",
+			Root:        "/work",
+			HasUnsynced: true,
+			Branches: []types.BranchSyncStatus{
+				{Name: "main", Current: true, Ahead: 2, AheadCommits: []types.Commit{
+					{Hash: "1a2b3c4", Subject: "Fix login redirect", Author: "Alice"},
+					{Hash: "5d6e7f8", Subject: "Add session store", Author: "Alice"},
+				}},
+				{Name: "feature/auth", NoUpstream: true},
+			},
+			Uncommitted:    types.WorkdirStatus{Modified: 1},
+			HasUncommitted: true,
+		},
+		{
+			Path:         "/work/api/libs/shared",
+			Root:         "/work",
+			Kind:         types.RepoKindSubmodule,
+			Parent:       "/work/api",
+			Operation:    types.OperationState{Operation: types.OperationMerge},
+			HasOperation: true,
+		},
+		{Path: "/work/clean", Root: "/work"},
+		{
+			Path:        "/work/clients/web/app",
+			Root:        "/work",
+			HasUnsynced: true,
+			Branches: []types.BranchSyncStatus{{Name: "develop", Behind: 5, BehindCommits: []types.Commit{
+				{Hash: "9f8e7d6", Subject: "Bump version", Author: "Bob"},
+			}}},
+		},
+		{Path: "/work/broken", Root: "/work", Error: errors.New("git command failed")},
+		{
+			Path:        "/oss/tool",
+			Root:        "/oss/tool",
+			HasUnsynced: true,
+			Branches:    []types.BranchSyncStatus{{Name: "main", Current: true, Gone: true}},
+		},
+	}
+
+	cfg := types.Config{RootPaths: []string{"/work", "/oss/tool"}, NoColor: true}
+	output := captureOutput(func() {
+		printTree(results, cfg, logger)
+	})
+
+	want := `/work/
+├── api
+│   ├── main [current] (ahead 2)
+│   │   ├── ↑ 1a2b3c4 Fix login redirect (Alice)
+│   │   └── ↑ 5d6e7f8 Add session store (Alice)
+│   ├── feature/auth (no upstream)
+│   ├── working tree (modified 1)
+│   └── libs/shared [submodule of /work/api]
+│       └── [MERGING]
+└── clients/web/app
+    └── develop (behind 5)
+        ├── ↓ 9f8e7d6 Bump version (Bob)
+        └── ... 4 more
+
+/oss/tool
+└── main [current] (gone)
+`
+	if output != want {
+		t.Errorf("Unexpected tree output:\n%s\nwant:\n%s", output, want)
+	}
+
+	cfg.ShowAll = true
+	output = captureOutput(func() {
+		printTree(results, cfg, logger)
+	})
+	if !strings.Contains(output, "├── clean (clean)\n") {
+		t.Errorf("Expected clean repository with ShowAll, got:\n%s", output)
+	}
+
+	cfg.NoColor = false
+	output = captureOutput(func() {
+		printTree(results, cfg, logger)
+	})
+	if !strings.Contains(output, "│   ├── "+ColorGreen+"main [current] (ahead 2)"+ColorReset) {
+		t.Errorf("Expected colored branch line after the connector, got:\n%s", output)
+	}
+}
+
+func TestPrintTable(t *testing.T) {
+	logger, _ := logger.NewLogger([]string{}, "")
+	now := time.Now()
+
+	results := []types.RepoResult{
+		{
+			Path:        "/work/api",
+			Root:        "/work",
+			Branch:      "main",
+			LastCommit:  now.Add(-3 * 24 * time.Hour),
+			HasUnsynced: true,
+			Branches: []types.BranchSyncStatus{
+				{Name: "main", Current: true, Ahead: 2, Behind: 1, LastCommit: now.Add(-3 * 24 * time.Hour)},
+				{Name: "feature/auth", NoUpstream: true, Push: "fork/feature/auth", PushGone: true, LastCommit: now.Add(-5 * time.Hour)},
+			},
+			Uncommitted:    types.WorkdirStatus{Modified: 1, Untracked: 12},
+			HasUncommitted: true,
+		},
+		{
+			Path:            "/work/tool",
+			Root:            "/work",
+			LastCommit:      now.Add(-10 * time.Minute),
+			DetachedHead:    types.DetachedHead{Commit: "1a2b3c4", From: "v1.0", Moved: true, Orphaned: 1},
+			HasDetachedHead: true,
+			Stash:           types.StashStatus{Count: 2},
+			HasStash:        true,
+			UnpushedTags:    []string{"v1.0.1"},
+			HasUnpushedTags: true,
+		},
+	}
+
+	cfg := types.Config{Relative: true}
+	output := captureOutput(func() {
+		printTable(results, cfg, logger)
+	})
+	want := `PATH  BRANCH      AHEAD  BEHIND  UPSTREAM  MODIFIED  STAGED  UNTRACKED  STASHES  TAGS  AGE
+api   main            2       1  diverged         1       0         12        0     0   3d
+tool  (detached)      -       -  detached         0       0          0        2     1  10m
+`
+	if output != want {
+		t.Errorf("Unexpected table:\n%s\nwant:\n%s", output, want)
+	}
+
+	cfg.PerBranch = true
+	cfg.Columns = []string{ColumnBranch, ColumnUpstream, ColumnPush, ColumnAge, ColumnPath}
+	output = captureOutput(func() {
+		printTable(results, cfg, logger)
+	})
+	want = `BRANCH        UPSTREAM  PUSH        AGE  PATH
+main          diverged  -            3d  api
+feature/auth  none      not pushed   5h  api
+(detached)    detached  -           10m  tool
+`
+	if output != want {
+		t.Errorf("Unexpected per-branch table:\n%s\nwant:\n%s", output, want)
+	}
+
+	cfg.Sort = SortAge
+	cfg.Columns = []string{ColumnPath, ColumnBranch, ColumnAge}
+	output = captureOutput(func() {
+		printTable([]types.RepoResult{results[1], results[0]}, cfg, logger)
+	})
+	want = `PATH  BRANCH        AGE
+api   main           3d
+api   feature/auth   5h
+tool  (detached)    10m
+`
+	if output != want {
+		t.Errorf("Unexpected table sorted by age:\n%s\nwant:\n%s", output, want)
+	}
+}
+
+func TestFormatTableLineColor(t *testing.T) {
+	columns := []string{ColumnBranch, ColumnAhead, ColumnUpstream}
+	widths := []int{6, 5, 8}
+	line := []tableCell{{text: "main"}, {text: "2", color: ColorGreen}, {text: "ahead", color: ColorGreen}}
+
+	want := "main        " + ColorGreen + "2" + ColorReset + "  " + ColorGreen + "ahead" + ColorReset
+	if got := formatTableLine(line, columns, widths, false); got != want {
+		t.Errorf("formatTableLine = %q, want %q", got, want)
+	}
+	if got := formatTableLine(line, columns, widths, true); got != "main        2  ahead" {
+		t.Errorf("formatTableLine without color = %q", got)
+	}
+}
+
+func TestParseColumns(t *testing.T) {
+	columns, err := ParseColumns(" Path,age ,,branch")
+	if err != nil {
+		t.Fatalf("ParseColumns failed: %v", err)
+	}
+	if strings.Join(columns, ",") != "path,age,branch" {
+		t.Errorf("ParseColumns = %v", columns)
+	}
+
+	for _, bad := range []string{"path,size", "", " , "} {
+		if _, err := ParseColumns(bad); err == nil {
+			t.Errorf("ParseColumns(%q) succeeded, want error", bad)
+		}
+	}
+}
+
+func TestParseAge(t *testing.T) {
+	tests := map[string]time.Duration{
+		"30d":  30 * 24 * time.Hour,
+		" 2w ": 14 * 24 * time.Hour,
+		"12h":  12 * time.Hour,
+		"90m":  90 * time.Minute,
+		"0d":   0,
+	}
+	for s, want := range tests {
+		if got, err := ParseAge(s); err != nil || got != want {
+			t.Errorf("ParseAge(%q) = %v, %v, want %v", s, got, err, want)
+		}
+	}
+
+	for _, bad := range []string{"", "d", "1.5d", "-2d", "-1h", "soon"} {
+		if _, err := ParseAge(bad); err == nil {
+			t.Errorf("ParseAge(%q) succeeded, want error", bad)
+		}
+	}
+}
+
+func TestFilterByAge(t *testing.T) {
+	now := time.Now()
+	days := func(n int) time.Time { return now.Add(-time.Duration(n) * 24 * time.Hour) }
+
+	results := []types.RepoResult{
+		{
+			Path:        "/work/active",
+			LastCommit:  days(1),
+			HasUnsynced: true,
+			Branches: []types.BranchSyncStatus{
+				{Name: "main", Current: true, Ahead: 1, LastCommit: days(1)},
+				{Name: "abandoned", NoUpstream: true, LastCommit: days(60)},
+			},
+		},
+		{Path: "/work/old", LastCommit: days(90)},
+		{Path: "/work/edited", LastCommit: days(90), LastModified: days(0), HasUncommitted: true},
+		{Path: "/work/broken", Error: errors.New("git command failed")},
+	}
+
+	paths := func(filtered []types.RepoResult) string {
+		var names []string
+		for _, res := range filtered {
+			name := filepath.Base(res.Path)
+			for _, b := range res.Branches {
+				name += ":" + b.Name
+			}
+			names = append(names, name)
+		}
+		return strings.Join(names, " ")
+	}
+
+	stale := filterByAge(results, types.Config{Stale: 30 * 24 * time.Hour}, now)
+	if got := paths(stale); got != "active:abandoned old broken" {
+		t.Errorf("-stale 30d kept %q", got)
+	}
+
+	since := filterByAge(results, types.Config{Since: 2 * 24 * time.Hour}, now)
+	if got := paths(since); got != "active:main edited broken" {
+		t.Errorf("-since 2d kept %q", got)
+	}
+
+	both := filterByAge(results, types.Config{Stale: 30 * 24 * time.Hour, Since: 70 * 24 * time.Hour}, now)
+	if got := paths(both); got != "active:abandoned broken" {
+		t.Errorf("-stale 30d -since 70d kept %q", got)
+	}
+	if !both[0].HasUnsynced {
+		t.Error("A repository with a branch left should stay unsynced")
+	}
+
+	if got := filterByAge(results, types.Config{}, now); len(got) != len(results) {
+		t.Errorf("Without filters %d of %d repositories were kept", len(got), len(results))
+	}
+}
+
+func TestFormatShortAge(t *testing.T) {
+	now := time.Now()
+	tests := map[time.Duration]string{
+		10 * time.Second: "now",
+		5 * time.Minute:  "5m",
+		3 * time.Hour:    "3h",
+		400 * time.Hour:  "16d",
+	}
+	for d, want := range tests {
+		if got := FormatShortAge(now.Add(-d), now); got != want {
+			t.Errorf("FormatShortAge(%v) = %q, want %q", d, got, want)
+		}
+	}
+}
+
 // Integration tests using real repos
 
+func TestSummarize(t *testing.T) {
+	results := []types.RepoResult{
+		{
+			Path:        "/repo/a",
+			HasUnsynced: true,
+			Branches: []types.BranchSyncStatus{
+				{Name: "main", Current: true, Ahead: 2, Behind: 1},
+				{Name: "old", Gone: true},
+				{Name: "wip", NoUpstream: true},
+			},
+			HasUncommitted: true,
+		},
+		{
+			Path:        "/repo/b",
+			HasUnsynced: true,
+			Branches:    []types.BranchSyncStatus{{Name: "main", Current: true, Behind: 3}},
+		},
+		{Path: "/repo/c", Error: errors.New("git command failed")},
+		{Path: "/repo/d", FetchError: errors.New("could not read from remote")},
+		{Path: "/repo/e", UnpushedTags: []string{"v1.0"}, HasUnpushedTags: true},
+		{Path: "/repo/clean"},
+	}
+
+	got := Summarize(results, 1500*time.Millisecond)
+	want := Summary{
+		Scanned:    6,
+		Matched:    6,
+		Clean:      1,
+		Unpushed:   1,
+		Behind:     2,
+		Gone:       1,
+		NoUpstream: 1,
+		Dirty:      1,
+		Tags:       1,
+		Errors:     2,
+		Elapsed:    1500 * time.Millisecond,
+	}
+	if got != want {
+		t.Errorf("Summarize() = %+v, want %+v", got, want)
+	}
+}
+
+func TestPrintSummary(t *testing.T) {
+	results := []types.RepoResult{
+		{
+			Path:           "/repo/a",
+			HasUncommitted: true,
+			Uncommitted:    types.WorkdirStatus{Modified: 1},
+		},
+		{Path: "/repo/clean"},
+	}
+
+	t.Run("Footer", func(t *testing.T) {
+		cfg := types.Config{NoColor: true, Summary: true}
+		out := captureOutput(func() {
+			PrintResults(results, cfg, 250*time.Millisecond, nil)
+		})
+
+		if !strings.Contains(out, "/repo/a (modified 1)") {
+			t.Errorf("Results should be printed before the summary:\n%s", out)
+		}
+		if !strings.Contains(out, "\nScanned 2 repositories in 250ms\n") {
+			t.Errorf("Missing summary header:\n%s", out)
+		}
+		for _, line := range []string{"  clean             1", "  dirty             1", "  errors            0"} {
+			if !strings.Contains(out, line+"\n") {
+				t.Errorf("Missing summary line %q:\n%s", line, out)
+			}
+		}
+	})
+
+	t.Run("SummaryOnly", func(t *testing.T) {
+		cfg := types.Config{NoColor: true, Format: FormatTable, SummaryOnly: true}
+		out := captureOutput(func() {
+			PrintResults(results, cfg, 0, nil)
+		})
+
+		if strings.Contains(out, "/repo/a") || strings.Contains(out, "PATH") {
+			t.Errorf("Results should not be printed with SummaryOnly:\n%s", out)
+		}
+		if !strings.HasPrefix(out, "Scanned 2 repositories in 0s\n") {
+			t.Errorf("Unexpected summary:\n%s", out)
+		}
+	})
+
+	t.Run("JSON", func(t *testing.T) {
+		cfg := types.Config{Format: FormatJSON, SummaryOnly: true}
+		out := captureOutput(func() {
+			PrintResults(results, cfg, 1200*time.Millisecond, nil)
+		})
+
+		var report struct {
+			Repositories []json.RawMessage `json:"repositories"`
+			Summary      struct {
+				Scanned   int   `json:"scanned"`
+				Matched   int   `json:"matched"`
+				Clean     int   `json:"clean"`
+				Dirty     int   `json:"dirty"`
+				ElapsedMS int64 `json:"elapsed_ms"`
+			} `json:"summary"`
+		}
+		if err := json.Unmarshal([]byte(out), &report); err != nil {
+			t.Fatalf("Output is not valid JSON: %v\n%s", err, out)
+		}
+		if len(report.Repositories) != 0 {
+			t.Errorf("Expected no repositories with SummaryOnly, got %d", len(report.Repositories))
+		}
+		if s := report.Summary; s.Scanned != 2 || s.Matched != 2 || s.Clean != 1 || s.Dirty != 1 || s.ElapsedMS != 1200 {
+			t.Errorf("Unexpected summary: %+v", s)
+		}
+	})
+
+	t.Run("Filtered", func(t *testing.T) {
+		recent := []types.RepoResult{
+			{Path: "/repo/new", LastCommit: time.Now().Add(-time.Hour), HasUncommitted: true, Uncommitted: types.WorkdirStatus{Modified: 1}},
+			{Path: "/repo/old", LastCommit: time.Now().Add(-30 * 24 * time.Hour), HasUncommitted: true, Uncommitted: types.WorkdirStatus{Modified: 1}},
+			{Path: "/repo/clean", LastCommit: time.Now().Add(-30 * 24 * time.Hour)},
+		}
+		cfg := types.Config{NoColor: true, SummaryOnly: true, Since: 24 * time.Hour}
+		var shown []types.RepoResult
+		out := captureOutput(func() {
+			shown = PrintResults(recent, cfg, 0, nil)
+		})
+
+		if len(shown) != 1 || shown[0].Path != "/repo/new" {
+			t.Errorf("Expected only the recent repository to be returned, got %+v", shown)
+		}
+		if !strings.HasPrefix(out, "Scanned 3 repositories in 0s, 1 matching -stale/-since\n") {
+			t.Errorf("Unexpected summary header:\n%s", out)
+		}
+		// Every total counts the repositories shown
+		if !strings.Contains(out, "  clean             0\n") || !strings.Contains(out, "  dirty             1\n") {
+			t.Errorf("Unexpected totals:\n%s", out)
+		}
+	})
+}
+
 func captureOutput(f func()) string {
 	old := os.Stdout
 	r, w, _ := os.Pipe()
@@ -126,12 +930,12 @@ func TestPrintResultsReal(t *testing.T) {
 
 	t.Run("CleanRepo_ShowAllFalse", func(t *testing.T) {
 		repoPath := filepath.Join(testEnv, "repo_synced")
-		result, _ := git.GetRepoStatus(ctx, repoPath, logger)
+		result, _ := git.GetRepoStatus(ctx, repoPath, git.BackendExec, logger)
 
 		cfg := types.Config{ShowAll: false, NoColor: true}
 
 		output := captureOutput(func() {
-			PrintResults([]types.RepoResult{*result}, cfg, logger)
+			PrintResults([]types.RepoResult{*result}, cfg, 0, logger)
 		})
 
 		if strings.Contains(output, "repo_synced") {
@@ -141,12 +945,12 @@ func TestPrintResultsReal(t *testing.T) {
 
 	t.Run("CleanRepo_ShowAllTrue", func(t *testing.T) {
 		repoPath := filepath.Join(testEnv, "repo_synced")
-		result, _ := git.GetRepoStatus(ctx, repoPath, logger)
+		result, _ := git.GetRepoStatus(ctx, repoPath, git.BackendExec, logger)
 
 		cfg := types.Config{ShowAll: true, NoColor: true}
 
 		output := captureOutput(func() {
-			PrintResults([]types.RepoResult{*result}, cfg, logger)
+			PrintResults([]types.RepoResult{*result}, cfg, 0, logger)
 		})
 
 		if !strings.Contains(output, "repo_synced") {
@@ -159,12 +963,12 @@ func TestPrintResultsReal(t *testing.T) {
 
 	t.Run("RepoAhead", func(t *testing.T) {
 		repoPath := filepath.Join(testEnv, "repo_ahead")
-		result, _ := git.GetRepoStatus(ctx, repoPath, logger)
+		result, _ := git.GetRepoStatus(ctx, repoPath, git.BackendExec, logger)
 
 		cfg := types.Config{ShowAll: false, NoColor: true}
 
 		output := captureOutput(func() {
-			PrintResults([]types.RepoResult{*result}, cfg, logger)
+			PrintResults([]types.RepoResult{*result}, cfg, 0, logger)
 		})
 
 		if !strings.Contains(output, "repo_ahead") {
@@ -177,12 +981,12 @@ func TestPrintResultsReal(t *testing.T) {
 
 	t.Run("RepoBehind", func(t *testing.T) {
 		repoPath := filepath.Join(testEnv, "repo_behind")
-		result, _ := git.GetRepoStatus(ctx, repoPath, logger)
+		result, _ := git.GetRepoStatus(ctx, repoPath, git.BackendExec, logger)
 
 		cfg := types.Config{ShowAll: false, NoColor: true}
 
 		output := captureOutput(func() {
-			PrintResults([]types.RepoResult{*result}, cfg, logger)
+			PrintResults([]types.RepoResult{*result}, cfg, 0, logger)
 		})
 
 		if !strings.Contains(output, "repo_behind") {
@@ -195,12 +999,12 @@ func TestPrintResultsReal(t *testing.T) {
 
 	t.Run("RepoModified", func(t *testing.T) {
 		repoPath := filepath.Join(testEnv, "repo_modified")
-		result, _ := git.GetRepoStatus(ctx, repoPath, logger)
+		result, _ := git.GetRepoStatus(ctx, repoPath, git.BackendExec, logger)
 
 		cfg := types.Config{ShowAll: false, NoColor: true}
 
 		output := captureOutput(func() {
-			PrintResults([]types.RepoResult{*result}, cfg, logger)
+			PrintResults([]types.RepoResult{*result}, cfg, 0, logger)
 		})
 
 		if !strings.Contains(output, "repo_modified") {
@@ -213,12 +1017,12 @@ func TestPrintResultsReal(t *testing.T) {
 
 	t.Run("RepoUntracked", func(t *testing.T) {
 		repoPath := filepath.Join(testEnv, "repo_untracked")
-		result, _ := git.GetRepoStatus(ctx, repoPath, logger)
+		result, _ := git.GetRepoStatus(ctx, repoPath, git.BackendExec, logger)
 
 		cfg := types.Config{ShowAll: false, NoColor: true}
 
 		output := captureOutput(func() {
-			PrintResults([]types.RepoResult{*result}, cfg, logger)
+			PrintResults([]types.RepoResult{*result}, cfg, 0, logger)
 		})
 
 		if !strings.Contains(output, "repo_untracked") {
@@ -231,12 +1035,12 @@ func TestPrintResultsReal(t *testing.T) {
 
 	t.Run("RepoNoUpstream", func(t *testing.T) {
 		repoPath := filepath.Join(testEnv, "repo_no_upstream")
-		result, _ := git.GetRepoStatus(ctx, repoPath, logger)
+		result, _ := git.GetRepoStatus(ctx, repoPath, git.BackendExec, logger)
 
 		cfg := types.Config{ShowAll: false, NoColor: true}
 
 		output := captureOutput(func() {
-			PrintResults([]types.RepoResult{*result}, cfg, logger)
+			PrintResults([]types.RepoResult{*result}, cfg, 0, logger)
 		})
 
 		if !strings.Contains(output, "repo_no_upstream") {
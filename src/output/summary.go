@@ -0,0 +1,118 @@
+package output
+
+import (
+	"fmt"
+	"time"
+
+	"gitstatus/src/types"
+)
+
+// Summary holds totals across the repositories left after -stale and
+// -since. Counts of gone and no-upstream are branches; the others are
+// repositories.
+type Summary struct {
+	Scanned    int // repositories scanned, including those -stale and -since left out
+	Matched    int // repositories left after -stale and -since, which the other totals count
+	Clean      int
+	Unpushed   int // repositories with a branch ahead of its upstream or push destination
+	Behind     int // repositories with a branch behind its upstream, push destination or another remote
+	Gone       int
+	NoUpstream int
+	Dirty      int // repositories with uncommitted changes
+	Tags       int // repositories with tags no remote has
+	Errors     int // repositories that failed to be analyzed or fetched
+	Elapsed    time.Duration
+}
+
+// Summarize computes the totals of results. elapsed is the scan time.
+func Summarize(results []types.RepoResult, elapsed time.Duration) Summary {
+	s := Summary{Scanned: len(results), Matched: len(results), Elapsed: elapsed}
+	for _, res := range results {
+		if res.Error != nil || res.FetchError != nil {
+			s.Errors++
+		}
+		if res.Error == nil && !NeedsAttention(res) {
+			s.Clean++
+		}
+		if res.HasUncommitted {
+			s.Dirty++
+		}
+		if res.HasUnpushedTags {
+			s.Tags++
+		}
+
+		ahead, behind := false, false
+		for _, b := range res.Branches {
+			switch {
+			case b.NoUpstream:
+				s.NoUpstream++
+			case b.Gone:
+				s.Gone++
+			default:
+				ahead = ahead || b.Ahead > 0
+				behind = behind || b.Behind > 0
+			}
+			ahead = ahead || b.PushAhead > 0
+			behind = behind || b.PushBehind > 0
+			// With -all-remotes falling behind another remote counts too
+			for _, r := range b.Remotes {
+				behind = behind || r.Behind > 0
+			}
+		}
+		if ahead {
+			s.Unpushed++
+		}
+		if behind {
+			s.Behind++
+		}
+	}
+	return s
+}
+
+// summaryLines returns the summary footer of the text formats. Non-zero
+// counts are colored like the lines they total.
+func summaryLines(s Summary, noColor bool) []string {
+	counts := []struct {
+		label string
+		n     int
+		color string
+	}{
+		{"clean", s.Clean, ""},
+		{"unpushed", s.Unpushed, ColorGreen},
+		{"behind", s.Behind, ColorRed},
+		{"gone upstream", s.Gone, ColorMagenta},
+		{"no upstream", s.NoUpstream, ColorCyan},
+		{"dirty", s.Dirty, ColorYellow},
+		{"unpushed tags", s.Tags, ColorGreen},
+		{"errors", s.Errors, ColorRed},
+	}
+
+	header := fmt.Sprintf("Scanned %s in %s", Pluralize(s.Scanned, "repository"), formatElapsed(s.Elapsed))
+	if s.Matched != s.Scanned {
+		header += fmt.Sprintf(", %d matching -stale/-since", s.Matched)
+	}
+	lines := []string{header}
+	for _, c := range counts {
+		color := c.color
+		if c.n == 0 {
+			color = ""
+		}
+		lines = append(lines, colorize(fmt.Sprintf("  %-14s%5d", c.label, c.n), color, noColor))
+	}
+	return lines
+}
+
+// printSummary prints the summary footer
+func printSummary(s Summary, noColor bool) {
+	for _, line := range summaryLines(s, noColor) {
+		fmt.Println(line)
+	}
+}
+
+// formatElapsed rounds d for display, e.g. "1.3s" or "250ms"
+func formatElapsed(d time.Duration) string {
+	if d < time.Second {
+		return d.Round(time.Millisecond).String()
+	}
+	return d.Round(100 * time.Millisecond).String()
+}
@@ -0,0 +1,389 @@
+package output
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"gitstatus/src/defaults"
+	"gitstatus/src/logger"
+	"gitstatus/src/types"
+)
+
+// Columns of the table format
+const (
+	ColumnPath      = "path"
+	ColumnBranch    = "branch"
+	ColumnAhead     = "ahead"
+	ColumnBehind    = "behind"
+	ColumnUpstream  = "upstream"
+	ColumnPush      = "push"
+	ColumnModified  = "modified"
+	ColumnStaged    = "staged"
+	ColumnUntracked = "untracked"
+	ColumnStashes   = "stashes"
+	ColumnTags      = "tags"
+	ColumnAge       = "age"
+)
+
+// Columns lists every column accepted by -columns
+var Columns = []string{
+	ColumnPath,
+	ColumnBranch,
+	ColumnAhead,
+	ColumnBehind,
+	ColumnUpstream,
+	ColumnPush,
+	ColumnModified,
+	ColumnStaged,
+	ColumnUntracked,
+	ColumnStashes,
+	ColumnTags,
+	ColumnAge,
+}
+
+// Orders of the table rows accepted in types.Config.Sort
+const (
+	SortPath = "path"
+	SortAge  = "age"
+)
+
+// Sorts lists every supported order of the table rows
+var Sorts = []string{SortPath, SortAge}
+
+// IsValidSort reports whether sort names a supported order
+func IsValidSort(sort string) bool {
+	for _, s := range Sorts {
+		if s == sort {
+			return true
+		}
+	}
+	return false
+}
+
+// rightAligned are the numeric columns
+var rightAligned = map[string]bool{
+	ColumnAhead:     true,
+	ColumnBehind:    true,
+	ColumnModified:  true,
+	ColumnStaged:    true,
+	ColumnUntracked: true,
+	ColumnStashes:   true,
+	ColumnTags:      true,
+	ColumnAge:       true,
+}
+
+// columnSeparator is printed between table columns
+const columnSeparator = "  "
+
+// ParseColumns parses a comma-separated list of table columns
+func ParseColumns(s string) ([]string, error) {
+	var columns []string
+	for _, c := range strings.Split(s, ",") {
+		c = strings.ToLower(strings.TrimSpace(c))
+		if c == "" {
+			continue
+		}
+		if !isColumn(c) {
+			return nil, fmt.Errorf("unknown column %q (expected one of: %s)", c, strings.Join(Columns, ", "))
+		}
+		columns = append(columns, c)
+	}
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("no columns selected (expected some of: %s)", strings.Join(Columns, ", "))
+	}
+	return columns, nil
+}
+
+func isColumn(c string) bool {
+	for _, known := range Columns {
+		if c == known {
+			return true
+		}
+	}
+	return false
+}
+
+// tableRow is a repository, or one of its branches with -per-branch
+type tableRow struct {
+	res    types.RepoResult
+	path   string
+	branch *types.BranchSyncStatus // nil when HEAD is detached or the current branch is in sync
+	name   string                  // branch shown in the branch column
+	head   bool                    // the row describes HEAD, so it carries the working directory counts
+}
+
+// tableCell is the text of a cell and the color it is printed in
+type tableCell struct {
+	text  string
+	color string
+}
+
+// printTable prints one aligned row per repository, or per branch with
+// cfg.PerBranch. Colors are only used when stdout is a terminal.
+func printTable(results []types.RepoResult, cfg types.Config, logger *logger.Logger) {
+	if !anyNeedsAttention(results) && !cfg.ShowAll {
+		fmt.Println(NoIssuesMessage)
+		return
+	}
+
+	columns := cfg.Columns
+	if len(columns) == 0 {
+		columns, _ = ParseColumns(defaults.DefaultColumns)
+	}
+	noColor := cfg.NoColor || !IsTerminal(os.Stdout)
+
+	var rows []tableRow
+	for _, res := range results {
+		if res.Error != nil {
+			logger.Error("Error in repository %s: %v", res.Path, res.Error)
+			continue
+		}
+		if !NeedsAttention(res) && !cfg.ShowAll {
+			continue
+		}
+		rows = append(rows, tableRows(res, cfg)...)
+	}
+	if cfg.Sort == SortAge {
+		sortRowsByAge(rows)
+	}
+
+	header := make([]tableCell, len(columns))
+	for i, column := range columns {
+		header[i] = tableCell{text: strings.ToUpper(column)}
+	}
+	cells := [][]tableCell{header}
+	for _, row := range rows {
+		line := make([]tableCell, len(columns))
+		for i, column := range columns {
+			line[i] = tableValue(row, column)
+		}
+		cells = append(cells, line)
+	}
+
+	widths := make([]int, len(columns))
+	for _, line := range cells {
+		for i, cell := range line {
+			if w := utf8.RuneCountInString(cell.text); w > widths[i] {
+				widths[i] = w
+			}
+		}
+	}
+
+	for _, line := range cells {
+		fmt.Println(formatTableLine(line, columns, widths, noColor))
+	}
+}
+
+// tableRows returns the rows shown for res: the repository as a whole, or
+// its current branch followed by every other branch needing attention
+func tableRows(res types.RepoResult, cfg types.Config) []tableRow {
+	path := DisplayPath(res, cfg)
+
+	head := tableRow{res: res, path: path, name: res.Branch, head: true}
+	switch {
+	case res.HasDetachedHead:
+		head.name = "(detached)"
+	case res.Branch == "":
+		head.name = "(no branch)"
+	}
+	for i := range res.Branches {
+		if res.Branches[i].Current {
+			head.branch = &res.Branches[i]
+		}
+	}
+
+	if !cfg.PerBranch {
+		return []tableRow{head}
+	}
+
+	rows := []tableRow{head}
+	for i := range res.Branches {
+		if !res.Branches[i].Current {
+			b := &res.Branches[i]
+			rows = append(rows, tableRow{res: res, path: path, branch: b, name: b.Name})
+		}
+	}
+	return rows
+}
+
+// tableValue returns the cell of row in column
+func tableValue(row tableRow, column string) tableCell {
+	res := row.res
+	switch column {
+	case ColumnPath:
+		return tableCell{text: row.path}
+	case ColumnBranch:
+		return tableCell{text: row.name}
+	case ColumnAhead, ColumnBehind:
+		if row.branch == nil && res.Branch == "" {
+			return tableCell{text: "-"}
+		}
+		if row.branch != nil && (row.branch.NoUpstream || row.branch.Gone) {
+			return tableCell{text: "-"}
+		}
+		n, color := 0, ColorGreen
+		if row.branch != nil && column == ColumnAhead {
+			n = row.branch.Ahead
+		}
+		if row.branch != nil && column == ColumnBehind {
+			n, color = row.branch.Behind, ColorRed
+		}
+		return countCell(n, color)
+	case ColumnUpstream:
+		return upstreamCell(row)
+	case ColumnPush:
+		return pushCell(row)
+	case ColumnModified:
+		return headCountCell(row, res.Uncommitted.Modified)
+	case ColumnStaged:
+		return headCountCell(row, res.Uncommitted.Staged)
+	case ColumnUntracked:
+		return headCountCell(row, res.Uncommitted.Untracked)
+	case ColumnStashes:
+		return headCountCell(row, res.Stash.Count)
+	case ColumnTags:
+		if !row.head {
+			return tableCell{}
+		}
+		return countCell(len(res.UnpushedTags), ColorGreen)
+	case ColumnAge:
+		lastCommit := rowLastCommit(row)
+		if lastCommit.IsZero() {
+			return tableCell{text: "-"}
+		}
+		return tableCell{text: FormatShortAge(lastCommit, time.Now())}
+	}
+	return tableCell{}
+}
+
+// rowLastCommit returns the commit time shown in the age column of row
+func rowLastCommit(row tableRow) time.Time {
+	if !row.head && row.branch != nil {
+		return row.branch.LastCommit
+	}
+	return row.res.LastCommit
+}
+
+// sortRowsByAge orders rows from the oldest to the most recent commit,
+// followed by the rows without commits
+func sortRowsByAge(rows []tableRow) {
+	sort.SliceStable(rows, func(i, j int) bool {
+		ti, tj := rowLastCommit(rows[i]), rowLastCommit(rows[j])
+		if ti.IsZero() || tj.IsZero() {
+			return !ti.IsZero() && tj.IsZero()
+		}
+		return ti.Before(tj)
+	})
+}
+
+// countCell shows n, colored when it is non-zero
+func countCell(n int, color string) tableCell {
+	if n == 0 {
+		return tableCell{text: "0"}
+	}
+	return tableCell{text: strconv.Itoa(n), color: color}
+}
+
+// headCountCell shows a working directory count on the row describing HEAD
+func headCountCell(row tableRow, n int) tableCell {
+	if !row.head {
+		return tableCell{}
+	}
+	return countCell(n, ColorYellow)
+}
+
+// upstreamCell summarizes how a branch relates to its upstream
+func upstreamCell(row tableRow) tableCell {
+	b := row.branch
+	switch {
+	case b == nil && row.res.HasDetachedHead:
+		return tableCell{text: "detached", color: ColorBlue}
+	case b == nil && row.res.Branch == "":
+		return tableCell{text: "-"}
+	case b == nil:
+		return tableCell{text: "ok"}
+	case b.NoUpstream:
+		return tableCell{text: "none", color: ColorCyan}
+	case b.Gone:
+		return tableCell{text: "gone", color: ColorMagenta}
+	}
+	return syncCell(b.Ahead, b.Behind)
+}
+
+// pushCell summarizes how a branch relates to a push destination other than
+// its upstream, "-" when it pushes to its upstream or is not listed
+func pushCell(row tableRow) tableCell {
+	b := row.branch
+	switch {
+	case b == nil || b.Push == "":
+		return tableCell{text: "-"}
+	case b.PushGone:
+		return tableCell{text: "not pushed", color: ColorGreen}
+	}
+	return syncCell(b.PushAhead, b.PushBehind)
+}
+
+// syncCell names the state of a branch ahead and behind by the given counts
+func syncCell(ahead, behind int) tableCell {
+	switch {
+	case ahead > 0 && behind > 0:
+		return tableCell{text: "diverged", color: ColorYellow}
+	case ahead > 0:
+		return tableCell{text: "ahead", color: ColorGreen}
+	case behind > 0:
+		return tableCell{text: "behind", color: ColorRed}
+	}
+	return tableCell{text: "ok"}
+}
+
+// formatTableLine pads every cell to its column width. Padding is added
+// outside the color codes so escape sequences do not affect alignment, and
+// the last column is not padded to avoid trailing spaces.
+func formatTableLine(line []tableCell, columns []string, widths []int, noColor bool) string {
+	var b strings.Builder
+	for i, cell := range line {
+		if i > 0 {
+			b.WriteString(columnSeparator)
+		}
+		padding := strings.Repeat(" ", widths[i]-utf8.RuneCountInString(cell.text))
+		text := colorize(cell.text, cell.color, noColor)
+		switch {
+		case rightAligned[columns[i]]:
+			b.WriteString(padding + text)
+		case i == len(line)-1:
+			b.WriteString(text)
+		default:
+			b.WriteString(text + padding)
+		}
+	}
+	return strings.TrimRight(b.String(), " ")
+}
+
+// FormatShortAge describes how long before now t was in a compact form, e.g. "3d"
+func FormatShortAge(t time.Time, now time.Time) string {
+	d := now.Sub(t)
+	switch {
+	case d < time.Minute:
+		return "now"
+	case d < time.Hour:
+		return fmt.Sprintf("%dm", int(d/time.Minute))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%dh", int(d/time.Hour))
+	default:
+		return fmt.Sprintf("%dd", int(d/(24*time.Hour)))
+	}
+}
+
+// IsTerminal reports whether f is a character device such as a terminal
+func IsTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
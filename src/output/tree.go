@@ -0,0 +1,246 @@
+package output
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gitstatus/src/logger"
+	"gitstatus/src/types"
+)
+
+// Box-drawing connectors of the tree format
+const (
+	treeBranch = "├── "
+	treeLast   = "└── "
+	treePipe   = "│   "
+	treeBlank  = "    "
+)
+
+// treeNode is a directory on the way to a repository, or a repository
+type treeNode struct {
+	name     string
+	repo     *types.RepoResult
+	children map[string]*treeNode
+}
+
+func newTreeNode(name string) *treeNode {
+	return &treeNode{name: name, children: make(map[string]*treeNode)}
+}
+
+// sortedChildren returns the children of n ordered by name
+func (n *treeNode) sortedChildren() []*treeNode {
+	children := make([]*treeNode, 0, len(n.children))
+	for _, child := range n.children {
+		children = append(children, child)
+	}
+	sort.Slice(children, func(i, j int) bool {
+		return children[i].name < children[j].name
+	})
+	return children
+}
+
+// compact merges directories that hold nothing but a single subdirectory
+// into it, e.g. "nested" and "level1" become "nested/level1"
+func (n *treeNode) compact() {
+	for key, child := range n.children {
+		for child.repo == nil && len(child.children) == 1 {
+			var only *treeNode
+			for _, c := range child.children {
+				only = c
+			}
+			only.name = child.name + "/" + only.name
+			child = only
+		}
+		child.compact()
+		n.children[key] = child
+	}
+}
+
+// printTree prints the repositories of each scan root as a directory tree,
+// listing branches and working directory state under each repository
+func printTree(results []types.RepoResult, cfg types.Config, logger *logger.Logger) {
+	if !anyNeedsAttention(results) && !cfg.ShowAll {
+		fmt.Println(NoIssuesMessage)
+		return
+	}
+
+	roots := buildTrees(results, cfg, logger)
+	for i, root := range roots {
+		if i > 0 {
+			fmt.Println()
+		}
+		fmt.Println(treeNodeLabel(root, cfg))
+		printTreeChildren(root, "", cfg)
+	}
+}
+
+// buildTrees arranges the repositories to show under one tree per scan root,
+// in the order the roots were given
+func buildTrees(results []types.RepoResult, cfg types.Config, logger *logger.Logger) []*treeNode {
+	trees := make(map[string]*treeNode)
+	var order []string
+
+	for i := range results {
+		res := &results[i]
+		if res.Error != nil {
+			logger.Error("Error in repository %s: %v", res.Path, res.Error)
+			continue
+		}
+		if !NeedsAttention(*res) && !cfg.ShowAll {
+			continue
+		}
+
+		root := res.Root
+		if root == "" {
+			root = filepath.Dir(res.Path)
+		}
+		rel, err := filepath.Rel(root, res.Path)
+		if err != nil {
+			root, rel = filepath.Dir(res.Path), filepath.Base(res.Path)
+		}
+
+		tree, ok := trees[root]
+		if !ok {
+			tree = newTreeNode(root)
+			trees[root] = tree
+			order = append(order, root)
+		}
+
+		node := tree
+		if rel != "." {
+			for _, part := range strings.Split(rel, string(filepath.Separator)) {
+				child, ok := node.children[part]
+				if !ok {
+					child = newTreeNode(part)
+					node.children[part] = child
+				}
+				node = child
+			}
+		}
+		node.repo = res
+	}
+
+	rootIndex := make(map[string]int)
+	for i, root := range cfg.RootPaths {
+		if _, ok := rootIndex[root]; !ok {
+			rootIndex[root] = i
+		}
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		ri, iok := rootIndex[order[i]]
+		rj, jok := rootIndex[order[j]]
+		if iok != jok {
+			return iok
+		}
+		if iok {
+			return ri < rj
+		}
+		return order[i] < order[j]
+	})
+
+	roots := make([]*treeNode, 0, len(order))
+	for _, root := range order {
+		trees[root].compact()
+		roots = append(roots, trees[root])
+	}
+	return roots
+}
+
+// printTreeChildren prints the status lines of n's repository followed by
+// its subdirectories, each line prefixed with the connectors of its parents
+func printTreeChildren(n *treeNode, prefix string, cfg types.Config) {
+	var lines []statusLine
+	if n.repo != nil && NeedsAttention(*n.repo) {
+		lines = repoStatusLines(*n.repo, cfg.NoColor)
+	}
+	children := n.sortedChildren()
+
+	total := len(lines) + len(children)
+	for i, line := range lines {
+		last := i == total-1
+		fmt.Println(prefix + treeConnector(last) + line.text)
+
+		subPrefix := prefix + treePipe
+		if last {
+			subPrefix = prefix + treeBlank
+		}
+		for j, sub := range line.sub {
+			fmt.Println(subPrefix + treeConnector(j == len(line.sub)-1) + sub)
+		}
+	}
+	for i, child := range children {
+		last := len(lines)+i == total-1
+		fmt.Println(prefix + treeConnector(last) + treeNodeLabel(child, cfg))
+
+		childPrefix := prefix + treePipe
+		if last {
+			childPrefix = prefix + treeBlank
+		}
+		printTreeChildren(child, childPrefix, cfg)
+	}
+}
+
+func treeConnector(last bool) string {
+	if last {
+		return treeLast
+	}
+	return treeBranch
+}
+
+// treeNodeLabel returns the line naming a directory or repository
+func treeNodeLabel(n *treeNode, cfg types.Config) string {
+	if n.repo == nil {
+		return n.name + "/"
+	}
+	label := n.name + formatRepoLabel(*n.repo)
+	if !NeedsAttention(*n.repo) {
+		return formatCleanRepoLine(label, cfg.NoColor)
+	}
+	return label
+}
+
+// statusLine is a status line of a repository in the tree format and the
+// lines nested under it, such as the commits of a branch
+type statusLine struct {
+	text string
+	sub  []string
+}
+
+// RepoStatusLines returns the status lines listed under a repository in the
+// tree format, without the repository path and the lines nested under them
+func RepoStatusLines(res types.RepoResult, noColor bool) []string {
+	var lines []string
+	for _, line := range repoStatusLines(res, noColor) {
+		lines = append(lines, line.text)
+	}
+	return lines
+}
+
+// repoStatusLines returns the status lines listed under a repository in the
+// tree format
+func repoStatusLines(res types.RepoResult, noColor bool) []statusLine {
+	var lines []statusLine
+
+	if res.HasOperation {
+		lines = append(lines, statusLine{text: colorize("["+OperationLabel(res.Operation)+"]", ColorBoldYellow, noColor)})
+	}
+	if res.FetchError != nil {
+		lines = append(lines, statusLine{text: colorize(fmt.Sprintf("fetch failed: %v", res.FetchError), ColorBoldRed, noColor)})
+	}
+	if res.HasDetachedHead {
+		lines = append(lines, statusLine{text: colorize("HEAD ("+detachedDetails(res.DetachedHead)+")", ColorBlue, noColor)})
+	}
+	for _, b := range res.Branches {
+		lines = append(lines, statusLine{text: colorize(b.Name+branchDetails(b), branchColor(b), noColor), sub: commitLines(b, noColor)})
+	}
+	if res.HasUncommitted || res.HasStash {
+		lines = append(lines, statusLine{text: colorize("working tree ("+workdirDetails(res.Uncommitted, res.Stash, res.LastModified)+")", ColorYellow, noColor)})
+	}
+	if res.HasUnpushedTags {
+		lines = append(lines, statusLine{text: colorize(tagsDetails(res.UnpushedTags, res.TagsPartial), ColorGreen, noColor)})
+	}
+
+	return lines
+}
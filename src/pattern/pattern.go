@@ -0,0 +1,281 @@
+package pattern
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"regexp/syntax"
+	"strings"
+)
+
+// Pattern is a single gitignore-style rule matched against slash-separated
+// paths relative to the scan root.
+//
+//   - "*" and "?" match within one path segment, "**" matches across segments
+//   - "[...]" matches one character of a set, which may hold ranges such as
+//     "a-z" and POSIX classes such as "[:alpha:]", and is negated by a
+//     leading "!" or "^"
+//   - a leading "/" or a "/" in the middle anchors the pattern to the root,
+//     otherwise it matches at any depth
+//   - a trailing "/" is accepted and ignored, only directories are matched
+//   - a leading "!" negates the pattern
+type Pattern struct {
+	Text   string // the pattern as written, for logging
+	Negate bool
+	re     *regexp.Regexp
+}
+
+// List is an ordered set of patterns where the last matching pattern wins
+type List []Pattern
+
+// Parse compiles a single pattern
+func Parse(text string) (Pattern, error) {
+	p := Pattern{Text: text}
+
+	s := strings.TrimSpace(text)
+	if strings.HasPrefix(s, "!") {
+		p.Negate = true
+		s = s[1:]
+	}
+	s = strings.TrimSuffix(s, "/")
+	if s == "" {
+		return Pattern{}, fmt.Errorf("invalid pattern %q: empty", text)
+	}
+
+	anchored := strings.Contains(s, "/")
+	s = strings.TrimPrefix(s, "/")
+
+	var b strings.Builder
+	b.WriteString("^")
+	if !anchored {
+		b.WriteString("(?:.*/)?")
+	}
+	if err := translate(&b, s); err != nil {
+		return Pattern{}, fmt.Errorf("invalid pattern %q: %w", text, err)
+	}
+	b.WriteString("$")
+
+	re, err := regexp.Compile(b.String())
+	if err != nil {
+		return Pattern{}, fmt.Errorf("invalid pattern %q: %w", text, err)
+	}
+	p.re = re
+	return p, nil
+}
+
+// translate appends the regular expression equivalent of glob s to b
+func translate(b *strings.Builder, s string) error {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case strings.HasPrefix(s[i:], "**/"):
+			b.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(s[i:], "**"):
+			b.WriteString(".*")
+			i++
+		case c == '*':
+			b.WriteString("[^/]*")
+		case c == '?':
+			b.WriteString("[^/]")
+		case c == '[':
+			end, err := translateClass(b, s, i)
+			if err != nil {
+				return err
+			}
+			i = end
+		case c == '\\' && i+1 < len(s):
+			i++
+			b.WriteString(regexp.QuoteMeta(string(s[i])))
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return nil
+}
+
+// posixClasses are the names git accepts in a "[:name:]" class
+var posixClasses = map[string]bool{
+	"alnum": true, "alpha": true, "blank": true, "cntrl": true,
+	"digit": true, "graph": true, "lower": true, "print": true,
+	"punct": true, "space": true, "upper": true, "xdigit": true,
+}
+
+// translateClass appends the regular expression equivalent of the bracket
+// expression starting at s[start] to b and returns the index of its closing
+// "]". Like in git, a "]" right after the opening bracket is a literal and no
+// character class matches "/", not even one listing it or a range or POSIX
+// class spanning it.
+func translateClass(b *strings.Builder, s string, start int) (int, error) {
+	i := start + 1
+	negate := i < len(s) && (s[i] == '!' || s[i] == '^')
+	if negate {
+		i++
+	}
+
+	var class strings.Builder
+	for first := true; ; first = false {
+		if i >= len(s) {
+			return 0, fmt.Errorf("unterminated character class")
+		}
+		c := s[i]
+		switch {
+		case c == ']' && !first:
+			if negate {
+				b.WriteString("[^/" + class.String() + "]")
+				return i, nil
+			}
+			set, err := withoutSlash(class.String())
+			if err != nil {
+				return 0, err
+			}
+			b.WriteString(set)
+			return i, nil
+		case strings.HasPrefix(s[i:], "[:"):
+			end := strings.Index(s[i+2:], ":]")
+			if end < 0 {
+				return 0, fmt.Errorf("unterminated character class %q", s[i:])
+			}
+			name := s[i+2 : i+2+end]
+			if !posixClasses[name] {
+				return 0, fmt.Errorf("unknown character class [:%s:]", name)
+			}
+			class.WriteString("[:" + name + ":]")
+			i += end + 4
+			continue
+		case c == '\\' && i+1 < len(s):
+			i++
+			if c = s[i]; !isAlnum(c) {
+				class.WriteString(`\` + string(c))
+			} else {
+				class.WriteByte(c)
+			}
+		case c == '\\' || c == '[' || c == ']' || c == '^':
+			class.WriteString(`\` + string(c))
+		default:
+			class.WriteByte(c)
+		}
+		i++
+	}
+}
+
+// withoutSlash returns the character class with the given contents minus "/".
+// RE2 has no class intersection, so the class is expanded into its ranges and
+// any range holding "/" is split around it.
+func withoutSlash(class string) (string, error) {
+	re, err := syntax.Parse("["+class+"]", syntax.Perl)
+	if err != nil {
+		return "", fmt.Errorf("invalid character class [%s]: %w", class, err)
+	}
+	var ranges []rune
+	switch re.Op {
+	case syntax.OpLiteral:
+		ranges = []rune{re.Rune[0], re.Rune[0]}
+	case syntax.OpCharClass:
+		ranges = re.Rune
+	default:
+		return "", fmt.Errorf("invalid character class [%s]", class)
+	}
+
+	var set strings.Builder
+	for j := 0; j < len(ranges); j += 2 {
+		lo, hi := ranges[j], ranges[j+1]
+		if lo <= '/' && '/' <= hi {
+			writeRange(&set, lo, '/'-1)
+			writeRange(&set, '/'+1, hi)
+			continue
+		}
+		writeRange(&set, lo, hi)
+	}
+	if set.Len() == 0 {
+		// Only "/" was listed, which nothing may match
+		return `[^\x00-\x{10FFFF}]`, nil
+	}
+	return "[" + set.String() + "]", nil
+}
+
+// writeRange appends the range lo-hi to a character class, if it is not empty
+func writeRange(b *strings.Builder, lo, hi rune) {
+	if lo > hi {
+		return
+	}
+	fmt.Fprintf(b, `\x{%x}`, lo)
+	if hi > lo {
+		fmt.Fprintf(b, `-\x{%x}`, hi)
+	}
+}
+
+func isAlnum(c byte) bool {
+	return c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80
+}
+
+// Match reports whether the pattern matches rel, ignoring negation
+func (p Pattern) Match(rel string) bool {
+	return p.re.MatchString(filepath.ToSlash(rel))
+}
+
+// ParseList compiles every pattern in texts, skipping blank lines and # comments
+func ParseList(texts []string) (List, error) {
+	var list List
+	for _, text := range texts {
+		trimmed := strings.TrimSpace(text)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		p, err := Parse(trimmed)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, p)
+	}
+	return list, nil
+}
+
+// Match returns whether rel is selected by the list together with the pattern
+// that decided it. A path matched by a negated pattern is not selected.
+func (l List) Match(rel string) (bool, *Pattern) {
+	for i := len(l) - 1; i >= 0; i-- {
+		if l[i].Match(rel) {
+			return !l[i].Negate, &l[i]
+		}
+	}
+	return false, nil
+}
+
+// MatchOrParent is like Match, but also selects rel when one of its parent
+// directories is selected.
+func (l List) MatchOrParent(rel string) (bool, *Pattern) {
+	rel = filepath.ToSlash(rel)
+	for {
+		if matched, p := l.Match(rel); p != nil {
+			return matched, p
+		}
+		slash := strings.LastIndexByte(rel, '/')
+		if slash < 0 {
+			return false, nil
+		}
+		rel = rel[:slash]
+	}
+}
+
+// ReadFile returns the lines of a gitignore-style pattern file. A missing
+// file yields no lines.
+func ReadFile(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	return lines, scanner.Err()
+}
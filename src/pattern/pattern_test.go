@@ -0,0 +1,144 @@
+package pattern
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPatternMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		want    bool
+	}{
+		{"archive", "archive", true},
+		{"archive", "src/archive", true},
+		{"archive", "src/archive2", false},
+		{"archive/", "src/archive", true},
+		{"/archive", "archive", true},
+		{"/archive", "src/archive", false},
+		{"src/archive", "src/archive", true},
+		{"src/archive", "other/src/archive", false},
+		{"src/archive/**", "src/archive/old/repo", true},
+		{"src/archive/**", "src/archive", false},
+		{"**/mirror", "vendor/deep/mirror", true},
+		{"**/mirror", "mirror", true},
+		{"a/**/b", "a/b", true},
+		{"a/**/b", "a/x/y/b", true},
+		{"*/services/*", "team/services/api", true},
+		{"*/services/*", "team/services/api/nested", false},
+		{"*/services/*", "services/api", false},
+		{"repo-?", "repo-1", true},
+		{"repo-?", "repo-12", false},
+		{"repo-[0-9]", "repo-7", true},
+		{"repo-[!0-9]", "repo-7", false},
+		{"repo-[[:alpha:]]", "repo-a", true},
+		{"repo-[[:alpha:]]", "repo-7", false},
+		{"repo-[![:digit:]_]", "repo-x", true},
+		{"repo-[![:digit:]_]", "repo-_", false},
+		{"repo-[]x]", "repo-]", true},
+		{"repo-[a\\-z]", "repo--", true},
+		{"repo-[a\\-z]", "repo-b", false},
+		{"a[!x]b", "a/b", false},
+		{"a[/a]b", "a/b", false},
+		{"a[/a]b", "aab", true},
+		{"a[.-0]b", "a/b", false},
+		{"a[.-0]b", "a.b", true},
+		{"a[.-0]b", "a0b", true},
+		{"a[[:punct:]]b", "a/b", false},
+		{"a[[:punct:]]b", "a-b", true},
+		{"a[/]b", "a/b", false},
+		{"*.bak", "x/y.bak", true},
+		{"*.bak", "x.bak/y", false},
+		{"foo\\*", "foo*", true},
+		{"foo\\*", "foobar", false},
+	}
+
+	for _, tt := range tests {
+		p, err := Parse(tt.pattern)
+		if err != nil {
+			t.Fatalf("Parse(%q) failed: %v", tt.pattern, err)
+		}
+		if got := p.Match(tt.path); got != tt.want {
+			t.Errorf("%q.Match(%q) = %v, want %v", tt.pattern, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, text := range []string{"", "!", "/", "repo-[0-9", "repo-[[:alpha:]", "repo-[[:word:]]"} {
+		if _, err := Parse(text); err == nil {
+			t.Errorf("Parse(%q) should fail", text)
+		}
+	}
+}
+
+func TestListLastMatchWins(t *testing.T) {
+	list, err := ParseList([]string{"# comment", "", "archive/**", "!archive/keep", "archive/keep/tmp"})
+	if err != nil {
+		t.Fatalf("ParseList failed: %v", err)
+	}
+	if len(list) != 3 {
+		t.Fatalf("Expected comments and blank lines to be skipped, got %d patterns", len(list))
+	}
+
+	tests := []struct {
+		path     string
+		want     bool
+		wantRule string
+	}{
+		{"archive/old", true, "archive/**"},
+		{"archive/keep", false, "!archive/keep"},
+		{"archive/keep/tmp", true, "archive/keep/tmp"},
+		{"src", false, ""},
+	}
+
+	for _, tt := range tests {
+		got, rule := list.Match(tt.path)
+		if got != tt.want {
+			t.Errorf("Match(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+		ruleText := ""
+		if rule != nil {
+			ruleText = rule.Text
+		}
+		if ruleText != tt.wantRule {
+			t.Errorf("Match(%q) rule = %q, want %q", tt.path, ruleText, tt.wantRule)
+		}
+	}
+}
+
+func TestListMatchOrParent(t *testing.T) {
+	list, err := ParseList([]string{"work/*"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, _ := list.MatchOrParent("work/team/repo"); !got {
+		t.Error("Expected repo below a matching directory to be selected")
+	}
+	if got, _ := list.MatchOrParent("oss/repo"); got {
+		t.Error("Expected unrelated repo not to be selected")
+	}
+}
+
+func TestReadFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".gitstatusignore")
+	if err := os.WriteFile(path, []byte("archive/**\n!archive/keep\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	lines, err := ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	if len(lines) != 2 || lines[0] != "archive/**" || lines[1] != "!archive/keep" {
+		t.Errorf("Unexpected lines: %q", lines)
+	}
+
+	lines, err = ReadFile(filepath.Join(t.TempDir(), "missing"))
+	if err != nil || lines != nil {
+		t.Errorf("Missing file: got %q, %v", lines, err)
+	}
+}
@@ -0,0 +1,91 @@
+package tui
+
+import "unicode/utf8"
+
+// key is a decoded keystroke: a printable rune or one of the special keys below
+type key rune
+
+const (
+	keyUp key = -(iota + 1)
+	keyDown
+	keyLeft
+	keyRight
+	keyHome
+	keyEnd
+	keyPageUp
+	keyPageDown
+	keyEnter
+	keyEscape
+	keyCtrlC
+	keyUnknown
+)
+
+// escapeSequences maps the sequences terminals send for special keys, in
+// both the normal and the application cursor mode
+var escapeSequences = map[string]key{
+	"\x1b[A":  keyUp,
+	"\x1b[B":  keyDown,
+	"\x1b[C":  keyRight,
+	"\x1b[D":  keyLeft,
+	"\x1bOA":  keyUp,
+	"\x1bOB":  keyDown,
+	"\x1bOC":  keyRight,
+	"\x1bOD":  keyLeft,
+	"\x1b[H":  keyHome,
+	"\x1b[F":  keyEnd,
+	"\x1bOH":  keyHome,
+	"\x1bOF":  keyEnd,
+	"\x1b[1~": keyHome,
+	"\x1b[4~": keyEnd,
+	"\x1b[5~": keyPageUp,
+	"\x1b[6~": keyPageDown,
+}
+
+// decodeKeys splits input read from the terminal into keys. An escape
+// sequence arrives in a single read, so a lone ESC is the Escape key.
+func decodeKeys(input []byte) []key {
+	var keys []key
+	for len(input) > 0 {
+		switch c := input[0]; {
+		case c == 0x1b:
+			n := escapeLength(input)
+			if n == 1 {
+				keys = append(keys, keyEscape)
+			} else if k, ok := escapeSequences[string(input[:n])]; ok {
+				keys = append(keys, k)
+			} else {
+				keys = append(keys, keyUnknown)
+			}
+			input = input[n:]
+		case c == '\r' || c == '\n':
+			keys = append(keys, keyEnter)
+			input = input[1:]
+		case c == 0x03:
+			keys = append(keys, keyCtrlC)
+			input = input[1:]
+		case c < 0x20 || c == 0x7f:
+			keys = append(keys, keyUnknown)
+			input = input[1:]
+		default:
+			r, size := utf8.DecodeRune(input)
+			keys = append(keys, key(r))
+			input = input[size:]
+		}
+	}
+	return keys
+}
+
+// escapeLength returns the length of the escape sequence input starts with:
+// ESC followed by "[" or "O", parameters and a final letter or "~"
+func escapeLength(input []byte) int {
+	if len(input) < 2 || (input[1] != '[' && input[1] != 'O') {
+		return 1
+	}
+	for i := 2; i < len(input); i++ {
+		c := input[i]
+		if (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || c == '~' {
+			return i + 1
+		}
+	}
+	return len(input)
+}
@@ -0,0 +1,414 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"gitstatus/src/output"
+	"gitstatus/src/types"
+)
+
+// commitLimit caps the commits listed per branch in each direction
+const commitLimit = 10
+
+// pageSize is how many repositories PageUp and PageDown move
+const pageSize = 10
+
+// command is an action the session runs in response to a key
+type command int
+
+const (
+	cmdNone command = iota
+	cmdQuit
+	cmdLoadDetails
+	cmdRefresh
+	cmdFetch
+	cmdPull
+	cmdPush
+	cmdShell
+)
+
+// helpLine lists the keys, shown at the bottom of the screen
+const helpLine = "↑↓ move  enter expand  f fetch  p pull  P push  s shell  r refresh  q quit"
+
+// repoItem is a repository in the list
+type repoItem struct {
+	res      types.RepoResult
+	expanded bool
+	details  *repoDetails // loaded when the repository is expanded, nil until then
+}
+
+// repoDetails holds what is shown under an expanded repository besides its status
+type repoDetails struct {
+	commits []branchCommits
+	files   []types.FileChange
+	err     error
+}
+
+// branchCommits are the commits a branch is ahead and behind of its upstream
+type branchCommits struct {
+	branch types.BranchSyncStatus
+	ahead  []types.Commit
+	behind []types.Commit
+}
+
+// model is the state of the interface, independent of the terminal
+type model struct {
+	cfg     types.Config
+	items   []*repoItem
+	cursor  int    // selected item
+	offset  int    // first body line shown
+	status  string // message about the last action
+	failed  bool   // the last action failed
+	noColor bool
+}
+
+// newModel lists the repositories the text output would show: those needing
+// attention or failing to scan, and every repository with cfg.ShowAll
+func newModel(results []types.RepoResult, cfg types.Config) *model {
+	m := &model{cfg: cfg, noColor: cfg.NoColor}
+	for _, res := range results {
+		if res.Error != nil || output.NeedsAttention(res) || cfg.ShowAll {
+			m.items = append(m.items, &repoItem{res: res})
+		}
+	}
+	return m
+}
+
+// selected returns the selected repository, or nil when the list is empty
+func (m *model) selected() *repoItem {
+	if len(m.items) == 0 {
+		return nil
+	}
+	return m.items[m.cursor]
+}
+
+// setStatus shows msg in the status line
+func (m *model) setStatus(msg string, failed bool) {
+	m.status, m.failed = msg, failed
+}
+
+// handleKey updates the selection for navigation keys and returns the
+// command to run for the others
+func (m *model) handleKey(k key) command {
+	switch k {
+	case 'q', keyCtrlC, keyEscape:
+		return cmdQuit
+	case 'j', keyDown:
+		m.move(1)
+	case 'k', keyUp:
+		m.move(-1)
+	case keyPageDown:
+		m.move(pageSize)
+	case keyPageUp:
+		m.move(-pageSize)
+	case 'g', keyHome:
+		m.move(-len(m.items))
+	case 'G', keyEnd:
+		m.move(len(m.items))
+	case keyEnter, ' ':
+		return m.setExpanded(!m.isExpanded())
+	case 'l', keyRight:
+		return m.setExpanded(true)
+	case 'h', keyLeft:
+		return m.setExpanded(false)
+	case 'r':
+		return m.itemCommand(cmdRefresh)
+	case 'f':
+		return m.itemCommand(cmdFetch)
+	case 'p':
+		return m.itemCommand(cmdPull)
+	case 'P':
+		return m.itemCommand(cmdPush)
+	case 's':
+		return m.itemCommand(cmdShell)
+	}
+	return cmdNone
+}
+
+func (m *model) move(delta int) {
+	m.cursor += delta
+	if m.cursor >= len(m.items) {
+		m.cursor = len(m.items) - 1
+	}
+	if m.cursor < 0 {
+		m.cursor = 0
+	}
+}
+
+func (m *model) isExpanded() bool {
+	item := m.selected()
+	return item != nil && item.expanded
+}
+
+// setExpanded expands or collapses the selected repository and asks for its
+// details when they have not been loaded yet
+func (m *model) setExpanded(expanded bool) command {
+	item := m.selected()
+	if item == nil {
+		return cmdNone
+	}
+	item.expanded = expanded
+	if expanded && item.details == nil {
+		return cmdLoadDetails
+	}
+	return cmdNone
+}
+
+// itemCommand returns cmd when a repository is selected
+func (m *model) itemCommand(cmd command) command {
+	if m.selected() == nil {
+		return cmdNone
+	}
+	return cmd
+}
+
+// view renders the screen as width x height lines: a title, the repository
+// list scrolled to keep the selection visible, the status and the key help
+func (m *model) view(width, height int) []string {
+	title := "gitstatus: " + output.Pluralize(len(m.items), "repository")
+	if !m.cfg.ShowAll {
+		title += " needing attention"
+	}
+	lines := []string{m.style(truncate(title, width), "\033[1m")}
+
+	bodyHeight := height - 3
+	if bodyHeight < 1 {
+		bodyHeight = 1
+	}
+
+	body, first, last := m.body(width)
+	m.scroll(first, last, len(body), bodyHeight)
+	for i := m.offset; i < m.offset+bodyHeight; i++ {
+		if i < len(body) {
+			lines = append(lines, body[i])
+		} else {
+			lines = append(lines, "")
+		}
+	}
+
+	status := truncate(m.status, width)
+	if m.failed {
+		status = m.style(status, output.ColorRed)
+	}
+	return append(lines, status, m.style(truncate(helpLine, width), "\033[2m"))
+}
+
+// scroll moves offset so the selected repository's lines first..last fit,
+// preferring its header line when the whole block does not
+func (m *model) scroll(first, last, total, height int) {
+	if last-m.offset >= height {
+		m.offset = last - height + 1
+	}
+	if first < m.offset || last-first >= height {
+		m.offset = first
+	}
+	if maxOffset := total - height; m.offset > maxOffset {
+		m.offset = maxOffset
+	}
+	if m.offset < 0 {
+		m.offset = 0
+	}
+}
+
+// body returns the lines of the repository list and the range of lines
+// taken by the selected repository
+func (m *model) body(width int) ([]string, int, int) {
+	if len(m.items) == 0 {
+		return []string{output.NoIssuesMessage}, 0, 0
+	}
+
+	var lines []string
+	first, last := 0, 0
+	for i, item := range m.items {
+		if i == m.cursor {
+			first = len(lines)
+		}
+
+		marker := "▸"
+		if item.expanded {
+			marker = "▾"
+		}
+		header := fmt.Sprintf("%s %s  %s", marker, output.DisplayPath(item.res, m.cfg), repoSummary(item.res))
+		if i == m.cursor {
+			lines = append(lines, m.style(truncate("> "+header, width), "\033[7m"))
+		} else {
+			lines = append(lines, truncate("  "+header, width))
+		}
+
+		if item.expanded {
+			for _, line := range m.detailLines(item) {
+				lines = append(lines, truncate("      "+line, width))
+			}
+		}
+
+		if i == m.cursor {
+			last = len(lines) - 1
+		}
+	}
+	return lines, first, last
+}
+
+// repoSummary describes a repository on one line, e.g. "main ↑2 ↓1  +1 branch  dirty"
+func repoSummary(res types.RepoResult) string {
+	if res.Error != nil {
+		return fmt.Sprintf("error: %v", res.Error)
+	}
+
+	var parts []string
+	if res.HasOperation {
+		parts = append(parts, "["+output.OperationLabel(res.Operation)+"]")
+	}
+
+	head := res.Branch
+	switch {
+	case res.HasDetachedHead:
+		head = "HEAD detached"
+	case head == "":
+		head = "(no branch)"
+	}
+	others := 0
+	for _, b := range res.Branches {
+		if !b.Current {
+			others++
+			continue
+		}
+		switch {
+		case b.NoUpstream:
+			head += " no upstream"
+		case b.Gone:
+			head += " gone"
+		default:
+			if b.Ahead > 0 {
+				head += fmt.Sprintf(" ↑%d", b.Ahead)
+			}
+			if b.Behind > 0 {
+				head += fmt.Sprintf(" ↓%d", b.Behind)
+			}
+		}
+	}
+	parts = append(parts, head)
+
+	if others == 1 {
+		parts = append(parts, "+1 branch")
+	} else if others > 1 {
+		parts = append(parts, fmt.Sprintf("+%d branches", others))
+	}
+	if res.HasUncommitted {
+		parts = append(parts, "dirty")
+	}
+	if res.HasStash {
+		parts = append(parts, fmt.Sprintf("stash %d", res.Stash.Count))
+	}
+	if res.HasUnpushedTags {
+		parts = append(parts, fmt.Sprintf("tags %d", len(res.UnpushedTags)))
+	}
+	if res.FetchError != nil {
+		parts = append(parts, "fetch failed")
+	}
+	if !output.NeedsAttention(res) {
+		parts = append(parts, "clean")
+	}
+	return strings.Join(parts, "  ")
+}
+
+// detailLines returns the lines shown under an expanded repository: its
+// status as in the tree format, the commits of each unsynced branch and the
+// changed files
+func (m *model) detailLines(item *repoItem) []string {
+	res := item.res
+	if res.Error != nil {
+		return []string{fmt.Sprintf("error: %v", res.Error)}
+	}
+
+	lines := output.RepoStatusLines(res, m.noColor)
+	if len(lines) == 0 {
+		lines = append(lines, "clean")
+	}
+
+	d := item.details
+	if d == nil {
+		return append(lines, "loading...")
+	}
+	if d.err != nil {
+		lines = append(lines, m.style(fmt.Sprintf("failed to load details: %v", d.err), output.ColorRed))
+	}
+
+	now := time.Now()
+	for _, bc := range d.commits {
+		if len(bc.ahead) > 0 {
+			lines = append(lines, "", fmt.Sprintf("%s: %d not pushed", bc.branch.Name, bc.branch.Ahead))
+			lines = append(lines, m.commitLines("↑", bc.ahead, bc.branch.Ahead, output.ColorGreen, now)...)
+		}
+		if len(bc.behind) > 0 {
+			lines = append(lines, "", fmt.Sprintf("%s: %d not pulled", bc.branch.Name, bc.branch.Behind))
+			lines = append(lines, m.commitLines("↓", bc.behind, bc.branch.Behind, output.ColorRed, now)...)
+		}
+	}
+
+	if len(d.files) > 0 {
+		lines = append(lines, "", "changed files:")
+		for _, f := range d.files {
+			line := f.Status + " " + f.Path
+			if f.OrigPath != "" {
+				line += " <- " + f.OrigPath
+			}
+			lines = append(lines, "  "+m.style(line, output.ColorYellow))
+		}
+	}
+	return lines
+}
+
+// commitLines lists commits, noting how many of total were left out
+func (m *model) commitLines(arrow string, commits []types.Commit, total int, color string, now time.Time) []string {
+	var lines []string
+	for _, c := range commits {
+		line := fmt.Sprintf("%s %s %s (%s, %s)", arrow, m.style(c.Hash, color), c.Subject, c.Author, output.FormatShortAge(c.Time, now))
+		lines = append(lines, "  "+line)
+	}
+	if more := total - len(commits); more > 0 {
+		lines = append(lines, fmt.Sprintf("  ... %d more", more))
+	}
+	return lines
+}
+
+// style wraps text in an escape sequence unless colors are disabled
+func (m *model) style(text, code string) string {
+	if m.noColor || text == "" {
+		return text
+	}
+	return code + text + output.ColorReset
+}
+
+// truncate cuts line to width visible characters. Escape sequences are
+// copied without counting, and a reset is appended when colors were cut off.
+func truncate(line string, width int) string {
+	if width <= 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	visible, escaped := 0, false
+	for i := 0; i < len(line); {
+		if line[i] == 0x1b {
+			end := i + escapeLength([]byte(line[i:]))
+			b.WriteString(line[i:end])
+			escaped = true
+			i = end
+			continue
+		}
+		if visible == width {
+			if escaped {
+				b.WriteString(output.ColorReset)
+			}
+			return b.String()
+		}
+		r, size := utf8.DecodeRuneInString(line[i:])
+		b.WriteRune(r)
+		visible++
+		i += size
+	}
+	return b.String()
+}
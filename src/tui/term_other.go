@@ -0,0 +1,33 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd)
+
+package tui
+
+import "errors"
+
+var errUnsupported = errors.New("interactive mode is not supported on this platform")
+
+type terminal struct{}
+
+func isTerminal(fd int) bool {
+	return false
+}
+
+func openTerminal(fd int) (*terminal, error) {
+	return nil, errUnsupported
+}
+
+func (t *terminal) raw() error {
+	return errUnsupported
+}
+
+func (t *terminal) restore() error {
+	return errUnsupported
+}
+
+func (t *terminal) read(buf []byte) (int, error) {
+	return 0, errUnsupported
+}
+
+func (t *terminal) size() (int, int, error) {
+	return 0, 0, errUnsupported
+}
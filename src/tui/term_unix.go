@@ -0,0 +1,82 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd
+
+package tui
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// terminal is a tty switched to raw mode. Reads return after at most
+// readTimeout tenths of a second so the event loop can notice cancellation
+// and resizes without a reader goroutine holding on to stdin.
+type terminal struct {
+	fd    int
+	saved syscall.Termios
+}
+
+const readTimeout = 1
+
+func ioctl(fd int, request uintptr, arg unsafe.Pointer) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), request, uintptr(arg))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// isTerminal reports whether fd is a terminal
+func isTerminal(fd int) bool {
+	var t syscall.Termios
+	return ioctl(fd, ioctlGetTermios, unsafe.Pointer(&t)) == nil
+}
+
+// openTerminal switches fd to raw mode: no echo, no line buffering and no
+// signals for Ctrl-C. Output processing is kept so "\n" still starts a new line.
+func openTerminal(fd int) (*terminal, error) {
+	t := &terminal{fd: fd}
+	if err := ioctl(fd, ioctlGetTermios, unsafe.Pointer(&t.saved)); err != nil {
+		return nil, err
+	}
+	if err := t.raw(); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func (t *terminal) raw() error {
+	raw := t.saved
+	raw.Iflag &^= syscall.IGNBRK | syscall.BRKINT | syscall.PARMRK | syscall.ISTRIP |
+		syscall.INLCR | syscall.IGNCR | syscall.ICRNL | syscall.IXON
+	raw.Lflag &^= syscall.ECHO | syscall.ECHONL | syscall.ICANON | syscall.ISIG | syscall.IEXTEN
+	raw.Cflag &^= syscall.CSIZE | syscall.PARENB
+	raw.Cflag |= syscall.CS8
+	raw.Cc[syscall.VMIN] = 0
+	raw.Cc[syscall.VTIME] = readTimeout
+	return ioctl(t.fd, ioctlSetTermios, unsafe.Pointer(&raw))
+}
+
+// restore puts the terminal back in the mode it was opened in
+func (t *terminal) restore() error {
+	return ioctl(t.fd, ioctlSetTermios, unsafe.Pointer(&t.saved))
+}
+
+// read returns the bytes typed since the last call, or none after the timeout
+func (t *terminal) read(buf []byte) (int, error) {
+	n, err := syscall.Read(t.fd, buf)
+	if err == syscall.EINTR || err == syscall.EAGAIN {
+		return 0, nil
+	}
+	return n, err
+}
+
+// size returns the width and height of the terminal
+func (t *terminal) size() (int, int, error) {
+	var ws struct {
+		Row, Col, Xpixel, Ypixel uint16
+	}
+	if err := ioctl(t.fd, syscall.TIOCGWINSZ, unsafe.Pointer(&ws)); err != nil {
+		return 0, 0, err
+	}
+	return int(ws.Col), int(ws.Row), nil
+}
@@ -0,0 +1,270 @@
+// Package tui is the full-screen interface of gitstatus -i. It lists the
+// scanned repositories, shows the commits and files behind their status, and
+// fetches, pulls, pushes or opens a shell in the selected repository.
+package tui
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+
+	"gitstatus/src/git"
+	"gitstatus/src/logger"
+	"gitstatus/src/types"
+	"gitstatus/src/walker"
+)
+
+// Escape sequences controlling the screen
+const (
+	enterScreen = "\033[?1049h\033[?25l" // switch to the alternate screen, hide the cursor
+	leaveScreen = "\033[?25h\033[?1049l" // show the cursor, back to the normal screen
+	cursorHome  = "\033[H"
+	clearLine   = "\033[K"
+	clearBelow  = "\033[J"
+)
+
+// ErrNoTerminal is returned by Run when stdin or stdout is not a terminal
+var ErrNoTerminal = errors.New("interactive mode needs a terminal")
+
+// session connects a model to the terminal and runs its commands
+type session struct {
+	ctx    context.Context
+	cfg    types.Config
+	logger *logger.Logger
+	term   *terminal
+	out    *bufio.Writer
+	model  *model
+}
+
+// Run shows results until the user quits or ctx is cancelled. Log output to
+// stderr is discarded while the interface owns the screen.
+func Run(ctx context.Context, results []types.RepoResult, cfg types.Config, logger *logger.Logger) error {
+	fd := int(os.Stdin.Fd())
+	if !isTerminal(fd) || !isTerminal(int(os.Stdout.Fd())) {
+		return ErrNoTerminal
+	}
+
+	if logger.Output == io.Writer(os.Stderr) {
+		logger.Output = io.Discard
+		defer func() { logger.Output = os.Stderr }()
+	}
+
+	term, err := openTerminal(fd)
+	if err != nil {
+		return fmt.Errorf("configuring terminal: %w", err)
+	}
+	defer term.restore()
+
+	// Actions refresh a repository without fetching it again
+	cfg.Fetch = false
+
+	s := &session{
+		ctx:    ctx,
+		cfg:    cfg,
+		logger: logger,
+		term:   term,
+		out:    bufio.NewWriter(os.Stdout),
+		model:  newModel(results, cfg),
+	}
+
+	s.out.WriteString(enterScreen)
+	defer func() {
+		s.out.WriteString(leaveScreen)
+		s.out.Flush()
+	}()
+
+	return s.loop()
+}
+
+// loop redraws the screen and handles keys until the user quits
+func (s *session) loop() error {
+	buf := make([]byte, 256)
+	width, height := 0, 0
+	dirty := true
+
+	for s.ctx.Err() == nil {
+		w, h := s.size()
+		if dirty || w != width || h != height {
+			width, height = w, h
+			s.draw(width, height)
+			dirty = false
+		}
+
+		n, err := s.term.read(buf)
+		if err != nil {
+			return fmt.Errorf("reading terminal: %w", err)
+		}
+
+		for _, k := range decodeKeys(buf[:n]) {
+			cmd := s.model.handleKey(k)
+			if cmd == cmdQuit {
+				return nil
+			}
+			s.run(cmd)
+			dirty = true
+		}
+	}
+	return nil
+}
+
+// size returns the terminal size, or 80x24 when it cannot be read
+func (s *session) size() (int, int) {
+	w, h, err := s.term.size()
+	if err != nil || w <= 0 || h <= 0 {
+		return 80, 24
+	}
+	return w, h
+}
+
+func (s *session) draw(width, height int) {
+	s.out.WriteString(cursorHome)
+	for i, line := range s.model.view(width, height) {
+		if i > 0 {
+			s.out.WriteString("\n")
+		}
+		s.out.WriteString(line + clearLine)
+	}
+	s.out.WriteString(clearBelow)
+	s.out.Flush()
+}
+
+// progress shows msg while a slow command runs
+func (s *session) progress(msg string) {
+	s.model.setStatus(msg, false)
+	s.draw(s.size())
+}
+
+// run executes cmd on the selected repository
+func (s *session) run(cmd command) {
+	item := s.model.selected()
+	if item == nil {
+		return
+	}
+	path := item.res.Path
+
+	switch cmd {
+	case cmdLoadDetails:
+		s.progress("Loading " + path + "...")
+		s.loadDetails(item)
+		s.model.setStatus("", false)
+	case cmdRefresh:
+		s.progress("Refreshing " + path + "...")
+		s.refresh(item)
+		s.model.setStatus("Refreshed "+path, false)
+	case cmdFetch:
+		s.progress("Fetching " + path + "...")
+		err := git.FetchRepo(s.ctx, path, s.logger)
+		s.refresh(item)
+		item.res.FetchError = err
+		s.report("Fetched "+path, err)
+	case cmdPull:
+		s.progress("Pulling " + path + "...")
+		err := git.Pull(s.ctx, path, s.logger)
+		s.refresh(item)
+		s.report("Pulled "+path, err)
+	case cmdPush:
+		s.progress("Pushing " + path + "...")
+		err := git.Push(s.ctx, path, s.logger)
+		s.refresh(item)
+		s.report("Pushed "+path, err)
+	case cmdShell:
+		err := s.shell(path)
+		s.refresh(item)
+		s.report("Back from "+path, err)
+	}
+}
+
+// report shows done, or err when the command failed
+func (s *session) report(done string, err error) {
+	if err != nil {
+		s.model.setStatus(err.Error(), true)
+		return
+	}
+	s.model.setStatus(done, false)
+}
+
+// refresh analyzes the repository again, and reloads its details when they
+// were shown
+func (s *session) refresh(item *repoItem) {
+	res, ok := walker.AnalyzeRepo(s.ctx, s.cfg, item.res.Path, nil, s.logger)
+	if !ok {
+		return
+	}
+	res.Root = item.res.Root
+	item.res = res
+
+	item.details = nil
+	if item.expanded {
+		s.loadDetails(item)
+	}
+}
+
+// loadDetails lists the commits of every branch that is ahead or behind its
+// upstream, and the changed files when the working directory is dirty
+func (s *session) loadDetails(item *repoItem) {
+	d := &repoDetails{}
+	item.details = d
+	res := item.res
+	if res.Error != nil {
+		return
+	}
+
+	for _, b := range res.Branches {
+		if b.Gone || b.NoUpstream || (b.Ahead == 0 && b.Behind == 0) {
+			continue
+		}
+		ahead, behind, err := git.GetBranchCommits(s.ctx, res.Path, b.Name, commitLimit, s.logger)
+		if err != nil {
+			d.err = err
+			return
+		}
+		d.commits = append(d.commits, branchCommits{branch: b, ahead: ahead, behind: behind})
+	}
+
+	if res.HasUncommitted {
+		files, err := git.GetChangedFiles(s.ctx, res.Path, s.logger)
+		if err != nil {
+			d.err = err
+			return
+		}
+		d.files = files
+	}
+}
+
+// shell runs $SHELL in path on the normal screen, with the terminal back in
+// the mode it was in before gitstatus started
+func (s *session) shell(path string) error {
+	shell := os.Getenv("SHELL")
+	if shell == "" {
+		shell = "/bin/sh"
+	}
+
+	s.out.WriteString(leaveScreen)
+	s.out.Flush()
+	if err := s.term.restore(); err != nil {
+		return fmt.Errorf("restoring terminal: %w", err)
+	}
+	fmt.Printf("Starting %s in %s, exit to return to gitstatus\n", shell, path)
+
+	cmd := exec.Command(shell)
+	cmd.Dir = path
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	runErr := cmd.Run()
+
+	if err := s.term.raw(); err != nil {
+		return fmt.Errorf("configuring terminal: %w", err)
+	}
+	s.out.WriteString(enterScreen)
+
+	var exitErr *exec.ExitError
+	if runErr != nil && !errors.As(runErr, &exitErr) {
+		return fmt.Errorf("starting %s: %w", shell, runErr)
+	}
+	return nil
+}
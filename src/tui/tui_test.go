@@ -0,0 +1,201 @@
+package tui
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"gitstatus/src/types"
+)
+
+func TestDecodeKeys(t *testing.T) {
+	input := []byte("jk\x1b[A\x1bOB\x1b[5~\r \x03\x1bPé\x1b[Z")
+	want := []key{'j', 'k', keyUp, keyDown, keyPageUp, keyEnter, ' ', keyCtrlC, keyEscape, 'P', 'é', keyUnknown}
+
+	got := decodeKeys(input)
+	if len(got) != len(want) {
+		t.Fatalf("decodeKeys() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Key %d = %v, want %v", i, got[i], want[i])
+		}
+	}
+}
+
+func testResults() []types.RepoResult {
+	return []types.RepoResult{
+		{
+			Path:        "/src/a",
+			Branch:      "main",
+			HasUnsynced: true,
+			Branches: []types.BranchSyncStatus{
+				{Name: "main", Current: true, Ahead: 2, Behind: 1},
+				{Name: "old", Gone: true},
+			},
+			HasUncommitted: true,
+			Uncommitted:    types.WorkdirStatus{Modified: 1},
+		},
+		{Path: "/src/clean", Branch: "main"},
+		{Path: "/src/broken", Error: errors.New("git branch failed")},
+		{
+			Path:            "/src/detached",
+			HasDetachedHead: true,
+			DetachedHead:    types.DetachedHead{Commit: "1a2b3c4", From: "v1.0", Orphaned: 1},
+			HasOperation:    true,
+			Operation:       types.OperationState{Operation: types.OperationRebase, Step: 1, Total: 3},
+		},
+	}
+}
+
+func TestNewModelFiltersCleanRepositories(t *testing.T) {
+	m := newModel(testResults(), types.Config{})
+	if len(m.items) != 3 {
+		t.Fatalf("Expected 3 repositories without ShowAll, got %d", len(m.items))
+	}
+	for _, item := range m.items {
+		if item.res.Path == "/src/clean" {
+			t.Error("Clean repository should be hidden")
+		}
+	}
+
+	m = newModel(testResults(), types.Config{ShowAll: true})
+	if len(m.items) != 4 {
+		t.Errorf("Expected 4 repositories with ShowAll, got %d", len(m.items))
+	}
+}
+
+func TestHandleKey(t *testing.T) {
+	m := newModel(testResults(), types.Config{})
+
+	steps := []struct {
+		key    key
+		cmd    command
+		cursor int
+	}{
+		{keyUp, cmdNone, 0},
+		{'j', cmdNone, 1},
+		{keyEnd, cmdNone, 2},
+		{keyDown, cmdNone, 2},
+		{'g', cmdNone, 0},
+		{keyEnter, cmdLoadDetails, 0},
+		{'f', cmdFetch, 0},
+		{'p', cmdPull, 0},
+		{'P', cmdPush, 0},
+		{'s', cmdShell, 0},
+		{'r', cmdRefresh, 0},
+		{'x', cmdNone, 0},
+		{'q', cmdQuit, 0},
+	}
+	for _, step := range steps {
+		if cmd := m.handleKey(step.key); cmd != step.cmd {
+			t.Errorf("handleKey(%v) = %v, want %v", step.key, cmd, step.cmd)
+		}
+		if m.cursor != step.cursor {
+			t.Errorf("After key %v cursor = %d, want %d", step.key, m.cursor, step.cursor)
+		}
+	}
+
+	if !m.items[0].expanded {
+		t.Error("Enter should expand the selected repository")
+	}
+	m.items[0].details = &repoDetails{}
+	if cmd := m.handleKey(keyLeft); cmd != cmdNone || m.items[0].expanded {
+		t.Errorf("Left should collapse without a command, got %v", cmd)
+	}
+	if cmd := m.handleKey(keyRight); cmd != cmdNone || !m.items[0].expanded {
+		t.Errorf("Right should expand without reloading loaded details, got %v", cmd)
+	}
+
+	empty := newModel(nil, types.Config{})
+	if cmd := empty.handleKey('f'); cmd != cmdNone {
+		t.Errorf("Commands need a selected repository, got %v", cmd)
+	}
+}
+
+func TestView(t *testing.T) {
+	m := newModel(testResults(), types.Config{NoColor: true})
+	m.items[0].expanded = true
+	m.items[0].details = &repoDetails{
+		commits: []branchCommits{{
+			branch: m.items[0].res.Branches[0],
+			ahead:  []types.Commit{{Hash: "1a2b3c4", Author: "Alice", Subject: "Add feature", Time: time.Now().Add(-2 * time.Hour)}},
+			behind: []types.Commit{{Hash: "5d6e7f8", Author: "Bob", Subject: "Upstream fix", Time: time.Now()}},
+		}},
+		files: []types.FileChange{{Status: " M", Path: "main.go"}, {Status: "R ", Path: "new.go", OrigPath: "old.go"}},
+	}
+	m.setStatus("Fetched /src/a", false)
+
+	lines := m.view(120, 40)
+	if len(lines) != 40 {
+		t.Fatalf("view() returned %d lines, want 40", len(lines))
+	}
+	screen := strings.Join(lines, "\n")
+
+	for _, want := range []string{
+		"gitstatus: 3 repositories needing attention",
+		"> ▾ /src/a  main ↑2 ↓1  +1 branch  dirty",
+		"      main [current] (ahead 2, behind 1)",
+		"      old (gone)",
+		"      working tree (modified 1)",
+		"      main: 2 not pushed",
+		"        ↑ 1a2b3c4 Add feature (Alice, 2h)",
+		"        ... 1 more",
+		"      main: 1 not pulled",
+		"        ↓ 5d6e7f8 Upstream fix (Bob, now)",
+		"        R  new.go <- old.go",
+		"  ▸ /src/broken  error: git branch failed",
+		"  ▸ /src/detached  [REBASING 1/3]  HEAD detached",
+		"Fetched /src/a",
+		helpLine,
+	} {
+		if !strings.Contains(screen, want+"\n") && !strings.HasSuffix(screen, want) {
+			t.Errorf("Screen is missing line %q:\n%s", want, screen)
+		}
+	}
+}
+
+func TestViewScrollsToSelection(t *testing.T) {
+	var results []types.RepoResult
+	for _, name := range []string{"a", "b", "c", "d", "e", "f", "g", "h"} {
+		results = append(results, types.RepoResult{Path: "/src/" + name, HasUncommitted: true})
+	}
+	m := newModel(results, types.Config{NoColor: true})
+
+	m.handleKey(keyEnd)
+	lines := m.view(80, 6)
+	if lines[3] != "> ▸ /src/h  (no branch)  dirty" {
+		t.Errorf("Last repository should be on the last body line, got %q", lines[3])
+	}
+	if lines[1] != "  ▸ /src/f  (no branch)  dirty" {
+		t.Errorf("Unexpected first body line %q", lines[1])
+	}
+
+	m.handleKey(keyHome)
+	lines = m.view(80, 6)
+	if lines[1] != "> ▸ /src/a  (no branch)  dirty" {
+		t.Errorf("First repository should be shown again, got %q", lines[1])
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		line  string
+		width int
+		want  string
+	}{
+		{"hello", 10, "hello"},
+		{"hello", 3, "hel"},
+		{"héllo", 2, "hé"},
+		{"\033[32mgreen\033[0m", 3, "\033[32mgre\033[0m"},
+		{"\033[32mgreen\033[0m", 5, "\033[32mgreen\033[0m"},
+		{"hello", 0, ""},
+	}
+
+	for _, tt := range tests {
+		if got := truncate(tt.line, tt.width); got != tt.want {
+			t.Errorf("truncate(%q, %d) = %q, want %q", tt.line, tt.width, got, tt.want)
+		}
+	}
+}
@@ -1,38 +1,160 @@
 package types
 
-// BranchSyncStatus represents a branch's sync state with origin
+import "time"
+
+// BranchSyncStatus represents a branch's sync state with its upstream
 type BranchSyncStatus struct {
-	Name       string
-	Current    bool // is checked out?
-	Ahead      int  // commits ahead of origin
-	Behind     int  // commits behind origin
-	Gone       bool // remote branch is gone
-	NoUpstream bool // no upstream configured
+	Name          string
+	Current       bool               // is checked out?
+	Upstream      string             // upstream branch, e.g. "origin/main" or "main" for a local upstream
+	Remote        string             // remote of the upstream, e.g. "origin", "." for a local upstream
+	Ahead         int                // commits ahead of the upstream
+	Behind        int                // commits behind the upstream
+	Gone          bool               // remote branch is gone
+	NoUpstream    bool               // no upstream configured
+	Push          string             // push destination when it differs from Upstream, e.g. "fork/main"
+	PushAhead     int                // commits ahead of Push
+	PushBehind    int                // commits behind Push
+	PushGone      bool               // Push does not exist yet, the branch was never pushed there
+	LastCommit    time.Time          // committer time of the branch tip
+	LastAuthored  time.Time          // author time of the branch tip
+	Remotes       []RemoteSyncStatus // with -all-remotes, the same branch on the other remotes
+	AheadCommits  []Commit           // with -commits, the commits ahead of the upstream, newest first
+	BehindCommits []Commit           // with -commits, the commits behind the upstream, newest first
+}
+
+// RemoteSyncStatus compares a branch with a remote-tracking branch other than
+// its upstream, e.g. main with upstream/main in a fork
+type RemoteSyncStatus struct {
+	Ref    string // remote-tracking branch, e.g. "upstream/main"
+	Remote string
+	Ahead  int // commits ahead of Ref
+	Behind int // commits behind Ref
+}
+
+// DetachedHead describes a HEAD that is not on any branch
+type DetachedHead struct {
+	Commit   string // abbreviated commit HEAD points at
+	From     string // ref or commit HEAD was detached at
+	Moved    bool   // HEAD has moved since it was detached (git's "detached from")
+	Orphaned int    // commits reachable only from HEAD, not from any branch, tag or remote
 }
 
 // WorkdirStatus represents uncommitted changes in the working directory
 type WorkdirStatus struct {
-	Modified  int // files modified but not staged
-	Staged    int // files staged (added to index)
-	Untracked int // untracked files
+	Modified    int // files modified but not staged
+	Staged      int // files staged (added to index)
+	Untracked   int // untracked files
+	Deleted     int // files deleted in the index or the working tree
+	Renamed     int // files renamed in the index
+	Conflicted  int // unmerged files with conflicts
+	TypeChanged int // files whose type changed (e.g. file to symlink)
 }
 
+// StashStatus summarizes the entries of refs/stash
+type StashStatus struct {
+	Count  int       // number of stash entries
+	Oldest time.Time // creation time of the oldest entry, zero if there are none
+}
+
+// Operation names an unfinished multi-step git operation
+type Operation string
+
+const (
+	OperationNone       Operation = ""
+	OperationRebase     Operation = "rebase"
+	OperationAm         Operation = "am"
+	OperationMerge      Operation = "merge"
+	OperationCherryPick Operation = "cherry-pick"
+	OperationRevert     Operation = "revert"
+	OperationBisect     Operation = "bisect"
+)
+
+// OperationState describes an operation left in progress in a repository
+type OperationState struct {
+	Operation Operation
+	Step      int // current step of a rebase or am, 0 if unknown
+	Total     int // total steps of a rebase or am, 0 if unknown
+}
+
+// Commit is a one-line description of a commit
+type Commit struct {
+	Hash    string // abbreviated commit hash
+	Author  string
+	Subject string
+	Time    time.Time // committer time
+}
+
+// FileChange is an entry of git status for a file with uncommitted changes
+type FileChange struct {
+	Status   string // two-letter XY code of git status --porcelain, "??" for untracked files
+	Path     string
+	OrigPath string // source path of a rename or copy, otherwise ""
+}
+
+// RepoKind describes how a working tree is attached to its git directory
+type RepoKind string
+
+const (
+	RepoKindRepository RepoKind = "repository" // .git is a directory
+	RepoKindGitfile    RepoKind = "gitfile"    // .git is a file pointing at a separate git directory
+	RepoKindWorktree   RepoKind = "worktree"   // linked worktree created by git worktree add
+	RepoKindSubmodule  RepoKind = "submodule"  // submodule checked out inside a superproject
+)
+
 // RepoResult holds info about a git repository
 type RepoResult struct {
-	Path           string
-	Branches       []BranchSyncStatus // branches relevant to status (unsynced or all depending on config)
-	HasUnsynced    bool               // true if any branch is ahead/behind/gone
-	Uncommitted    WorkdirStatus      // uncommitted changes in working directory
-	HasUncommitted bool               // true if there are uncommitted changes
-	Error          error              // any error encountered
+	Path            string
+	Root            string // scan root the repository was found under
+	Kind            RepoKind
+	Parent          string             // main worktree or superproject for worktrees and submodules
+	Branch          string             // current branch, "" when HEAD is detached or has no commits
+	LastCommit      time.Time          // committer time of HEAD, zero when there are no commits
+	LastAuthored    time.Time          // author time of HEAD, zero when there are no commits
+	LastModified    time.Time          // modification time of the most recently changed uncommitted file
+	Branches        []BranchSyncStatus // branches relevant to status (unsynced or all depending on config)
+	HasUnsynced     bool               // true if any branch is ahead/behind/gone, or differs from another remote
+	DetachedHead    DetachedHead       // HEAD state when it is not on a branch
+	HasDetachedHead bool               // true if HEAD is detached
+	Uncommitted     WorkdirStatus      // uncommitted changes in working directory
+	HasUncommitted  bool               // true if there are uncommitted changes
+	Operation       OperationState     // merge, rebase, cherry-pick, revert, am or bisect in progress
+	HasOperation    bool               // true if an operation is in progress
+	Stash           StashStatus        // stashed changes
+	HasStash        bool               // true if there is at least one stash entry
+	UnpushedTags    []string           // local tags no remote has, sorted by name
+	HasUnpushedTags bool               // true if there is at least one unpushed tag
+	TagsPartial     bool               // the remotes' tags could not be listed, so tags on pushed commits were not checked
+	FetchError      error              // set when -fetch could not update remote-tracking refs
+	Error           error              // any error encountered
 }
 
 // Config holds CLI configuration
 type Config struct {
-	RootPath string
-	MaxDepth int
-	LogTypes []string
-	ShowAll  bool
-	NoColor  bool
-	LogFile  string
+	RootPaths   []string // directories to scan
+	MaxDepth    int
+	IgnoredDirs []string // comma-separated directory names to skip besides the defaults, "!name" to scan one of them
+	Exclude     []string // gitignore-style patterns of directories to skip, relative to each root
+	Include     []string // gitignore-style patterns; when set only matching repositories are analyzed
+	Jobs        int      // number of repositories analyzed in parallel
+	LogTypes    []string
+	ShowAll     bool
+	Relative    bool // show repository paths relative to their scan root
+	NoColor     bool
+	Format      string        // output format: text, json, tree or table
+	Columns     []string      // columns of the table format, nil means the default columns
+	PerBranch   bool          // table format: one row per branch instead of per repository
+	Sort        string        // table format: order of the rows, by path or age
+	Commits     bool          // list the commits ahead of and behind the upstream under each branch
+	CommitLimit int           // with Commits, the most commits listed per repository, 0 means no limit
+	Stale       time.Duration // only show repositories and branches inactive for at least this long
+	Since       time.Duration // only show repositories and branches active within this long
+	Summary     bool          // print totals after the results
+	SummaryOnly bool          // print only the totals
+	Fetch       bool          // fetch remotes before computing branch status
+	NoTags      bool          // do not look for tags missing from the remotes
+	AllRemotes  bool          // compare branches with every remote, not only their upstream
+	Backend     string        // how repositories are read: exec or native
+	FailOn      []string      // conditions that make the process exit non-zero
+	LogFile     string
 }
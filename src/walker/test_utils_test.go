@@ -115,6 +115,18 @@ func SetupTestRepos(t *testing.T) string {
 
 	git(pathGone, "fetch", "-p")
 
+	pathWithWorktree := cloneRepo("repo_with_worktree")
+	git(pathWithWorktree, "worktree", "add", "-b", "worktree-branch", filepath.Join(testEnvPath, "repo_worktree"))
+
+	pathWithSubmodule := cloneRepo("repo_with_submodule")
+	git(pathWithSubmodule, "-c", "protocol.file.allow=always", "submodule", "add", remoteRepoPath, "sub")
+
+	if err := os.MkdirAll(filepath.Join(testEnvPath, "gitdirs"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	git(testEnvPath, "clone", "--separate-git-dir", filepath.Join(testEnvPath, "gitdirs", "repo_gitfile.git"),
+		remoteRepoPath, "repo_gitfile")
+
 	if err := os.MkdirAll(filepath.Join(testEnvPath, "not_a_repo"), 0755); err != nil {
 		t.Fatal(err)
 	}
@@ -2,25 +2,247 @@ package walker
 
 import (
 	"context"
+	"fmt"
 	"io/fs"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 
+	"gitstatus/src/config"
 	"gitstatus/src/defaults"
 	"gitstatus/src/git"
 	"gitstatus/src/logger"
+	"gitstatus/src/pattern"
 	"gitstatus/src/types"
 )
 
+// repoJob is a discovered repository waiting to be analyzed
+type repoJob struct {
+	path string
+	root string
+}
+
+// repoOutcome is the analysis of a repoJob; ok is false when it was aborted
+type repoOutcome struct {
+	path   string
+	result types.RepoResult
+	ok     bool
+}
+
+// walkProgress tells the collector of Walk how far discovery got: path, if
+// not "", was queued for analysis, and no repository queued later sorts
+// before bound
+type walkProgress struct {
+	path  string
+	bound string
+}
+
+// PathLess reports whether path a sorts before b. Paths are compared one
+// element at a time, so a directory's contents come right after it: "a",
+// "a/b", "a-c". This is the order the walk visits directories in.
+func PathLess(a, b string) bool {
+	sep := string(filepath.Separator)
+	return strings.ReplaceAll(a, sep, "\x00") < strings.ReplaceAll(b, sep, "\x00")
+}
+
+// Walk discovers git repositories under every root in cfg.RootPaths and
+// analyzes them with a pool of cfg.Jobs workers. A repository reachable from
+// more than one root, directly or through symlinks, is analyzed once under
+// the first root that reaches it.
+// Results are passed to callback while the scan goes on, one at a time and
+// sorted by path (see PathLess): a result is held back until every
+// repository sorting before it was analyzed and no such repository can still
+// be found.
 func Walk(
 	ctx context.Context,
 	cfg types.Config,
 	logger *logger.Logger,
 	callback func(types.RepoResult),
 ) error {
-	logger.Info("Starting scan from: %s", cfg.RootPath)
-	err := filepath.WalkDir(cfg.RootPath, func(path string, d fs.DirEntry, err error) error {
+	jobs := cfg.Jobs
+	if jobs < 1 {
+		jobs = defaults.DefaultJobs
+	}
+
+	repoJobs := make(chan repoJob)
+	repoResults := make(chan repoOutcome)
+	progress := make(chan walkProgress)
+
+	var fetches git.FetchGroup
+	var wg sync.WaitGroup
+	for i := 0; i < jobs; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range repoJobs {
+				result, ok := AnalyzeRepo(ctx, cfg, job.path, &fetches, logger)
+				result.Root = job.root
+				repoResults <- repoOutcome{path: job.path, result: result, ok: ok}
+			}
+		}()
+	}
+
+	// Deliver finished results in path order as far as discovery allows
+	collected := make(chan struct{})
+	go func() {
+		var queued []string // queued paths not delivered yet, sorted
+		finished := make(map[string]repoOutcome)
+		bound, walking := "", true
+		deliver := func() {
+			for len(queued) > 0 {
+				outcome, ok := finished[queued[0]]
+				if !ok || walking && PathLess(bound, queued[0]) {
+					return
+				}
+				delete(finished, queued[0])
+				queued = queued[1:]
+				if outcome.ok {
+					callback(outcome.result)
+				}
+			}
+		}
+
+		found, results := progress, repoResults
+		for walking || results != nil {
+			select {
+			case p, ok := <-found:
+				if !ok {
+					walking, found = false, nil
+					break
+				}
+				if p.path != "" {
+					i := sort.Search(len(queued), func(i int) bool { return !PathLess(queued[i], p.path) })
+					queued = append(queued[:i], append([]string{p.path}, queued[i:]...)...)
+				}
+				bound = p.bound
+			case outcome, ok := <-results:
+				if !ok {
+					results = nil
+					break
+				}
+				finished[outcome.path] = outcome
+			}
+			deliver()
+		}
+		close(collected)
+	}()
+
+	// Repositories found under a root sort after it, so nothing found later
+	// sorts before the first of the roots still to walk
+	roots := cfg.RootPaths
+	laterRoot := make([]string, len(roots)) // "" after the last root
+	for i := len(roots) - 2; i >= 0; i-- {
+		laterRoot[i] = roots[i+1]
+		if next := laterRoot[i+1]; next != "" && PathLess(next, laterRoot[i]) {
+			laterRoot[i] = next
+		}
+	}
+	bound := func(i int, path string) string {
+		if laterRoot[i] != "" && PathLess(laterRoot[i], path) {
+			return laterRoot[i]
+		}
+		return path
+	}
+
+	seen := make(map[string]string)
+	var err error
+	for i, root := range roots {
+		err = walkRoot(ctx, cfg, root, seen, func(job repoJob) error {
+			select {
+			case progress <- walkProgress{path: job.path, bound: bound(i, job.path)}:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			// Every queued path needs an outcome, so the job is handed over
+			// even when the scan was cancelled meanwhile
+			repoJobs <- job
+			return nil
+		}, nil, logger)
+		if err != nil {
+			break
+		}
+		if laterRoot[i] != "" {
+			progress <- walkProgress{bound: laterRoot[i]}
+		}
+	}
+
+	close(progress)
+	close(repoJobs)
+	wg.Wait()
+	close(repoResults)
+	<-collected
+
+	return err
+}
+
+// Location is a repository found by Discover
+type Location struct {
+	Path string
+	Root string // scan root the repository was found under
+}
+
+// Discover finds the repositories under cfg.RootPaths like Walk without
+// analyzing them. It returns them sorted by path like Walk, along with every
+// directory the walk looked into, which is where new repositories would
+// appear.
+func Discover(ctx context.Context, cfg types.Config, logger *logger.Logger) ([]Location, []string, error) {
+	var locations []Location
+	queue := func(job repoJob) error {
+		locations = append(locations, Location{Path: job.path, Root: job.root})
+		return nil
+	}
+
+	var dirs []string
+	visit := func(dir string) {
+		dirs = append(dirs, dir)
+	}
+
+	seen := make(map[string]string)
+	var err error
+	for _, root := range cfg.RootPaths {
+		err = walkRoot(ctx, cfg, root, seen, queue, visit, logger)
+		if err != nil {
+			break
+		}
+	}
+
+	sort.SliceStable(locations, func(i, j int) bool {
+		return PathLess(locations[i].Path, locations[j].Path)
+	})
+
+	return locations, dirs, err
+}
+
+// walkRoot walks a single root and passes every repository it finds to
+// queue, stopping at the first error queue returns. seen maps the resolved path of each repository already queued
+// to the path it was queued under. visit, if not nil, is called with every
+// directory that is not skipped.
+func walkRoot(
+	ctx context.Context,
+	cfg types.Config,
+	root string,
+	seen map[string]string,
+	queue func(repoJob) error,
+	visit func(dir string),
+	logger *logger.Logger,
+) error {
+	rules, err := loadRootRules(cfg, root)
+	if err != nil {
+		return err
+	}
+
+	// WalkDir does not descend into a root that is itself a symlink
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		logger.Error("Error accessing path %s: %v", root, err)
+		return nil
+	}
+
+	logger.Info("Starting scan from: %s", root)
+	err = filepath.WalkDir(realRoot, func(realPath string, d fs.DirEntry, err error) error {
 		select {
 		case <-ctx.Done():
 			return ctx.Err()
@@ -28,7 +250,7 @@ func Walk(
 		}
 
 		if err != nil {
-			logger.Error("Error accessing path %s: %v", path, err)
+			logger.Error("Error accessing path %s: %v", realPath, err)
 			return nil
 		}
 
@@ -36,48 +258,74 @@ func Walk(
 			return nil
 		}
 
-		if cfg.MaxDepth > 0 {
-			rel, relErr := filepath.Rel(cfg.RootPath, path)
-			if relErr != nil {
-				logger.Warn("Could not calculate relative path for %s: %v", path, relErr)
-				return nil
-			}
+		rel, relErr := filepath.Rel(realRoot, realPath)
+		if relErr != nil {
+			logger.Warn("Could not calculate relative path for %s: %v", realPath, relErr)
+			return nil
+		}
+		path := filepath.Join(root, rel)
+
+		if rules.maxDepth > 0 {
 			depth := strings.Count(rel, string(os.PathSeparator))
-			if depth >= cfg.MaxDepth {
+			if depth >= rules.maxDepth {
 				return filepath.SkipDir
 			}
 		}
 
-		for _, skipDir := range defaults.DefaultIgnoredDirs {
+		for _, skipDir := range rules.ignoredDirs {
 			if d.Name() == skipDir {
-				logger.Debug("Skipping directory: %s", path)
+				logger.Debug("Skipping directory: %s (ignored directory name %q)", path, skipDir)
+				return filepath.SkipDir
+			}
+		}
+
+		if rel != "." {
+			if excluded, rule := rules.excludes.Match(rel); excluded {
+				logger.Debug("Skipping directory: %s (exclude pattern %q)", path, rule.Text)
 				return filepath.SkipDir
 			}
 		}
 
 		logger.Debug("Checking directory: %s", path)
+		if visit != nil {
+			visit(path)
+		}
 
-		gitDir := filepath.Join(path, ".git")
-		fileInfo, statErr := os.Stat(gitDir)
-		if statErr == nil {
-			if fileInfo.IsDir() {
-				logger.Debug("Found git repo: %s", path)
+		kind, parent, detectErr := git.DetectRepo(path)
+		if detectErr != nil {
+			if detectErr != git.ErrNotRepository {
+				logger.Debug("Error checking for .git in %s: %v", path, detectErr)
+			}
+			return nil
+		}
+
+		if parent != "" {
+			logger.Debug("Found git %s: %s (parent: %s)", kind, path, parent)
+		} else {
+			logger.Debug("Found git %s: %s", kind, path)
+		}
 
-				result, repoErr := git.GetRepoStatus(ctx, path, logger)
-				if repoErr != nil {
-					logger.Error("Error getting repo status for %s: %v", path, repoErr)
-					callback(types.RepoResult{Path: path, Error: repoErr})
+		// A root that is itself a repository was named explicitly
+		if len(rules.includes) > 0 && rel != "." {
+			included, rule := rules.includes.MatchOrParent(rel)
+			if !included {
+				if rule != nil {
+					logger.Debug("Skipping repo: %s (negated include pattern %q)", path, rule.Text)
 				} else {
-					callback(*result)
+					logger.Debug("Skipping repo: %s (no include pattern matched)", path)
 				}
+				return nil
 			}
-		} else {
-			if !os.IsNotExist(statErr) {
-				logger.Debug("Error checking for .git in %s: %v", path, statErr)
-			}
+			logger.Debug("Including repo: %s (include pattern %q)", path, rule.Text)
 		}
 
-		return nil
+		if first, ok := seen[realPath]; ok {
+			logger.Debug("Skipping repo: %s (already found as %s)", path, first)
+			return nil
+		}
+		seen[realPath] = path
+
+		return queue(repoJob{path: path, root: root})
 	})
 
 	if err != nil {
@@ -86,3 +334,147 @@ func Walk(
 
 	return err
 }
+
+// rootRules decide what is skipped under one scan root
+type rootRules struct {
+	excludes    pattern.List
+	includes    pattern.List
+	ignoredDirs []string
+	maxDepth    int // 0 means unlimited
+}
+
+// loadRootRules returns what is skipped under root: the exclude patterns
+// from the root's ignore file, its config file and cfg.Exclude, in that
+// order, the include patterns from the root's config file and cfg.Include,
+// the ignored directory names of cfg plus those of the root's config file,
+// and the lower of the depth limits of cfg and the root's config file. The
+// config file of one root never affects another.
+func loadRootRules(cfg types.Config, root string) (rootRules, error) {
+	ignoreFile := filepath.Join(root, defaults.DefaultIgnoreFile)
+	excludeLines, err := pattern.ReadFile(ignoreFile)
+	if err != nil {
+		return rootRules{}, fmt.Errorf("reading %s: %w", ignoreFile, err)
+	}
+
+	settings, err := config.ReadRootFile(root)
+	if err != nil {
+		return rootRules{}, err
+	}
+
+	rules := rootRules{maxDepth: cfg.MaxDepth}
+	var includeLines []string
+	ignoreLists := append([]string(nil), cfg.IgnoredDirs...)
+	for _, s := range settings {
+		switch s.Key {
+		case "exclude":
+			excludeLines = append(excludeLines, s.Value)
+		case "include":
+			includeLines = append(includeLines, s.Value)
+		case "ignore":
+			ignoreLists = append(ignoreLists, s.Value)
+		case "depth":
+			// ReadRootFile checked the value
+			depth, _ := strconv.Atoi(s.Value)
+			if depth > 0 && (rules.maxDepth == 0 || depth < rules.maxDepth) {
+				rules.maxDepth = depth
+			}
+		}
+	}
+	rules.ignoredDirs = config.IgnoredDirs(ignoreLists...)
+
+	if rules.excludes, err = pattern.ParseList(append(excludeLines, cfg.Exclude...)); err != nil {
+		return rootRules{}, err
+	}
+	if rules.includes, err = pattern.ParseList(append(includeLines, cfg.Include...)); err != nil {
+		return rootRules{}, err
+	}
+	return rules, nil
+}
+
+// AnalyzeRepo runs the git analysis for a single repository, fetching its
+// remotes first when cfg.Fetch is set. Repositories analyzed in parallel
+// should share fetches so that worktrees of one repository are fetched once;
+// nil fetches the repository on its own. It reports false when the analysis
+// was aborted because the scan was cancelled.
+func AnalyzeRepo(ctx context.Context, cfg types.Config, path string, fetches *git.FetchGroup, logger *logger.Logger) (types.RepoResult, bool) {
+	var fetchErr error
+	if cfg.Fetch {
+		if fetches != nil {
+			fetchErr = fetches.Fetch(ctx, path, logger)
+		} else {
+			fetchErr = git.FetchRepo(ctx, path, logger)
+		}
+		if fetchErr != nil {
+			if ctx.Err() != nil {
+				return types.RepoResult{}, false
+			}
+			logger.Warn("Fetch failed for %s: %v", path, fetchErr)
+		}
+	}
+
+	result, err := git.GetRepoStatus(ctx, path, cfg.Backend, logger)
+	if err != nil {
+		if ctx.Err() != nil {
+			logger.Debug("Analysis of %s aborted: %v", path, ctx.Err())
+			return types.RepoResult{}, false
+		}
+		logger.Error("Error getting repo status for %s: %v", path, err)
+		kind, parent, _ := git.DetectRepo(path)
+		return types.RepoResult{Path: path, Kind: kind, Parent: parent, FetchError: fetchErr, Error: err}, true
+	}
+	result.FetchError = fetchErr
+
+	if cfg.AllRemotes {
+		if err := git.CompareAllRemotes(ctx, path, result, logger); err != nil && ctx.Err() == nil {
+			logger.Error("Failed to compare branches with all remotes for %s: %v", path, err)
+		}
+	}
+
+	if !cfg.NoTags {
+		// Only talk to the remotes when -fetch did and reached them; otherwise
+		// the remote-tracking branches answer offline
+		tags, partial, err := git.GetUnpushedTags(ctx, path, cfg.Fetch && fetchErr == nil, logger)
+		if err != nil && ctx.Err() == nil {
+			logger.Error("Failed to get unpushed tags for %s: %v", path, err)
+		}
+		result.UnpushedTags = tags
+		result.HasUnpushedTags = len(tags) > 0
+		result.TagsPartial = partial
+	}
+
+	if cfg.Commits {
+		if err := listBranchCommits(ctx, path, result, cfg.CommitLimit, logger); err != nil && ctx.Err() == nil {
+			logger.Error("Failed to list the commits of %s: %v", path, err)
+		}
+	}
+	return *result, true
+}
+
+// listBranchCommits stores the commits each branch is ahead of and behind
+// its upstream, listing at most limit commits in the whole repository (0
+// means no limit). Branches are filled in order until the limit is reached.
+func listBranchCommits(ctx context.Context, path string, result *types.RepoResult, limit int, logger *logger.Logger) error {
+	remaining := limit
+	for i := range result.Branches {
+		b := &result.Branches[i]
+		if b.Gone || b.NoUpstream || (b.Ahead == 0 && b.Behind == 0) {
+			continue
+		}
+		if limit > 0 && remaining == 0 {
+			break
+		}
+
+		ahead, behind, err := git.GetBranchCommits(ctx, path, b.Name, remaining, logger)
+		if err != nil {
+			return err
+		}
+		if limit > 0 {
+			ahead = ahead[:min(len(ahead), remaining)]
+			remaining -= len(ahead)
+			behind = behind[:min(len(behind), remaining)]
+			remaining -= len(behind)
+		}
+		b.AheadCommits, b.BehindCommits = ahead, behind
+	}
+	return nil
+}
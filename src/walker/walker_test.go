@@ -2,12 +2,15 @@ package walker
 
 import (
 	"context"
+	"os"
+	"os/exec"
 	"path/filepath"
+	"reflect"
+	"sort"
 	"strings"
-	"sync"
 	"testing"
-	"time"
 
+	"gitstatus/src/git"
 	"gitstatus/src/logger"
 	"gitstatus/src/types"
 )
@@ -20,8 +23,8 @@ func TestWalkRealRepos(t *testing.T) {
 	t.Run("FindGitAtRoot", func(t *testing.T) {
 		repoPath := filepath.Join(testEnv, "repo_synced")
 		cfg := types.Config{
-			RootPath: repoPath,
-			MaxDepth: 0,
+			RootPaths: []string{repoPath},
+			MaxDepth:  0,
 		}
 
 		var results []types.RepoResult
@@ -44,8 +47,8 @@ func TestWalkRealRepos(t *testing.T) {
 		nestedRoot := filepath.Join(testEnv, "nested")
 
 		cfg := types.Config{
-			RootPath: nestedRoot,
-			MaxDepth: 3,
+			RootPaths: []string{nestedRoot},
+			MaxDepth:  3,
 		}
 
 		var results []types.RepoResult
@@ -69,8 +72,8 @@ func TestWalkRealRepos(t *testing.T) {
 		nestedRoot := filepath.Join(testEnv, "nested")
 
 		cfg := types.Config{
-			RootPath: nestedRoot,
-			MaxDepth: 1,
+			RootPaths: []string{nestedRoot},
+			MaxDepth:  1,
 		}
 
 		var results []types.RepoResult
@@ -83,10 +86,66 @@ func TestWalkRealRepos(t *testing.T) {
 		}
 	})
 
+	t.Run("ParallelResultsSorted", func(t *testing.T) {
+		var sequential []types.RepoResult
+		Walk(ctx, types.Config{RootPaths: []string{testEnv}, MaxDepth: 5, Jobs: 1}, logger, func(result types.RepoResult) {
+			sequential = append(sequential, result)
+		})
+
+		var parallel []types.RepoResult
+		Walk(ctx, types.Config{RootPaths: []string{testEnv}, MaxDepth: 5, Jobs: 4}, logger, func(result types.RepoResult) {
+			parallel = append(parallel, result)
+		})
+
+		if len(parallel) != len(sequential) {
+			t.Fatalf("Expected %d results with 4 jobs, got %d", len(sequential), len(parallel))
+		}
+		for i := range parallel {
+			if parallel[i].Path != sequential[i].Path {
+				t.Errorf("Result %d: expected path %s, got %s", i, sequential[i].Path, parallel[i].Path)
+			}
+		}
+		if !sort.SliceIsSorted(parallel, func(i, j int) bool { return PathLess(parallel[i].Path, parallel[j].Path) }) {
+			t.Errorf("Results not sorted by path: %v", parallel)
+		}
+	})
+
+	t.Run("NestedRootsSorted", func(t *testing.T) {
+		root := t.TempDir()
+		for _, name := range []string{"z", "a-c", "a", "a/b", "m/z"} {
+			cmd := exec.Command("git", "init", "--quiet", filepath.Join(root, name))
+			if out, err := cmd.CombinedOutput(); err != nil {
+				t.Fatalf("git init failed: %v\n%s", err, out)
+			}
+		}
+
+		// The outer root skips m, which the inner root covers
+		cfg := types.Config{
+			RootPaths: []string{filepath.Join(root, "m"), root},
+			MaxDepth:  5,
+			Jobs:      4,
+			Exclude:   []string{"m"},
+		}
+		var paths []string
+		if err := Walk(ctx, cfg, logger, func(result types.RepoResult) {
+			paths = append(paths, result.Path)
+		}); err != nil {
+			t.Fatalf("Walk failed: %v", err)
+		}
+
+		var expected []string
+		for _, name := range []string{"a", "a/b", "a-c", "m/z", "z"} {
+			expected = append(expected, filepath.Join(root, name))
+		}
+		if !reflect.DeepEqual(paths, expected) {
+			t.Errorf("Walk delivered %v, want %v", paths, expected)
+		}
+	})
+
 	t.Run("SkipDirsToSkip", func(t *testing.T) {
 		cfg := types.Config{
-			RootPath: testEnv,
-			MaxDepth: 5,
+			RootPaths: []string{testEnv},
+			MaxDepth:  5,
 		}
 
 		var results []types.RepoResult
@@ -101,39 +160,301 @@ func TestWalkRealRepos(t *testing.T) {
 		}
 	})
 
-	t.Run("ContextCancellation", func(t *testing.T) {
+	t.Run("CustomIgnoredDirs", func(t *testing.T) {
+		cfg := types.Config{
+			RootPaths:   []string{testEnv},
+			MaxDepth:    5,
+			IgnoredDirs: []string{"nested", "!node_modules"},
+		}
+
+		var results []types.RepoResult
+		Walk(ctx, cfg, logger, func(result types.RepoResult) {
+			results = append(results, result)
+		})
+
+		foundIgnored := false
+		for _, r := range results {
+			if strings.Contains(r.Path, "nested") {
+				t.Errorf("Should not have found repo in nested: %s", r.Path)
+			}
+			if strings.Contains(r.Path, "node_modules") {
+				foundIgnored = true
+			}
+		}
+		if !foundIgnored {
+			t.Error("Expected node_modules to be scanned when removed with !node_modules")
+		}
+	})
+
+	t.Run("ExcludePatterns", func(t *testing.T) {
+		cfg := types.Config{
+			RootPaths: []string{testEnv},
+			MaxDepth:  5,
+			Exclude:   []string{"nested/**", "repo_*", "!repo_ahead"},
+		}
+
+		var results []types.RepoResult
+		Walk(ctx, cfg, logger, func(result types.RepoResult) {
+			results = append(results, result)
+		})
+
+		if len(results) != 1 || results[0].Path != filepath.Join(testEnv, "repo_ahead") {
+			t.Errorf("Expected only repo_ahead, got %v", results)
+		}
+	})
+
+	t.Run("IncludePatterns", func(t *testing.T) {
+		cfg := types.Config{
+			RootPaths: []string{testEnv},
+			MaxDepth:  5,
+			Include:   []string{"nested/*", "repo_b*"},
+		}
+
+		var results []types.RepoResult
+		Walk(ctx, cfg, logger, func(result types.RepoResult) {
+			results = append(results, result)
+		})
+
+		expected := []string{
+			filepath.Join(testEnv, "nested", "level1", "repo_deep"),
+			filepath.Join(testEnv, "repo_behind"),
+		}
+		if len(results) != len(expected) {
+			t.Fatalf("Expected %d results, got %v", len(expected), results)
+		}
+		for i, path := range expected {
+			if results[i].Path != path {
+				t.Errorf("Result %d: expected %s, got %s", i, path, results[i].Path)
+			}
+		}
+	})
+
+	t.Run("IncludePatternsRootRepo", func(t *testing.T) {
+		root := filepath.Join(testEnv, "repo_ahead")
+		cfg := types.Config{
+			RootPaths: []string{root},
+			MaxDepth:  5,
+			Include:   []string{"nothing-matches"},
+		}
+
+		var results []types.RepoResult
+		Walk(ctx, cfg, logger, func(result types.RepoResult) {
+			results = append(results, result)
+		})
+
+		if len(results) != 1 || results[0].Path != root {
+			t.Errorf("Expected only the root repository, got %v", results)
+		}
+	})
+
+	t.Run("IgnoreFile", func(t *testing.T) {
+		root := t.TempDir()
+		for _, name := range []string{"keep", "skip"} {
+			cmd := exec.Command("git", "init", filepath.Join(root, name))
+			if out, err := cmd.CombinedOutput(); err != nil {
+				t.Fatalf("git init failed: %v\n%s", err, out)
+			}
+		}
+		if err := os.WriteFile(filepath.Join(root, ".gitstatusignore"), []byte("# local\nskip\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		var results []types.RepoResult
+		Walk(ctx, types.Config{RootPaths: []string{root}}, logger, func(result types.RepoResult) {
+			results = append(results, result)
+		})
+
+		if len(results) != 1 || results[0].Path != filepath.Join(root, "keep") {
+			t.Errorf("Expected only keep, got %v", results)
+		}
+	})
+
+	t.Run("RootConfigFilePerRoot", func(t *testing.T) {
+		a, b := t.TempDir(), t.TempDir()
+		for _, root := range []string{a, b} {
+			for _, name := range []string{"keep", "skip", "cache", "sub/deep"} {
+				cmd := exec.Command("git", "init", filepath.Join(root, name))
+				if out, err := cmd.CombinedOutput(); err != nil {
+					t.Fatalf("git init failed: %v\n%s", err, out)
+				}
+			}
+		}
+		if err := os.WriteFile(filepath.Join(a, ".gitstatus"), []byte("exclude = skip\nignore = cache\ndepth = 1\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		// Whatever the order of the roots, a's file only applies under a
+		for _, roots := range [][]string{{a, b}, {b, a}} {
+			var found []string
+			Walk(ctx, types.Config{RootPaths: roots}, logger, func(result types.RepoResult) {
+				found = append(found, result.Path)
+			})
+			sort.Strings(found)
+
+			want := []string{filepath.Join(a, "keep")}
+			for _, name := range []string{"cache", "keep", "skip", "sub/deep"} {
+				want = append(want, filepath.Join(b, name))
+			}
+			sort.Strings(want)
+			if strings.Join(found, ",") != strings.Join(want, ",") {
+				t.Errorf("Roots %v: found %v, want %v", roots, found, want)
+			}
+		}
+
+		if err := os.WriteFile(filepath.Join(b, ".gitstatus"), []byte("logfile = /tmp/x\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := Walk(ctx, types.Config{RootPaths: []string{b}}, logger, func(types.RepoResult) {}); err == nil {
+			t.Error("Expected error for a setting not allowed in a root file")
+		}
+	})
+
+	t.Run("MultipleRootsDeduplicated", func(t *testing.T) {
+		link := filepath.Join(t.TempDir(), "nested_link")
+		if err := os.Symlink(filepath.Join(testEnv, "nested"), link); err != nil {
+			t.Fatal(err)
+		}
+
 		cfg := types.Config{
-			RootPath: testEnv,
+			RootPaths: []string{
+				filepath.Join(testEnv, "nested"),
+				filepath.Join(testEnv, "repo_ahead"),
+				link,
+				testEnv,
+			},
 			MaxDepth: 5,
 		}
 
+		var results []types.RepoResult
+		Walk(ctx, cfg, logger, func(result types.RepoResult) {
+			results = append(results, result)
+		})
+
+		counts := make(map[string]int)
+		for _, r := range results {
+			counts[filepath.Base(r.Path)]++
+		}
+		for _, name := range []string{"repo_deep", "repo_ahead", "repo_synced"} {
+			if counts[name] != 1 {
+				t.Errorf("Expected %s exactly once, found %d times", name, counts[name])
+			}
+		}
+
+		for _, r := range results {
+			switch filepath.Base(r.Path) {
+			case "repo_deep":
+				if r.Root != filepath.Join(testEnv, "nested") {
+					t.Errorf("Expected repo_deep under the first root that reached it, got %s", r.Root)
+				}
+			case "repo_synced":
+				if r.Root != testEnv {
+					t.Errorf("Expected repo_synced root %s, got %s", testEnv, r.Root)
+				}
+			}
+		}
+	})
+
+	t.Run("SymlinkRoot", func(t *testing.T) {
+		link := filepath.Join(t.TempDir(), "nested_link")
+		if err := os.Symlink(filepath.Join(testEnv, "nested"), link); err != nil {
+			t.Fatal(err)
+		}
+
+		var results []types.RepoResult
+		Walk(ctx, types.Config{RootPaths: []string{link}}, logger, func(result types.RepoResult) {
+			results = append(results, result)
+		})
+
+		expectedPath := filepath.Join(link, "level1", "repo_deep")
+		if len(results) != 1 || results[0].Path != expectedPath {
+			t.Errorf("Expected %s through the symlinked root, got %v", expectedPath, results)
+		}
+	})
+
+	t.Run("InvalidPattern", func(t *testing.T) {
+		cfg := types.Config{
+			RootPaths: []string{testEnv},
+			Exclude:   []string{"repo_[a"},
+		}
+
+		if err := Walk(ctx, cfg, logger, func(types.RepoResult) {}); err == nil {
+			t.Error("Expected error for invalid exclude pattern")
+		}
+	})
+
+	t.Run("ContextCancellation", func(t *testing.T) {
+		cfg := types.Config{
+			RootPaths: []string{testEnv},
+			MaxDepth:  5,
+			Jobs:      1,
+		}
+
+		var total int
+		Walk(context.Background(), cfg, logger, func(types.RepoResult) {
+			total++
+		})
+		if total < 3 {
+			t.Fatalf("Expected several repositories in the test environment, found %d", total)
+		}
+
+		// Results stream in while the walk goes on, so cancelling from the
+		// first one stops the scan before the rest are analyzed
 		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
 
 		var count int
-		var mu sync.Mutex
-
 		err := Walk(ctx, cfg, logger, func(result types.RepoResult) {
-			mu.Lock()
 			count++
 			if count == 1 {
 				cancel()
 			}
-			mu.Unlock()
-			time.Sleep(10 * time.Millisecond) 
 		})
 
-		if err != nil && err != context.Canceled {
-			t.Logf("Walk returned error (expected possibly Canceled): %v", err)
+		if err != context.Canceled {
+			t.Errorf("Expected context.Canceled, got %v", err)
 		}
+		if count == 0 || count >= total {
+			t.Errorf("Expected the scan to stop after the first of %d results, got %d", total, count)
+		}
+	})
 
-		// We can't guarantee exactly 1 result due to concurrency, but it shouldn't be ALL of them
-		// And we expect cancellation to happen.
+	t.Run("FindWorktreesAndSubmodules", func(t *testing.T) {
+		cfg := types.Config{
+			RootPaths: []string{testEnv},
+			MaxDepth:  2,
+		}
+
+		var results []types.RepoResult
+		Walk(ctx, cfg, logger, func(result types.RepoResult) {
+			results = append(results, result)
+		})
+
+		expected := map[string]types.RepoKind{
+			filepath.Join(testEnv, "repo_worktree"):              types.RepoKindWorktree,
+			filepath.Join(testEnv, "repo_with_submodule", "sub"): types.RepoKindSubmodule,
+			filepath.Join(testEnv, "repo_gitfile"):               types.RepoKindGitfile,
+		}
+
+		for path, kind := range expected {
+			found := false
+			for _, r := range results {
+				if r.Path == path {
+					found = true
+					if r.Kind != kind {
+						t.Errorf("Expected %s to be a %s, got %s", path, kind, r.Kind)
+					}
+				}
+			}
+			if !found {
+				t.Errorf("Expected to find %s: %s", kind, path)
+			}
+		}
 	})
 
 	t.Run("FindMultipleRepos", func(t *testing.T) {
 		cfg := types.Config{
-			RootPath: testEnv,
-			MaxDepth: 1,
+			RootPaths: []string{testEnv},
+			MaxDepth:  1,
 		}
 
 		var results []types.RepoResult
@@ -162,3 +483,148 @@ func TestWalkRealRepos(t *testing.T) {
 		}
 	})
 }
+
+func TestAnalyzeRepoCommits(t *testing.T) {
+	logger, _ := logger.NewLogger([]string{}, "")
+	ctx := context.Background()
+
+	dir := t.TempDir()
+	run := func(dir string, args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-c", "user.name=Test User", "-c", "user.email=test@example.com"}, args...)...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v failed: %v\n%s", args, err, out)
+		}
+	}
+	remote := filepath.Join(dir, "remote.git")
+	a := filepath.Join(dir, "a")
+	b := filepath.Join(dir, "b")
+	run(dir, "init", "--quiet", "--bare", "--initial-branch=main", remote)
+	run(dir, "clone", "--quiet", remote, a)
+	run(a, "commit", "--quiet", "--allow-empty", "-m", "Initial commit")
+	run(a, "push", "--quiet", "origin", "main")
+	run(dir, "clone", "--quiet", remote, b)
+	for _, subject := range []string{"Upstream 1", "Upstream 2"} {
+		run(b, "commit", "--quiet", "--allow-empty", "-m", subject)
+	}
+	run(b, "push", "--quiet")
+	for _, subject := range []string{"Local 1", "Local 2", "Local 3"} {
+		run(a, "commit", "--quiet", "--allow-empty", "-m", subject)
+	}
+	run(a, "fetch", "--quiet")
+
+	subjects := func(commits []types.Commit) string {
+		var s []string
+		for _, c := range commits {
+			s = append(s, c.Subject)
+		}
+		return strings.Join(s, ", ")
+	}
+
+	tests := []struct {
+		limit  int
+		ahead  string
+		behind string
+	}{
+		{0, "Local 3, Local 2, Local 1", "Upstream 2, Upstream 1"},
+		{4, "Local 3, Local 2, Local 1", "Upstream 2"},
+		{2, "Local 3, Local 2", ""},
+	}
+	for _, tt := range tests {
+		res, ok := AnalyzeRepo(ctx, types.Config{Commits: true, CommitLimit: tt.limit}, a, nil, logger)
+		if !ok || res.Error != nil || len(res.Branches) != 1 {
+			t.Fatalf("AnalyzeRepo failed: %+v", res)
+		}
+		br := res.Branches[0]
+		if got := subjects(br.AheadCommits); got != tt.ahead {
+			t.Errorf("Limit %d: ahead commits = %q, want %q", tt.limit, got, tt.ahead)
+		}
+		if got := subjects(br.BehindCommits); got != tt.behind {
+			t.Errorf("Limit %d: behind commits = %q, want %q", tt.limit, got, tt.behind)
+		}
+		if c := br.AheadCommits[0]; c.Author != "Test User" || len(c.Hash) < 7 {
+			t.Errorf("Unexpected commit %+v", c)
+		}
+	}
+
+	res, _ := AnalyzeRepo(ctx, types.Config{}, a, nil, logger)
+	if br := res.Branches[0]; br.AheadCommits != nil || br.BehindCommits != nil {
+		t.Errorf("Commits listed without -commits: %+v", br)
+	}
+}
+
+func TestAnalyzeRepoTags(t *testing.T) {
+	logger, _ := logger.NewLogger([]string{}, "")
+
+	dir := t.TempDir()
+	run := func(dir string, args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-c", "user.name=Test User", "-c", "user.email=test@example.com"}, args...)...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v failed: %v\n%s", args, err, out)
+		}
+	}
+	remote := filepath.Join(dir, "remote.git")
+	a := filepath.Join(dir, "a")
+	run(dir, "init", "--quiet", "--bare", "--initial-branch=main", remote)
+	run(dir, "clone", "--quiet", remote, a)
+	run(a, "commit", "--quiet", "--allow-empty", "-m", "Initial commit")
+	run(a, "push", "--quiet", "origin", "main")
+	run(a, "tag", "v1.0")
+
+	tests := []struct {
+		name    string
+		fetch   bool
+		tags    []string
+		partial bool
+	}{
+		// Offline, a tag on a pushed commit cannot be told apart from a pushed tag
+		{"NoFetch", false, nil, true},
+		{"Fetch", true, []string{"v1.0"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := &git.RecordingRunner{Runner: git.ExecRunner{}}
+			ctx := git.WithRunner(context.Background(), recorder)
+
+			res, ok := AnalyzeRepo(ctx, types.Config{Fetch: tt.fetch}, a, nil, logger)
+			if !ok || res.Error != nil {
+				t.Fatalf("AnalyzeRepo failed: %+v", res)
+			}
+			if !reflect.DeepEqual(res.UnpushedTags, tt.tags) || res.TagsPartial != tt.partial {
+				t.Errorf("Unpushed tags %v (partial %v), want %v (partial %v)", res.UnpushedTags, res.TagsPartial, tt.tags, tt.partial)
+			}
+
+			lsRemote := false
+			for _, call := range recorder.Calls() {
+				if call.Args[0] == "ls-remote" {
+					lsRemote = true
+				}
+			}
+			if lsRemote != tt.fetch {
+				t.Errorf("Ran git ls-remote = %v, want %v", lsRemote, tt.fetch)
+			}
+		})
+	}
+}
+
+func TestPathLess(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"/src/a", "/src/a-c", true},
+		{"/src/a/b", "/src/a-c", true},
+		{"/src/a-c", "/src/a/b", false},
+		{"/src/a", "/src/a/b", true},
+		{"/src/b", "/src/a/z", false},
+		{"/src/a", "/src/a", false},
+	}
+	for _, tt := range tests {
+		if got := PathLess(filepath.FromSlash(tt.a), filepath.FromSlash(tt.b)); got != tt.want {
+			t.Errorf("PathLess(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,200 @@
+//go:build linux
+
+package watch
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"unsafe"
+
+	"gitstatus/src/logger"
+	"gitstatus/src/types"
+)
+
+// inotifyMask selects the events that can change a repository's status
+const inotifyMask = syscall.IN_CREATE | syscall.IN_DELETE | syscall.IN_CLOSE_WRITE |
+	syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO | syscall.IN_ATTRIB |
+	syscall.IN_DELETE_SELF | syscall.IN_MOVE_SELF
+
+// inotify watches directories with the Linux inotify API. Directories
+// created inside a watched working tree are watched as they appear.
+type inotify struct {
+	cfg    types.Config
+	logger *logger.Logger
+	fd     int
+	file   *os.File // fd, for reads that stop when the notifier is closed
+	events chan string
+	done   chan struct{}
+
+	mu      sync.Mutex
+	dirs    map[int32]string // watch descriptor to directory
+	watched map[string]bool
+}
+
+func newNotifier(cfg types.Config, logger *logger.Logger) (notifier, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, fmt.Errorf("inotify_init1: %w", err)
+	}
+
+	n := &inotify{
+		cfg:     cfg,
+		logger:  logger,
+		fd:      fd,
+		file:    os.NewFile(uintptr(fd), "inotify"),
+		events:  make(chan string, 256),
+		done:    make(chan struct{}),
+		dirs:    make(map[int32]string),
+		watched: make(map[string]bool),
+	}
+	go n.read()
+	return n, nil
+}
+
+func (n *inotify) name() string {
+	return "inotify"
+}
+
+func (n *inotify) changes() <-chan string {
+	return n.events
+}
+
+func (n *inotify) close() error {
+	close(n.done)
+	return n.file.Close()
+}
+
+// watch adds every directory not watched yet. The git directories are only
+// watched at the top and under refs, leaving out the object database.
+func (n *inotify) watch(dirs []string, repos []*repo) error {
+	for _, dir := range dirs {
+		if err := n.add(dir); err != nil {
+			return err
+		}
+	}
+
+	for _, r := range repos {
+		if err := n.addTree(r.location.Path); err != nil {
+			return err
+		}
+		for _, gitDir := range r.gitDirs {
+			if err := n.add(gitDir); err != nil {
+				return err
+			}
+			if err := n.addTree(filepath.Join(gitDir, "refs")); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// add watches dir. Directories that vanished in the meantime are skipped;
+// running out of watches is an error.
+func (n *inotify) add(dir string) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.watched[dir] {
+		return nil
+	}
+	wd, err := syscall.InotifyAddWatch(n.fd, dir, inotifyMask)
+	if err != nil {
+		if errors.Is(err, syscall.ENOSPC) {
+			return fmt.Errorf("inotify watch limit reached (see /proc/sys/fs/inotify/max_user_watches): %w", err)
+		}
+		n.logger.Debug("Cannot watch %s: %v", dir, err)
+		return nil
+	}
+	n.dirs[int32(wd)] = dir
+	n.watched[dir] = true
+	return nil
+}
+
+// addTree watches root and the directories below it, except skipped ones
+func (n *inotify) addTree(root string) error {
+	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.IsDir() {
+			return nil
+		}
+		if path != root && skipDir(n.cfg, d.Name()) {
+			return filepath.SkipDir
+		}
+		return n.add(path)
+	})
+}
+
+// read decodes events until the notifier is closed
+func (n *inotify) read() {
+	buf := make([]byte, 64*1024)
+	for {
+		count, err := n.file.Read(buf)
+		if err != nil {
+			select {
+			case <-n.done:
+			default:
+				n.logger.Error("Reading inotify events failed: %v", err)
+			}
+			close(n.events)
+			return
+		}
+
+		for offset := 0; offset+syscall.SizeofInotifyEvent <= count; {
+			event := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+			nameStart := offset + syscall.SizeofInotifyEvent
+			name := strings.TrimRight(string(buf[nameStart:nameStart+int(event.Len)]), "\x00")
+			offset = nameStart + int(event.Len)
+
+			if !n.handle(event.Wd, event.Mask, name) {
+				return
+			}
+		}
+	}
+}
+
+// handle sends the path of an event, watching directories created in a
+// watched directory. It reports false once the notifier is closed.
+func (n *inotify) handle(wd int32, mask uint32, name string) bool {
+	if mask&syscall.IN_Q_OVERFLOW != 0 {
+		return n.send("")
+	}
+
+	n.mu.Lock()
+	dir, ok := n.dirs[wd]
+	if mask&syscall.IN_IGNORED != 0 {
+		delete(n.dirs, wd)
+		delete(n.watched, dir)
+		ok = false
+	}
+	n.mu.Unlock()
+	if !ok {
+		return true
+	}
+
+	path := dir
+	if name != "" {
+		path = filepath.Join(dir, name)
+	}
+
+	if mask&syscall.IN_ISDIR != 0 && mask&(syscall.IN_CREATE|syscall.IN_MOVED_TO) != 0 && !skipDir(n.cfg, name) {
+		if err := n.addTree(path); err != nil {
+			n.logger.Warn("%v", err)
+		}
+	}
+	return n.send(path)
+}
+
+func (n *inotify) send(path string) bool {
+	select {
+	case n.events <- path:
+		return true
+	case <-n.done:
+		return false
+	}
+}
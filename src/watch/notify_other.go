@@ -0,0 +1,14 @@
+//go:build !linux
+
+package watch
+
+import (
+	"errors"
+
+	"gitstatus/src/logger"
+	"gitstatus/src/types"
+)
+
+func newNotifier(cfg types.Config, logger *logger.Logger) (notifier, error) {
+	return nil, errors.New("file system notifications are only supported on Linux")
+}
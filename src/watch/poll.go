@@ -0,0 +1,184 @@
+package watch
+
+import (
+	"hash/fnv"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"gitstatus/src/logger"
+	"gitstatus/src/types"
+)
+
+// poller is the notifier used where inotify is not available. Every interval
+// it fingerprints the files of each repository and the entries of every
+// discovered directory, and reports what changed.
+type poller struct {
+	cfg      types.Config
+	logger   *logger.Logger
+	interval time.Duration
+	events   chan string
+	done     chan struct{}
+
+	mu     sync.Mutex
+	repos  map[string][]string // working tree to git directories
+	dirs   map[string]time.Time
+	prints map[string]uint64 // working tree to fingerprint
+}
+
+func newPoller(cfg types.Config, interval time.Duration, logger *logger.Logger) *poller {
+	p := &poller{
+		cfg:      cfg,
+		logger:   logger,
+		interval: interval,
+		events:   make(chan string),
+		done:     make(chan struct{}),
+		repos:    make(map[string][]string),
+		dirs:     make(map[string]time.Time),
+		prints:   make(map[string]uint64),
+	}
+	go p.run()
+	return p
+}
+
+func (p *poller) name() string {
+	return "polling"
+}
+
+func (p *poller) changes() <-chan string {
+	return p.events
+}
+
+func (p *poller) close() error {
+	close(p.done)
+	return nil
+}
+
+// watch replaces the polled repositories and directories, taking their
+// current state as the baseline
+func (p *poller) watch(dirs []string, repos []*repo) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.dirs = make(map[string]time.Time, len(dirs))
+	for _, dir := range dirs {
+		p.dirs[dir] = modTime(dir)
+	}
+
+	p.repos = make(map[string][]string, len(repos))
+	prints := make(map[string]uint64, len(repos))
+	for _, r := range repos {
+		path := r.location.Path
+		p.repos[path] = r.gitDirs
+		if f, ok := p.prints[path]; ok {
+			prints[path] = f
+		} else {
+			prints[path] = p.fingerprint(path, r.gitDirs)
+		}
+	}
+	p.prints = prints
+	return nil
+}
+
+func (p *poller) run() {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.done:
+			return
+		case <-ticker.C:
+		}
+
+		for _, path := range p.poll() {
+			select {
+			case p.events <- path:
+			case <-p.done:
+				return
+			}
+		}
+	}
+}
+
+// poll returns the directories whose entries changed and the repositories
+// whose fingerprint changed since the last poll
+func (p *poller) poll() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var changed []string
+	for dir, previous := range p.dirs {
+		if current := modTime(dir); !current.Equal(previous) {
+			p.dirs[dir] = current
+			changed = append(changed, dir)
+		}
+	}
+	for path, gitDirs := range p.repos {
+		if current := p.fingerprint(path, gitDirs); current != p.prints[path] {
+			p.prints[path] = current
+			changed = append(changed, path)
+		}
+	}
+	return changed
+}
+
+// fingerprint hashes the name, size, mode and modification time of every
+// file in the working tree and of the git directories' top-level entries
+// and refs. It changes whenever the status of the repository may change.
+func (p *poller) fingerprint(path string, gitDirs []string) uint64 {
+	h := fnv.New64a()
+	add := func(name string, info fs.FileInfo) {
+		h.Write([]byte(name))
+		h.Write([]byte(info.ModTime().String()))
+		h.Write([]byte(info.Mode().String()))
+		var size [8]byte
+		for i := range size {
+			size[i] = byte(info.Size() >> (8 * i))
+		}
+		h.Write(size[:])
+	}
+
+	filepath.WalkDir(path, func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() && name != path && skipDir(p.cfg, d.Name()) {
+			return filepath.SkipDir
+		}
+		if info, err := d.Info(); err == nil {
+			add(name, info)
+		}
+		return nil
+	})
+
+	for _, gitDir := range gitDirs {
+		entries, _ := os.ReadDir(gitDir)
+		for _, entry := range entries {
+			if info, err := entry.Info(); err == nil && entry.Name() != "objects" {
+				add(filepath.Join(gitDir, entry.Name()), info)
+			}
+		}
+		filepath.WalkDir(filepath.Join(gitDir, "refs"), func(name string, d fs.DirEntry, err error) error {
+			if err == nil {
+				if info, err := d.Info(); err == nil {
+					add(name, info)
+				}
+			}
+			return nil
+		})
+	}
+
+	return h.Sum64()
+}
+
+// modTime returns the modification time of path, zero if it does not exist
+func modTime(path string) time.Time {
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
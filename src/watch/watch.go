@@ -0,0 +1,331 @@
+// Package watch implements gitstatus -watch: the report stays on screen and a
+// repository is analyzed again whenever its working tree or git directory
+// changes. Repositories created or deleted under the scan roots appear and
+// disappear without restarting.
+package watch
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gitstatus/src/config"
+	"gitstatus/src/defaults"
+	"gitstatus/src/git"
+	"gitstatus/src/logger"
+	"gitstatus/src/output"
+	"gitstatus/src/types"
+	"gitstatus/src/walker"
+)
+
+// debounce is how long changes are collected before the repositories they
+// belong to are analyzed, so a checkout touching many files causes one update
+const debounce = 200 * time.Millisecond
+
+// pollInterval is how often the polling fallback looks for changes
+const pollInterval = 2 * time.Second
+
+// notifier reports changes under the directories it watches. Every change is
+// sent as the path that changed; "" means changes were lost and everything
+// must be checked again.
+type notifier interface {
+	// watch adds the directories found by discovery and the working trees
+	// and git directories of repos. It is called after every discovery.
+	watch(dirs []string, repos []*repo) error
+	changes() <-chan string
+	name() string
+	close() error
+}
+
+// repo is a discovered repository and its latest result
+type repo struct {
+	location walker.Location
+	gitDirs  []string // git directory, then the common directory of a linked worktree
+	result   types.RepoResult
+}
+
+// watcher holds the discovered repositories between updates
+type watcher struct {
+	cfg      types.Config
+	logger   *logger.Logger
+	repos    map[string]*repo
+	notifier notifier
+	redraw   bool // the report has been printed before
+}
+
+// Run scans cfg.RootPaths, prints the report and updates it until ctx is
+// cancelled. Changes are watched with inotify where available, otherwise by
+// polling every pollInterval.
+func Run(ctx context.Context, cfg types.Config, logger *logger.Logger) error {
+	w := &watcher{cfg: cfg, logger: logger, repos: make(map[string]*repo)}
+
+	n, err := newNotifier(cfg, logger)
+	if err != nil {
+		logger.Info("Watching by polling every %s: %v", pollInterval, err)
+		n = newPoller(cfg, pollInterval, logger)
+	}
+	w.notifier = n
+	defer func() { w.notifier.close() }()
+
+	start := time.Now()
+	if err := w.discover(ctx, true); err != nil {
+		return err
+	}
+	if ctx.Err() != nil {
+		return nil
+	}
+	w.draw(time.Since(start))
+
+	// -fetch only applies to the initial scan
+	w.cfg.Fetch = false
+
+	pending := make(map[string]bool)
+	rediscover, all := false, false
+	var timer <-chan time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case path, ok := <-w.notifier.changes():
+			if !ok {
+				return fmt.Errorf("%s stopped unexpectedly", w.notifier.name())
+			}
+			switch {
+			case path == "":
+				rediscover, all = true, true
+			case !w.match(path, pending):
+				rediscover = true
+			}
+			if timer == nil {
+				timer = time.After(debounce)
+			}
+		case <-timer:
+			timer = nil
+			start := time.Now()
+			if all {
+				for path := range w.repos {
+					pending[path] = true
+				}
+			}
+			if rediscover {
+				if err := w.discover(ctx, false); err != nil {
+					return err
+				}
+			}
+			w.refresh(ctx, pending)
+			if ctx.Err() != nil {
+				return nil
+			}
+			w.draw(time.Since(start))
+			pending = make(map[string]bool)
+			rediscover, all = false, false
+		}
+	}
+}
+
+// match adds the repositories path belongs to to pending. A path in a
+// repository's working tree belongs to the innermost repository; a path in
+// a git directory belongs to every repository sharing it. It reports false
+// for paths outside every repository.
+func (w *watcher) match(path string, pending map[string]bool) bool {
+	innermost := ""
+	matched := false
+	for p, r := range w.repos {
+		for _, dir := range r.gitDirs {
+			if within(path, dir) {
+				pending[p] = true
+				matched = true
+			}
+		}
+		if within(path, p) && len(p) > len(innermost) {
+			innermost = p
+		}
+	}
+
+	if innermost != "" {
+		pending[innermost] = true
+	}
+
+	// A .git appearing or disappearing below a repository adds or removes a
+	// nested repository
+	if filepath.Base(path) == ".git" && filepath.Dir(path) != innermost {
+		return false
+	}
+	return innermost != "" || matched
+}
+
+// within reports whether path is dir or inside it
+func within(path, dir string) bool {
+	return path == dir || strings.HasPrefix(path, dir+string(os.PathSeparator))
+}
+
+// discover walks the scan roots again: new repositories are analyzed and
+// added, and repositories that no longer exist are dropped
+func (w *watcher) discover(ctx context.Context, initial bool) error {
+	locations, dirs, err := walker.Discover(ctx, w.cfg, w.logger)
+	if err != nil && ctx.Err() == nil {
+		return err
+	}
+	if ctx.Err() != nil {
+		return nil
+	}
+
+	found := make(map[string]bool)
+	var added []string
+	for _, loc := range locations {
+		found[loc.Path] = true
+		if _, ok := w.repos[loc.Path]; ok {
+			continue
+		}
+		w.repos[loc.Path] = &repo{location: loc, gitDirs: gitDirs(loc.Path)}
+		added = append(added, loc.Path)
+		if !initial {
+			w.logger.Info("New repository: %s", loc.Path)
+		}
+	}
+	for path := range w.repos {
+		if !found[path] {
+			w.logger.Info("Repository removed: %s", path)
+			delete(w.repos, path)
+		}
+	}
+
+	w.analyze(ctx, added)
+
+	repos := make([]*repo, 0, len(w.repos))
+	for _, r := range w.repos {
+		repos = append(repos, r)
+	}
+	if err := w.notifier.watch(dirs, repos); err != nil {
+		if _, polling := w.notifier.(*poller); polling {
+			return err
+		}
+		w.logger.Warn("Watching with %s failed, polling every %s instead: %v", w.notifier.name(), pollInterval, err)
+		w.notifier.close()
+		w.notifier = newPoller(w.cfg, pollInterval, w.logger)
+		return w.notifier.watch(dirs, repos)
+	}
+	return nil
+}
+
+// refresh analyzes the pending repositories again, dropping the ones that
+// are no longer repositories
+func (w *watcher) refresh(ctx context.Context, pending map[string]bool) {
+	var paths []string
+	for path := range pending {
+		if _, ok := w.repos[path]; !ok {
+			continue
+		}
+		if _, _, err := git.DetectRepo(path); err != nil {
+			w.logger.Info("Repository removed: %s", path)
+			delete(w.repos, path)
+			continue
+		}
+		paths = append(paths, path)
+	}
+	w.analyze(ctx, paths)
+}
+
+// analyze runs the analysis of paths on cfg.Jobs workers
+func (w *watcher) analyze(ctx context.Context, paths []string) {
+	jobs := w.cfg.Jobs
+	if jobs < 1 {
+		jobs = defaults.DefaultJobs
+	}
+
+	results := make([]types.RepoResult, len(paths))
+	analyzed := make([]bool, len(paths))
+	sem := make(chan struct{}, jobs)
+	var fetches git.FetchGroup
+	var wg sync.WaitGroup
+	for i, path := range paths {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, path string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			results[i], analyzed[i] = walker.AnalyzeRepo(ctx, w.cfg, path, &fetches, w.logger)
+		}(i, path)
+	}
+	wg.Wait()
+
+	for i, path := range paths {
+		if !analyzed[i] {
+			continue
+		}
+		r := w.repos[path]
+		results[i].Root = r.location.Root
+		r.result = results[i]
+	}
+}
+
+// results returns the latest result of every repository, sorted by path
+func (w *watcher) results() []types.RepoResult {
+	results := make([]types.RepoResult, 0, len(w.repos))
+	for _, r := range w.repos {
+		results = append(results, r.result)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		return walker.PathLess(results[i].Path, results[j].Path)
+	})
+	return results
+}
+
+// draw prints the report, replacing the previous one when stdout is a
+// terminal. elapsed is the time the update took.
+func (w *watcher) draw(elapsed time.Duration) {
+	structured := w.cfg.Format == output.FormatJSON
+	switch {
+	case !structured && output.IsTerminal(os.Stdout):
+		fmt.Print("\033[H\033[2J")
+	case w.redraw && !structured:
+		fmt.Println()
+	}
+	w.redraw = true
+
+	output.PrintResults(w.results(), w.cfg, elapsed, w.logger)
+
+	if !structured {
+		fmt.Printf("\nWatching %s with %s, updated %s. Press Ctrl-C to stop.\n",
+			output.Pluralize(len(w.repos), "repository"), w.notifier.name(), time.Now().Format("15:04:05"))
+	}
+}
+
+// gitDirs returns the git directory of the working tree at path and, for a
+// linked worktree, the common directory holding the shared refs
+func gitDirs(path string) []string {
+	gitDir, err := git.ResolveGitDir(path)
+	if err != nil {
+		return nil
+	}
+	dirs := []string{gitDir}
+
+	content, err := os.ReadFile(filepath.Join(gitDir, "commondir"))
+	if err != nil {
+		return dirs
+	}
+	commonDir := strings.TrimSpace(string(content))
+	if !filepath.IsAbs(commonDir) {
+		commonDir = filepath.Join(gitDir, commonDir)
+	}
+	return append(dirs, filepath.Clean(commonDir))
+}
+
+// skipDir reports whether directories called name are not watched in a
+// working tree: .git, which is watched selectively, and ignored directories
+func skipDir(cfg types.Config, name string) bool {
+	if name == ".git" {
+		return true
+	}
+	for _, ignored := range config.IgnoredDirs(cfg.IgnoredDirs...) {
+		if name == ignored {
+			return true
+		}
+	}
+	return false
+}
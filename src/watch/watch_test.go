@@ -0,0 +1,179 @@
+package watch
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gitstatus/src/git"
+	"gitstatus/src/logger"
+	"gitstatus/src/types"
+	"gitstatus/src/walker"
+)
+
+// initRepo creates a repository with one commit at path
+func initRepo(t *testing.T, path string) {
+	t.Helper()
+	for _, args := range [][]string{
+		{"init", "--quiet", path},
+		{"-C", path, "-c", "user.name=Test User", "-c", "user.email=test@example.com", "commit", "--quiet", "--allow-empty", "-m", "Initial commit"},
+	} {
+		if output, err := exec.Command("git", args...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v failed: %v\n%s", args, err, output)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	w := &watcher{repos: map[string]*repo{
+		"/src/app":          {gitDirs: []string{"/src/app/.git"}},
+		"/src/app/vendor/x": {gitDirs: []string{"/src/app/.git/modules/x"}},
+		"/src/wt":           {gitDirs: []string{"/src/app/.git/worktrees/wt", "/src/app/.git"}},
+	}}
+
+	tests := []struct {
+		path    string
+		matched bool
+		pending []string
+	}{
+		{"/src/app/main.go", true, []string{"/src/app"}},
+		{"/src/app/vendor/x/lib.go", true, []string{"/src/app/vendor/x"}},
+		{"/src/app/.git/refs/heads/main", true, []string{"/src/app", "/src/wt"}},
+		{"/src/app/.git/worktrees/wt/HEAD", true, []string{"/src/app", "/src/wt"}},
+		{"/src/application/main.go", false, nil},
+		{"/src/new/.git", false, nil},
+		{"/src/app/sub/.git", false, []string{"/src/app"}},
+	}
+
+	for _, tt := range tests {
+		pending := make(map[string]bool)
+		if matched := w.match(tt.path, pending); matched != tt.matched {
+			t.Errorf("match(%q) = %v, want %v", tt.path, matched, tt.matched)
+		}
+		if len(pending) != len(tt.pending) {
+			t.Errorf("match(%q) pending = %v, want %v", tt.path, pending, tt.pending)
+			continue
+		}
+		for _, p := range tt.pending {
+			if !pending[p] {
+				t.Errorf("match(%q) pending = %v, want %v", tt.path, pending, tt.pending)
+			}
+		}
+	}
+}
+
+// expectChange waits for n to report a change for which accept returns true
+func expectChange(t *testing.T, n notifier, accept func(string) bool) {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case path := <-n.changes():
+			if accept(path) {
+				return
+			}
+		case <-timeout:
+			t.Fatal("No change reported")
+		}
+	}
+}
+
+func testNotifier(t *testing.T, n notifier) {
+	root := t.TempDir()
+	repoPath := filepath.Join(root, "repo")
+	initRepo(t, repoPath)
+	r := &repo{location: walker.Location{Path: repoPath, Root: root}, gitDirs: gitDirs(repoPath)}
+
+	if err := n.watch([]string{root}, []*repo{r}); err != nil {
+		t.Fatalf("watch failed: %v", err)
+	}
+
+	inRepo := func(path string) bool { return within(path, repoPath) }
+	if err := os.WriteFile(filepath.Join(repoPath, "file.txt"), []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	expectChange(t, n, inRepo)
+
+	if err := os.MkdirAll(filepath.Join(repoPath, "sub", "dir"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	expectChange(t, n, inRepo)
+	if err := os.WriteFile(filepath.Join(repoPath, "sub", "dir", "new.txt"), []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	expectChange(t, n, inRepo)
+
+	if err := os.Mkdir(filepath.Join(root, "other"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	expectChange(t, n, func(path string) bool { return !inRepo(path) })
+}
+
+func TestNotifier(t *testing.T) {
+	logger, _ := logger.NewLogger([]string{}, "")
+	n, err := newNotifier(types.Config{}, logger)
+	if err != nil {
+		t.Skipf("No file system notifications: %v", err)
+	}
+	defer n.close()
+
+	testNotifier(t, n)
+}
+
+func TestPoller(t *testing.T) {
+	logger, _ := logger.NewLogger([]string{}, "")
+	p := newPoller(types.Config{}, 20*time.Millisecond, logger)
+	defer p.close()
+
+	testNotifier(t, p)
+}
+
+func TestDiscoverAddsAndRemovesRepositories(t *testing.T) {
+	logger, _ := logger.NewLogger([]string{}, "")
+	ctx := context.Background()
+
+	root := t.TempDir()
+	initRepo(t, filepath.Join(root, "a"))
+
+	cfg := types.Config{RootPaths: []string{root}, Backend: git.BackendExec}
+	w := &watcher{cfg: cfg, logger: logger, repos: make(map[string]*repo), notifier: newPoller(cfg, time.Hour, logger)}
+	defer w.notifier.close()
+
+	if err := w.discover(ctx, true); err != nil {
+		t.Fatalf("discover failed: %v", err)
+	}
+	results := w.results()
+	if len(results) != 1 || results[0].Path != filepath.Join(root, "a") || results[0].Root != root {
+		t.Fatalf("Unexpected results after the first discovery: %+v", results)
+	}
+	if results[0].Branch == "" {
+		t.Error("Repository should be analyzed when it is discovered")
+	}
+
+	initRepo(t, filepath.Join(root, "b"))
+	if err := w.discover(ctx, false); err != nil {
+		t.Fatalf("discover failed: %v", err)
+	}
+	if len(w.repos) != 2 {
+		t.Errorf("Expected the new repository to be added, got %d repositories", len(w.repos))
+	}
+
+	if err := os.WriteFile(filepath.Join(root, "b", "file.txt"), []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.RemoveAll(filepath.Join(root, "a", ".git")); err != nil {
+		t.Fatal(err)
+	}
+	w.refresh(ctx, map[string]bool{filepath.Join(root, "a"): true, filepath.Join(root, "b"): true})
+
+	results = w.results()
+	if len(results) != 1 || results[0].Path != filepath.Join(root, "b") {
+		t.Fatalf("Expected only b after a was removed, got %+v", results)
+	}
+	if !results[0].HasUncommitted {
+		t.Error("Refreshed repository should report the new file")
+	}
+}